@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/rgeraskin/a10-bgp-neighbor-manager/manager"
+	"github.com/rgeraskin/a10-bgp-neighbor-manager/manager/fake"
+)
+
+// signature is the session signature issued by the stub.
+const signature = "e2e"
+
+// axapiStub serves the aXAPI endpoints the manager uses for a single BGP
+// process, keeping the neighbors in a fake device, so the failures are
+// injected with its hooks and answered with the matching status code.
+type axapiStub struct {
+	*httptest.Server
+	as     int
+	device *fake.Device
+}
+
+// newAXAPIStub starts a TLS aXAPI stub of the BGP process of the AS.
+func newAXAPIStub(as int, device *fake.Device) *axapiStub {
+	s := &axapiStub{as: as, device: device}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /axapi/v3/auth", s.auth)
+	neighbors := fmt.Sprintf("/axapi/v3/router/bgp/%d/neighbor/ipv4-neighbor", as)
+	mux.HandleFunc("GET "+neighbors, s.authorized(s.neighbors))
+	mux.HandleFunc("POST "+neighbors, s.authorized(s.addNeighbor))
+	mux.HandleFunc("GET "+neighbors+"/{ip}", s.authorized(s.neighbor))
+	mux.HandleFunc("DELETE "+neighbors+"/{ip}", s.authorized(s.removeNeighbor))
+	mux.HandleFunc("GET "+neighbors+"/oper", s.authorized(s.sessions))
+	s.Server = httptest.NewTLSServer(mux)
+	return s
+}
+
+// auth issues the session signature whatever the credentials.
+func (s *axapiStub) auth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]any{"authresponse": map[string]string{"signature": signature}})
+}
+
+// authorized rejects the requests without the session signature.
+func (s *axapiStub) authorized(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "A10 "+signature {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// neighbors lists the neighbors of the device.
+func (s *axapiStub) neighbors(w http.ResponseWriter, r *http.Request) {
+	neighbors, err := s.device.Neighbors(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	list := make([]map[string]any, 0, len(neighbors))
+	for _, neighbor := range neighbors {
+		list = append(list, map[string]any{
+			"neighbor-ipv4": neighbor.IP,
+			"nbr-remote-as": neighbor.RemoteAS,
+		})
+	}
+	writeJSON(w, map[string]any{"ipv4-neighbor-list": list})
+}
+
+// neighbor gets the neighbor of the path, 404 if it doesn't exist.
+func (s *axapiStub) neighbor(w http.ResponseWriter, r *http.Request) {
+	neighbors, err := s.device.Neighbors(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	for _, neighbor := range neighbors {
+		if neighbor.IP == r.PathValue("ip") {
+			writeJSON(w, map[string]any{"ipv4-neighbor": map[string]any{
+				"neighbor-ipv4": neighbor.IP,
+				"nbr-remote-as": neighbor.RemoteAS,
+			}})
+			return
+		}
+	}
+	writeError(w, manager.ErrNotFound)
+}
+
+// addNeighbor creates the neighbor of the request.
+func (s *axapiStub) addNeighbor(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Neighbor struct {
+			IP       string `json:"neighbor-ipv4"`
+			RemoteAS int    `json:"nbr-remote-as"`
+		} `json:"ipv4-neighbor"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.device.AddNeighbor(r.Context(), request.Neighbor.IP, request.Neighbor.RemoteAS); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, request)
+}
+
+// removeNeighbor deletes the neighbor of the path.
+func (s *axapiStub) removeNeighbor(w http.ResponseWriter, r *http.Request) {
+	if err := s.device.RemoveNeighbor(r.Context(), r.PathValue("ip")); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, map[string]any{})
+}
+
+// sessions reports every neighbor as established.
+func (s *axapiStub) sessions(w http.ResponseWriter, r *http.Request) {
+	neighbors := s.device.State()
+	list := make([]map[string]any, 0, len(neighbors))
+	for ip := range neighbors {
+		list = append(list, map[string]any{
+			"neighbor-ipv4": ip,
+			"oper":          map[string]any{"state": "Established", "up-time": 60},
+		})
+	}
+	writeJSON(w, map[string]any{"ipv4-neighbor-list": list})
+}
+
+// writeJSON writes the JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers the error of the fake device with the status code of
+// its sentinel, 500 otherwise.
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, manager.ErrUnauthorized):
+		code = http.StatusUnauthorized
+	case errors.Is(err, manager.ErrForbidden):
+		code = http.StatusForbidden
+	case errors.Is(err, manager.ErrNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, manager.ErrDeviceBusy):
+		code = http.StatusServiceUnavailable
+	case errors.Is(err, manager.ErrConflict):
+		code = http.StatusConflict
+	}
+	http.Error(w, strings.TrimSpace(err.Error()), code)
+}
@@ -0,0 +1,152 @@
+// Command e2e runs the end-to-end scenarios of the manager against a
+// disposable Kubernetes cluster, e.g. kind, and an aXAPI stub: it creates
+// and updates Node objects and asserts the neighbors of the stub device.
+//
+//	kind create cluster --name a10-e2e
+//	go run ./e2e -context kind-a10-e2e
+//
+// The nodes are created with a label unique to the run and deleted on exit.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/rgeraskin/a10-bgp-neighbor-manager/manager"
+	"github.com/rgeraskin/a10-bgp-neighbor-manager/manager/fake"
+)
+
+const (
+	deviceAS = 65000
+	remoteAS = 65001
+	// otherAS is the remote AS of the neighbors the manager doesn't manage
+	otherAS = 65099
+)
+
+func main() {
+	kubeContext := flag.String("context", "", "kubeconfig context of the cluster, the current one if empty")
+	anyContext := flag.Bool("allow-any-context", false, "allow contexts other than kind-*")
+	timeout := flag.Duration("timeout", time.Minute, "how long to wait for each expectation")
+	verbose := flag.Bool("v", false, "log the manager output")
+	flag.Parse()
+
+	logger := log.NewWithOptions(os.Stderr, log.Options{ReportTimestamp: true, Prefix: "e2e"})
+	if err := run(*kubeContext, *anyContext, *verbose, *timeout, logger); err != nil {
+		logger.Error("End-to-end tests failed", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("End-to-end tests passed")
+}
+
+// run starts the stub and the manager and runs the scenarios in order.
+// Returns the error of the first failed scenario.
+func run(kubeContext string, anyContext, verbose bool, timeout time.Duration, logger *log.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	kubeConfig, contextName, err := loadKubeConfig(kubeContext)
+	if err != nil {
+		return err
+	}
+	if !anyContext && !strings.HasPrefix(contextName, "kind-") {
+		return fmt.Errorf("refusing to create nodes in the %q context, pass -allow-any-context", contextName)
+	}
+	clientset, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("creating Kubernetes client: %w", err)
+	}
+
+	device := fake.NewDevice(
+		manager.BackendNeighbor{IP: "198.51.100.1", RemoteAS: remoteAS},
+		manager.BackendNeighbor{IP: "198.51.100.2", RemoteAS: otherAS},
+	)
+	stub := newAXAPIStub(deviceAS, device)
+	defer stub.Close()
+
+	e := &env{
+		clientset: clientset,
+		device:    device,
+		run:       fmt.Sprintf("%x", time.Now().UnixNano()),
+		timeout:   timeout,
+	}
+	defer e.cleanup(context.WithoutCancel(ctx))
+
+	managerLogger := log.NewWithOptions(os.Stderr, log.Options{ReportTimestamp: true, Prefix: "manager"})
+	if !verbose {
+		managerLogger.SetLevel(log.FatalLevel)
+	}
+	m, err := manager.New(
+		manager.WithKubeConfig(kubeConfig),
+		manager.WithLogger(managerLogger),
+		manager.WithSettings(map[string]string{
+			"A10_ADDRESS":             stub.URL,
+			"A10_USERNAME":            "e2e",
+			"A10_PASSWORD":            "e2e",
+			"A10_AS":                  fmt.Sprint(deviceAS),
+			"A10_REMOTE_AS":           fmt.Sprint(remoteAS),
+			"NODES_LABEL_SELECTOR":    e.label() + "=true",
+			"NODE_ELIGIBILITY_CHECKS": "label,address",
+			"STATUS_ADDRESS":          "127.0.0.1:0",
+			"ALLOW_MASS_REMOVAL":      "true",
+			"SHUTDOWN_GRACE_PERIOD":   "5s",
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("creating manager: %w", err)
+	}
+	managerCtx, stopManager := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- m.Run(managerCtx) }()
+	defer func() {
+		stopManager()
+		<-done
+	}()
+
+	for _, s := range scenarios {
+		logger.Info("Running scenario", "name", s.name)
+		start := time.Now()
+		select {
+		case err := <-done:
+			return fmt.Errorf("manager stopped: %w", err)
+		default:
+		}
+		if err := s.run(ctx, e); err != nil {
+			return fmt.Errorf("%s: %w", s.name, err)
+		}
+		logger.Info("Scenario passed", "name", s.name, "duration", time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// loadKubeConfig loads the client configuration of the kubeconfig context,
+// the current one if empty.
+// Returns the configuration and the name of the context.
+func loadKubeConfig(kubeContext string) (*rest.Config, string, error) {
+	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(),
+		&clientcmd.ConfigOverrides{CurrentContext: kubeContext},
+	)
+	raw, err := loader.RawConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	config, err := loader.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	if kubeContext == "" {
+		kubeContext = raw.CurrentContext
+	}
+	return config, kubeContext, nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/rgeraskin/a10-bgp-neighbor-manager/manager"
+	"github.com/rgeraskin/a10-bgp-neighbor-manager/manager/fake"
+)
+
+// pollInterval is how often the expectations are checked.
+const pollInterval = 200 * time.Millisecond
+
+// env is the state shared by the scenarios of a run.
+type env struct {
+	clientset kubernetes.Interface
+	device    *fake.Device
+	// run tells the nodes of the run apart
+	run     string
+	timeout time.Duration
+	// nodes are the names of the created nodes, deleted on cleanup
+	nodes []string
+}
+
+// scenario is a step of the run, building on the state of the previous
+// ones.
+type scenario struct {
+	name string
+	run  func(ctx context.Context, e *env) error
+}
+
+// scenarios are run in order.
+var scenarios = []scenario{
+	{"stale neighbors are removed and unmanaged ones kept", func(ctx context.Context, e *env) error {
+		if err := e.eventuallyAbsent(ctx, "198.51.100.1"); err != nil {
+			return err
+		}
+		return e.consistentlyPresent(ctx, "198.51.100.2", otherAS)
+	}},
+	{"labeled node is added", func(ctx context.Context, e *env) error {
+		if err := e.createNode(ctx, "node-1", "192.0.2.1", true); err != nil {
+			return err
+		}
+		return e.eventuallyPresent(ctx, "192.0.2.1")
+	}},
+	{"unlabeled node is ignored", func(ctx context.Context, e *env) error {
+		if err := e.createNode(ctx, "node-2", "192.0.2.2", false); err != nil {
+			return err
+		}
+		return e.consistentlyAbsent(ctx, "192.0.2.2")
+	}},
+	{"labeling a node adds it", func(ctx context.Context, e *env) error {
+		if err := e.setLabel(ctx, "node-2", true); err != nil {
+			return err
+		}
+		return e.eventuallyPresent(ctx, "192.0.2.2")
+	}},
+	{"address change moves the neighbor", func(ctx context.Context, e *env) error {
+		if err := e.setAddress(ctx, "node-1", "192.0.2.11"); err != nil {
+			return err
+		}
+		if err := e.eventuallyPresent(ctx, "192.0.2.11"); err != nil {
+			return err
+		}
+		return e.eventuallyAbsent(ctx, "192.0.2.1")
+	}},
+	{"unlabeling a node removes it", func(ctx context.Context, e *env) error {
+		if err := e.setLabel(ctx, "node-2", false); err != nil {
+			return err
+		}
+		return e.eventuallyAbsent(ctx, "192.0.2.2")
+	}},
+	{"busy device is retried", func(ctx context.Context, e *env) error {
+		e.device.FailNext(fake.OpAddNeighbor, manager.ErrDeviceBusy, manager.ErrDeviceBusy)
+		if err := e.createNode(ctx, "node-3", "192.0.2.3", true); err != nil {
+			return err
+		}
+		if err := e.eventuallyPresent(ctx, "192.0.2.3"); err != nil {
+			return err
+		}
+		failed := slices.IndexFunc(e.device.Calls(), func(call fake.Call) bool {
+			return call.Op == fake.OpAddNeighbor && errors.Is(call.Err, manager.ErrDeviceBusy)
+		})
+		if failed < 0 {
+			return errors.New("no busy addition was made")
+		}
+		return nil
+	}},
+	{"duplicate address is kept for its first node", func(ctx context.Context, e *env) error {
+		if err := e.createNode(ctx, "node-4", "192.0.2.3", true); err != nil {
+			return err
+		}
+		if err := e.consistentlyPresent(ctx, "192.0.2.3", remoteAS); err != nil {
+			return err
+		}
+		if err := e.deleteNode(ctx, "node-4"); err != nil {
+			return err
+		}
+		return e.consistentlyPresent(ctx, "192.0.2.3", remoteAS)
+	}},
+	{"deleted node is removed", func(ctx context.Context, e *env) error {
+		if err := e.deleteNode(ctx, "node-1"); err != nil {
+			return err
+		}
+		return e.eventuallyAbsent(ctx, "192.0.2.11")
+	}},
+}
+
+// label is the label key of the nodes of the run.
+func (e *env) label() string {
+	return "e2e.a10-bgp-neighbor-manager/run-" + e.run
+}
+
+// nodeName returns the name of the node of the run.
+func (e *env) nodeName(name string) string {
+	return fmt.Sprintf("a10-e2e-%s-%s", e.run, name)
+}
+
+// createNode creates the node with the external address, labeled for the
+// manager if labeled.
+// Returns an error if the operation fails.
+func (e *env) createNode(ctx context.Context, name, address string, labeled bool) error {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   e.nodeName(name),
+		Labels: map[string]string{e.label(): fmt.Sprint(labeled)},
+	}}
+	node, err := e.clientset.CoreV1().Nodes().Create(ctx, node, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("creating node %s: %w", name, err)
+	}
+	e.nodes = append(e.nodes, node.Name)
+	return e.setAddress(ctx, name, address)
+}
+
+// setAddress sets the external address of the node.
+// Returns an error if the operation fails.
+func (e *env) setAddress(ctx context.Context, name, address string) error {
+	patch := fmt.Sprintf(`{"status":{"addresses":[{"type":"ExternalIP","address":%q}]}}`, address)
+	_, err := e.clientset.CoreV1().Nodes().Patch(
+		ctx, e.nodeName(name), types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{}, "status",
+	)
+	if err != nil {
+		return fmt.Errorf("setting address of node %s: %w", name, err)
+	}
+	return nil
+}
+
+// setLabel labels the node for the manager or not.
+// Returns an error if the operation fails.
+func (e *env) setLabel(ctx context.Context, name string, labeled bool) error {
+	patch := fmt.Sprintf(`{"metadata":{"labels":{%q:%q}}}`, e.label(), fmt.Sprint(labeled))
+	_, err := e.clientset.CoreV1().Nodes().Patch(
+		ctx, e.nodeName(name), types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{},
+	)
+	if err != nil {
+		return fmt.Errorf("labeling node %s: %w", name, err)
+	}
+	return nil
+}
+
+// deleteNode deletes the node.
+// Returns an error if the operation fails.
+func (e *env) deleteNode(ctx context.Context, name string) error {
+	if err := e.clientset.CoreV1().Nodes().Delete(ctx, e.nodeName(name), metav1.DeleteOptions{}); err != nil {
+		return fmt.Errorf("deleting node %s: %w", name, err)
+	}
+	return nil
+}
+
+// cleanup deletes the nodes of the run, whatever their state.
+func (e *env) cleanup(ctx context.Context) {
+	for _, name := range e.nodes {
+		_ = e.clientset.CoreV1().Nodes().Delete(ctx, name, metav1.DeleteOptions{})
+	}
+}
+
+// eventually waits for the condition to hold until the timeout.
+// Returns an error describing the expectation if it never holds.
+func (e *env) eventually(ctx context.Context, expectation string, condition func() bool) error {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for !condition() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("expected %s within %s, device has %v", expectation, e.timeout, e.device.State())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// consistently checks that the condition holds for a few seconds, long
+// enough for the manager to have handled the previous changes.
+// Returns an error describing the expectation if it stops holding.
+func (e *env) consistently(ctx context.Context, expectation string, condition func() bool) error {
+	deadline := time.Now().Add(min(3*time.Second, e.timeout))
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for time.Now().Before(deadline) {
+		if !condition() {
+			return fmt.Errorf("expected %s, device has %v", expectation, e.device.State())
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// has checks if the device has the neighbor with the remote AS.
+func (e *env) has(neighborIP string, as int) bool {
+	got, ok := e.device.State()[neighborIP]
+	return ok && got == as
+}
+
+// eventuallyPresent waits for the managed neighbor to be added.
+func (e *env) eventuallyPresent(ctx context.Context, neighborIP string) error {
+	return e.eventually(ctx, "neighbor "+neighborIP, func() bool { return e.has(neighborIP, remoteAS) })
+}
+
+// eventuallyAbsent waits for the neighbor to be removed.
+func (e *env) eventuallyAbsent(ctx context.Context, neighborIP string) error {
+	return e.eventually(ctx, "no neighbor "+neighborIP, func() bool {
+		_, ok := e.device.State()[neighborIP]
+		return !ok
+	})
+}
+
+// consistentlyPresent checks that the neighbor stays with the remote AS.
+func (e *env) consistentlyPresent(ctx context.Context, neighborIP string, as int) error {
+	return e.consistently(ctx, fmt.Sprintf("neighbor %s with AS %d", neighborIP, as), func() bool {
+		return e.has(neighborIP, as)
+	})
+}
+
+// consistentlyAbsent checks that the neighbor isn't added.
+func (e *env) consistentlyAbsent(ctx context.Context, neighborIP string) error {
+	return e.consistently(ctx, "no neighbor "+neighborIP, func() bool {
+		_, ok := e.device.State()[neighborIP]
+		return !ok
+	})
+}
@@ -0,0 +1,907 @@
+package manager
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultTimeout    = 10 * time.Second
+	maxRequestRetries = 3
+	// defaultSessionIdleTimeout is the ACOS default admin idle timeout
+	defaultSessionIdleTimeout = 10 * time.Minute
+	// sessionExpiryMargin is subtracted from the idle timeout so the session
+	// isn't used right when the device expires it
+	sessionExpiryMargin = 30 * time.Second
+	// defaultNeighborCacheTTL is how long the cached device neighbors are
+	// trusted before they are fetched again
+	defaultNeighborCacheTTL = 5 * time.Minute
+	// busyBackoff is the first wait before retrying a busy device without
+	// Retry-After, doubled on every attempt
+	busyBackoff = 2 * time.Second
+	// maxBusyWait caps the wait before retrying a busy device
+	maxBusyWait = time.Minute
+	// defaultMaxIdleConns is how many idle connections to a device are kept
+	// for reuse
+	defaultMaxIdleConns = 4
+	// defaultIdleConnTimeout is how long an idle connection to a device is
+	// kept
+	defaultIdleConnTimeout = 90 * time.Second
+	authEndpoint           = "/axapi/v3/auth"
+	bgpEndpoint            = "/axapi/v3/router/bgp/%d/neighbor/ipv4-neighbor"
+)
+
+// authResponse is the response from the A10 device when logging in.
+type authResponse struct {
+	AuthResponse struct {
+		Signature string `json:"signature"`
+	} `json:"authresponse"`
+}
+
+// ipv4Neighbor is the structure of the data for a BGP neighbor.
+type ipv4Neighbor struct {
+	NeighborIPV4 string `json:"neighbor-ipv4"`
+	RemoteAS     int    `json:"nbr-remote-as"`
+}
+
+// ipv4Neighbors is the structure of the data for a list of BGP neighbors.
+type ipv4Neighbors struct {
+	Ipv4NeighborList []ipv4Neighbor `json:"ipv4-neighbor-list"`
+}
+
+type A10 struct {
+	logger                     *log.Logger
+	signature                  Secret
+	sessionIssued, sessionUsed time.Time
+	sessionIdleTimeout         time.Duration
+	address, username          string
+	password, token            Secret
+	remoteAS, as               int
+	managedAS                  []int
+	// neighbors are the cached neighbors with a managed remote AS, mapped
+	// to their remote AS
+	neighbors              map[string]int
+	totalNeighbors         int
+	maxNeighbors           int
+	neighborLimitWarnRatio float64
+	protected              protectedNeighbors
+	tlsFingerprints        [][]byte
+	neighborTemplate       *neighborTemplate
+	neighborExtraAttrs     map[string]interface{}
+	// localAS is presented to the neighbors instead of the device AS if
+	// set, unless overridden by the localASAnnotation of the node
+	localAS           int
+	localASAnnotation string
+	neighborsFetched  time.Time
+	neighborCacheTTL  time.Duration
+	lockout           authLockout
+	responseSchema    string
+	transport         transportOptions
+	// unreachable is set by the supervisor while the device is down
+	unreachable atomic.Bool
+	// backend applies the changes instead of the aXAPI if set
+	backend deviceBackend
+	// snmp reads the BGP sessions over SNMP instead of the oper API if set
+	snmp *snmpSessions
+	// partition is the ADP partition of the neighbors, shared if empty
+	partition string
+	// credentials resolve the device credentials instead of the shared
+	// source if set
+	credentials CredentialsProvider
+	// selector limits the nodes peered with the device if set
+	selector *nodeSelector
+	// changeLimiter caps the neighbor mutations per minute if set
+	changeLimiter *rate.Limiter
+	// chaos injects failures into the aXAPI requests if set
+	chaos *chaosInjector
+
+	ctx       context.Context
+	mu        sync.RWMutex
+	refreshMu sync.Mutex
+	client    *http.Client
+}
+
+// transportOptions tune the persistent connections to a device, so bursts
+// of operations reuse the TCP and TLS sessions instead of re-handshaking for
+// each request.
+type transportOptions struct {
+	maxIdleConns    int
+	idleConnTimeout time.Duration
+	http2           bool
+}
+
+// deviceBackend manages the neighbors of a device with a protocol other
+// than the aXAPI.
+type deviceBackend interface {
+	// neighbors gets the neighbors of the device AS with their remote AS
+	neighbors(ctx context.Context) ([]ipv4Neighbor, error)
+	// addNeighbor configures the neighbor with its remote AS
+	addNeighbor(ctx context.Context, neighborIP string, remoteAS int) error
+	// removeNeighbor removes the neighbor configuration
+	removeNeighbor(ctx context.Context, neighborIP string) error
+}
+
+type BGPManager interface {
+	AddNeighbor(neighborIP string) error
+	RemoveNeighbor(neighborIP string) error
+	GetNeighbors() ([]string, error)
+	containsNeighbor(neighborIP string) bool
+	login() error
+	makeRequest(req *http.Request, signature Secret, retry retryPolicy) ([]byte, error)
+}
+
+// newA10 creates the client of the device from the configuration.
+func newA10(ctx context.Context, device deviceConfig, creds Credentials, config *Config) *A10 {
+	a10 := &A10{
+		logger:      config.logger,
+		ctx:         ctx,
+		address:     device.address,
+		username:    creds.Username,
+		password:    creds.Password,
+		token:       creds.Token,
+		as:          device.as,
+		partition:   device.partition,
+		credentials: device.credentials,
+		selector:    device.selector,
+		remoteAS:    cmp.Or(device.remoteAS, config.RemoteAS),
+
+		managedAS: config.ManagedRemoteAS,
+
+		sessionIdleTimeout: config.SessionIdleTimeout,
+		neighborCacheTTL:   config.NeighborCacheTTL,
+		responseSchema:     config.ResponseSchema,
+		transport:          config.Transport,
+		lockout: authLockout{
+			limit:    config.AuthFailureLimit,
+			cooldown: config.AuthLockout,
+		},
+
+		maxNeighbors:           config.MaxNeighbors,
+		neighborLimitWarnRatio: config.NeighborLimitWarnRatio,
+		protected:              config.ProtectedNeighbors,
+		tlsFingerprints:        config.TLSFingerprints,
+		neighborTemplate:       config.NeighborTemplate,
+		neighborExtraAttrs:     device.neighborExtraAttrs(config.NeighborExtraAttrs),
+		localAS:                config.LocalAS,
+		localASAnnotation:      config.LocalASAnnotation,
+		chaos:                  config.Chaos,
+	}
+	a10.changeLimiter = newChangeLimiter(config.MaxChangesPerMinute)
+	a10.AddHTTPClient()
+	switch config.Backend {
+	case backendSSH:
+		a10.backend = newCLIBackend(a10, config.SSHPort, config.SSHHostKeys)
+	case backendREST:
+		a10.backend = newRESTBackend(a10, config.RESTBackend)
+	case backendGNMI:
+		a10.backend = newGNMIBackend(a10, config.GNMI)
+	}
+	if config.backendFactory != nil {
+		a10.backend = externalBackend{config.backendFactory(device.address)}
+	}
+	if config.SessionSource == sessionSourceSNMP {
+		a10.snmp = newSNMPSessions(device.address, config.SNMPPort, config.SNMPCommunity)
+	}
+	return a10
+}
+
+// AddHTTPClient adds an http client to the A10 struct.
+// It creates an http client with TLS skip verify, or pinning the device
+// certificate if fingerprints are configured.
+// To reuse the same client for multiple requests, the idle connections are
+// kept alive as tuned by the transport options. In chaos mode, the
+// transport injects failures into the requests.
+func (a *A10) AddHTTPClient() {
+	maxIdleConns := a.transport.maxIdleConns
+	if maxIdleConns == 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+	idleConnTimeout := a.transport.idleConnTimeout
+	if idleConnTimeout == 0 {
+		idleConnTimeout = defaultIdleConnTimeout
+	}
+	tr := &http.Transport{
+		TLSClientConfig:     a.tlsConfig(),
+		TLSHandshakeTimeout: defaultTimeout,
+		// every device has its own client, so all idle connections go to
+		// the same host
+		MaxIdleConns:        maxIdleConns,
+		MaxIdleConnsPerHost: maxIdleConns,
+		IdleConnTimeout:     idleConnTimeout,
+		// a custom TLS config disables HTTP/2 unless it's forced
+		ForceAttemptHTTP2: a.transport.http2,
+	}
+	a.client = &http.Client{
+		Transport: a.chaos.wrap(a.logger, a.address, tr),
+		Timeout:   defaultTimeout,
+	}
+}
+
+// tlsConfig returns the TLS config of the device connections, skipping
+// the verification, or pinning the device certificate if fingerprints are
+// configured.
+func (a *A10) tlsConfig() *tls.Config {
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	if len(a.tlsFingerprints) > 0 {
+		tlsConfig.VerifyPeerCertificate = verifyFingerprint(a.tlsFingerprints)
+	}
+	return tlsConfig
+}
+
+// login logs in to the A10 device.
+// With a pre-issued token, it uses the token as the session signature
+// instead of logging in. The session is switched to the device partition if
+// set.
+// Returns an error if the operation fails or the logins are locked out.
+func (a *A10) login() error {
+	creds := a.currentCredentials()
+	if creds.Token != "" {
+		a.logger.Debug("Using pre-issued A10 token", "device", a.address)
+		if err := a.activatePartition(creds.Token); err != nil {
+			return err
+		}
+		a.mu.Lock()
+		a.signature = creds.Token
+		a.sessionIssued = time.Now()
+		a.sessionUsed = a.sessionIssued
+		a.mu.Unlock()
+		return nil
+	}
+	if err := a.checkLockout(); err != nil {
+		return err
+	}
+	a.logger.Debug("Logging in to A10")
+
+	url := fmt.Sprintf("%s%s", a.address, authEndpoint)
+
+	// Define the structure of the data
+	data := map[string]interface{}{
+		"credentials": map[string]string{
+			"username": creds.Username,
+			"password": creds.Password.Reveal(),
+		},
+	}
+
+	// Convert the JSON object to a string
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshaling JSON: %w", err)
+	}
+	// Create a new HTTP POST request
+	req, err := http.NewRequestWithContext(a.ctx, "POST", url, bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		return fmt.Errorf("creating request to A10 to get neighbors: %w", err)
+	}
+
+	// make http request
+	body, err := a.makeRequest(req, a.currentSignature(), retryIdempotent)
+	if errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrForbidden) {
+		a.loginRejected()
+	}
+	if err != nil {
+		return fmt.Errorf("making http request: %w", err)
+	}
+	a.loginAccepted()
+
+	// get signature
+	var response authResponse
+	if err = a.decodeResponse("auth", body, "authresponse", &response); err != nil {
+		return fmt.Errorf("unmarshaling JSON from A10 to log in: %w", err)
+	}
+	if response.AuthResponse.Signature == "" {
+		if err := a.unexpectedSchema("auth", "no signature", body); err != nil {
+			return err
+		}
+	}
+	if err := a.activatePartition(Secret(response.AuthResponse.Signature)); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.signature = Secret(response.AuthResponse.Signature)
+	a.sessionIssued = time.Now()
+	a.sessionUsed = a.sessionIssued
+	a.mu.Unlock()
+	a.logger.Debug("Logged in to A10", "signature", Secret(response.AuthResponse.Signature))
+	return nil
+}
+
+// sessionValid checks if the current session can be reused.
+// The session is valid until the device idle timeout elapses since it was
+// last used.
+func (a *A10) sessionValid() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.signature == "" {
+		return false
+	}
+	idleTimeout := a.sessionIdleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = defaultSessionIdleTimeout
+	}
+	return time.Since(a.sessionUsed) < idleTimeout-sessionExpiryMargin
+}
+
+// ensureSession logs in to the A10 device unless a valid session exists.
+// Returns an error if the operation fails.
+func (a *A10) ensureSession() error {
+	if a.sessionValid() {
+		a.mu.RLock()
+		age := time.Since(a.sessionIssued)
+		a.mu.RUnlock()
+		a.logger.Debug("Reusing A10 session", "device", a.address, "age", age)
+		return nil
+	}
+	return a.login()
+}
+
+// invalidateSession drops the current session so the next operation logs in.
+func (a *A10) invalidateSession() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.signature = ""
+}
+
+// beginBatch makes sure the operations of a batch share a valid session.
+// Returns an error if the operation fails.
+func (a *A10) beginBatch() error {
+	return a.ensureSession()
+}
+
+// sessionRequest makes an authenticated http request to the A10 device.
+// It reuses the current session if it's still valid and logs in otherwise.
+// If the device rejects the session, it logs in again and retries once.
+// Failed attempts are retried following the retry policy.
+// Returns an error if the operation fails.
+func (a *A10) sessionRequest(
+	ctx context.Context,
+	method, url string,
+	data []byte,
+	retry retryPolicy,
+) ([]byte, error) {
+	if err := a.ensureSession(); err != nil {
+		return nil, fmt.Errorf("logging in to A10: %w", err)
+	}
+	body, err := a.request(ctx, method, url, data, retry)
+	if errors.Is(err, ErrUnauthorized) {
+		a.logger.Info(
+			"A10 session rejected, logging in again",
+			"device", a.address,
+			"correlationID", correlationID(ctx),
+		)
+		a.invalidateSession()
+		if err := a.login(); err != nil {
+			return nil, fmt.Errorf("logging in to A10: %w", err)
+		}
+		body, err = a.request(ctx, method, url, data, retry)
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	a.sessionUsed = time.Now()
+	a.mu.Unlock()
+	return body, nil
+}
+
+// request creates and makes an http request with the current session.
+// The correlation ID of the context is sent with the request.
+// Returns an error if the operation fails.
+func (a *A10) request(
+	ctx context.Context,
+	method, url string,
+	data []byte,
+	retry retryPolicy,
+) ([]byte, error) {
+	var reqBody io.Reader
+	if data != nil {
+		reqBody = bytes.NewBuffer(data)
+		a.logger.Debug(
+			"A10 request body",
+			"method", method,
+			"url", url,
+			"body", redactJSON(data),
+			"correlationID", correlationID(ctx),
+		)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("creating request to A10: %w", err)
+	}
+	body, err := a.makeRequest(req, a.currentSignature(), retry)
+	if err != nil {
+		return nil, fmt.Errorf("making http request: %w", err)
+	}
+	return body, nil
+}
+
+// currentSignature returns the signature of the current session.
+func (a *A10) currentSignature() Secret {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.signature
+}
+
+// GetNeighbors gets the neighbors from the A10 device.
+// It first makes sure there is a valid session, and then
+// makes a request to get the neighbors.
+// Returns an error if the operation fails.
+func (a *A10) GetNeighbors() error {
+	a.logger.Debug("Getting neighbors from A10")
+
+	var deviceNeighbors []ipv4Neighbor
+	var err error
+	if a.backend != nil {
+		deviceNeighbors, err = a.backend.neighbors(a.ctx)
+	} else {
+		deviceNeighbors, err = a.fetchNeighbors()
+	}
+	if err != nil {
+		return fmt.Errorf("getting neighbors: %w", err)
+	}
+
+	// Update the A10 struct's Neighbors field
+	neighbors := make(map[string]int, len(deviceNeighbors))
+	for _, n := range deviceNeighbors {
+		ip, err := parseNeighborIP(n.NeighborIPV4)
+		if err != nil {
+			a.logger.Warn("Ignoring invalid A10 neighbor", "device", a.address, "error", err)
+			continue
+		}
+		n.NeighborIPV4 = ip
+		if a.protected.contains(n.NeighborIPV4) {
+			a.logger.Debug("Ignoring protected neighbor", "neighbor", n.NeighborIPV4)
+			continue
+		}
+		if a.managesAS(n.RemoteAS) {
+			neighbors[n.NeighborIPV4] = n.RemoteAS
+		}
+	}
+	a.logger.Debug(
+		"Neighbors from A10 with AS that matches",
+		"AS",
+		a.remoteAS,
+		"neighbors",
+		len(neighbors),
+	)
+	a.mu.Lock()
+	a.neighbors = neighbors
+	a.totalNeighbors = len(deviceNeighbors)
+	a.neighborsFetched = time.Now()
+	a.mu.Unlock()
+	a.checkNeighborLimit()
+	return nil
+}
+
+// fetchNeighbors gets the neighbors from the aXAPI.
+// Returns an error if the operation fails.
+func (a *A10) fetchNeighbors() ([]ipv4Neighbor, error) {
+	url := fmt.Sprintf("%s%s", a.address, fmt.Sprintf(bgpEndpoint, a.as))
+
+	// Make a HTTP GET request
+	body, err := a.sessionRequest(a.ctx, "GET", url, nil, retryIdempotent)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the JSON response
+	var response ipv4Neighbors
+	if err = a.decodeResponse("neighbors", body, "ipv4-neighbor-list", &response); err != nil {
+		return nil, fmt.Errorf("unmarshaling JSON from A10 to get neighbors: %w", err)
+	}
+
+	// For debugging, print the response
+	a.logger.Debug("Response from A10 to get neighbors:", "response", response)
+
+	neighbors := make([]ipv4Neighbor, 0, len(response.Ipv4NeighborList))
+	for _, n := range response.Ipv4NeighborList {
+		if n.NeighborIPV4 == "" {
+			if err := a.unexpectedSchema("neighbors", "neighbor without neighbor-ipv4", body); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		neighbors = append(neighbors, n)
+	}
+	return neighbors, nil
+}
+
+// revalidateNeighbors re-fetches the neighbors from the A10 device if the
+// cache is older than its TTL. A zero TTL disables expiration.
+// Returns an error if the operation fails.
+func (a *A10) revalidateNeighbors() error {
+	if a.neighborCacheTTL == 0 {
+		return nil
+	}
+	// let only one caller refresh, the others wait and reuse the result
+	a.refreshMu.Lock()
+	defer a.refreshMu.Unlock()
+
+	a.mu.RLock()
+	age := time.Since(a.neighborsFetched)
+	a.mu.RUnlock()
+	if age < a.neighborCacheTTL {
+		return nil
+	}
+	a.logger.Info("A10 neighbor cache expired, fetching neighbors", "device", a.address, "age", age)
+	return a.GetNeighbors()
+}
+
+// listNeighbors returns the cached neighbors, sorted.
+func (a *A10) listNeighbors() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return slices.Sorted(maps.Keys(a.neighbors))
+}
+
+// neighborSet returns a copy of the cached neighbors mapped to their remote
+// AS, for lookups in large clusters.
+func (a *A10) neighborSet() map[string]int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return maps.Clone(a.neighbors)
+}
+
+// containsNeighbor checks if a neighbor exists in the A10 device.
+// It first checks if the neighbor exists, and if so,
+// returns true.
+func (a *A10) containsNeighbor(neighborIP string) bool {
+	logger := a.logger.With(
+		"neighbor", neighborIP,
+	)
+	// a.getNeighbors()
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	_, contains := a.neighbors[neighborIP]
+	logger.Debug("Checking if neighbor is in A10", "contains", contains)
+	return contains
+}
+
+// peersWith checks if the neighbor exists in the A10 device with its
+// remote AS.
+func (a *A10) peersWith(neighbor Neighbor) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	remoteAS, ok := a.neighbors[neighbor.IP]
+	return ok && remoteAS == a.neighborRemoteAS(neighbor)
+}
+
+// AddNeighbor adds a new BGP neighbor to the A10 device.
+// It first revalidates the cached neighbors if they are stale,
+// then checks if the neighbor already exists, and if not,
+// creates a new neighbor with the specified IP and remote AS
+// and the attributes rendered from the neighbor template.
+// A neighbor existing with another remote AS, e.g. after its node moved to
+// another pool or the integration ASN changed, is deleted and re-created.
+// Returns an error if the operation fails.
+func (a *A10) AddNeighbor(ctx context.Context, neighbor Neighbor) error {
+	neighborIP := neighbor.IP
+	logger := a.logger.With(
+		"neighbor", neighborIP,
+		"node", neighbor.NodeName,
+		"correlationID", correlationID(ctx),
+	)
+
+	if a.protected.contains(neighborIP) {
+		logger.Warn("Refusing to add protected neighbor to A10")
+		return nil
+	}
+	remoteAS := a.neighborRemoteAS(neighbor)
+	if !a.managesAS(remoteAS) {
+		return fmt.Errorf(
+			"remote AS %d is not managed, add it to A10_MANAGED_REMOTE_AS",
+			remoteAS,
+		)
+	}
+	if err := a.revalidateNeighbors(); err != nil {
+		return fmt.Errorf("revalidating neighbors: %w", err)
+	}
+	if a.peersWith(neighbor) {
+		logger.Info("Neighbor already exists in A10")
+		return nil
+	}
+	if a.containsNeighbor(neighborIP) {
+		if err := a.replaceNeighbor(ctx, logger, neighborIP, remoteAS); err != nil {
+			return err
+		}
+	}
+	logger.Info("Adding neighbor to A10")
+	if err := a.waitChangeBudget(ctx); err != nil {
+		return fmt.Errorf("waiting for the change rate limit: %w", err)
+	}
+	if err := a.createNeighbor(ctx, neighbor); err != nil {
+		return fmt.Errorf("adding neighbor: %w", err)
+	}
+
+	a.mu.Lock()
+	if _, ok := a.neighbors[neighborIP]; !ok {
+		if a.neighbors == nil {
+			a.neighbors = map[string]int{}
+		}
+		a.totalNeighbors++
+	}
+	a.neighbors[neighborIP] = remoteAS
+	a.mu.Unlock()
+	a.checkNeighborLimit()
+	return nil
+}
+
+// replaceNeighbor deletes the neighbor configured with another remote AS,
+// so it's re-created with the remote AS.
+// Returns an error if the operation fails.
+func (a *A10) replaceNeighbor(ctx context.Context, logger *log.Logger, neighborIP string, remoteAS int) error {
+	a.mu.RLock()
+	configuredAS := a.neighbors[neighborIP]
+	a.mu.RUnlock()
+	logger.Info(
+		"Neighbor exists in A10 with another remote AS, replacing it",
+		"remoteAS", configuredAS,
+		"desiredRemoteAS", remoteAS,
+	)
+	if err := a.waitChangeBudget(ctx); err != nil {
+		return fmt.Errorf("waiting for the change rate limit: %w", err)
+	}
+	if err := a.deleteNeighbor(ctx, neighborIP); err != nil {
+		return fmt.Errorf("removing neighbor with remote AS %d: %w", configuredAS, err)
+	}
+	a.mu.Lock()
+	if _, ok := a.neighbors[neighborIP]; ok {
+		delete(a.neighbors, neighborIP)
+		a.totalNeighbors--
+	}
+	a.mu.Unlock()
+	return nil
+}
+
+// createNeighbor creates the neighbor on the device, with the backend and
+// its remote AS only, or with the aXAPI and the attributes rendered from the
+// neighbor template.
+// Returns an error if the operation fails.
+func (a *A10) createNeighbor(ctx context.Context, neighbor Neighbor) error {
+	if a.backend != nil {
+		return a.backend.addNeighbor(ctx, neighbor.IP, a.neighborRemoteAS(neighbor))
+	}
+	logger := a.logger.With(
+		"neighbor", neighbor.IP,
+		"node", neighbor.NodeName,
+		"correlationID", correlationID(ctx),
+	)
+	url := fmt.Sprintf("%s%s", a.address, fmt.Sprintf(bgpEndpoint, a.as))
+
+	attrs, err := a.neighborPayload(neighbor)
+	if err != nil {
+		return fmt.Errorf("building neighbor payload: %w", err)
+	}
+	data := map[string]interface{}{
+		"ipv4-neighbor": attrs,
+	}
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshaling request data: %w", err)
+	}
+	logger.Debugf("Request body to add neighbor: %s", redactJSON(jsonData))
+
+	logger.Debug("Making request to A10 to add neighbor")
+	_, err = a.sessionRequest(ctx, "POST", url, jsonData, a.retryCreate(ctx, neighbor.IP))
+	return err
+}
+
+// RemoveNeighbor removes a BGP neighbor from the A10 device.
+// It first revalidates the cached neighbors if they are stale,
+// then checks if the neighbor exists, and if so,
+// removes the neighbor from the A10 device.
+// Returns an error if the operation fails.
+func (a *A10) RemoveNeighbor(ctx context.Context, neighborIP string, nodeName string) error {
+	logger := a.logger.With(
+		"neighbor", neighborIP,
+		"node", nodeName,
+		"correlationID", correlationID(ctx),
+	)
+
+	if a.protected.contains(neighborIP) {
+		logger.Warn("Refusing to remove protected neighbor from A10")
+		return nil
+	}
+	if err := a.revalidateNeighbors(); err != nil {
+		return fmt.Errorf("revalidating neighbors: %w", err)
+	}
+	if !a.containsNeighbor(neighborIP) {
+		logger.Info("Neighbor does not exist in A10")
+		return nil
+	}
+	logger.Info("Removing neighbor from A10")
+	if err := a.waitChangeBudget(ctx); err != nil {
+		return fmt.Errorf("waiting for the change rate limit: %w", err)
+	}
+	if err := a.deleteNeighbor(ctx, neighborIP); err != nil {
+		return fmt.Errorf("removing neighbor: %w", err)
+	}
+
+	// Delete neighbor from A10
+	a.mu.Lock()
+	if _, ok := a.neighbors[neighborIP]; ok {
+		delete(a.neighbors, neighborIP)
+		a.totalNeighbors--
+	}
+	logger.Debug("Neighbors after deletion", "neighbors", len(a.neighbors))
+	a.mu.Unlock()
+	a.checkNeighborLimit()
+	return nil
+}
+
+// deleteNeighbor deletes the neighbor from the device.
+// Returns an error if the operation fails.
+func (a *A10) deleteNeighbor(ctx context.Context, neighborIP string) error {
+	if a.backend != nil {
+		return a.backend.removeNeighbor(ctx, neighborIP)
+	}
+
+	// Create a new HTTP DELETE request
+	url := fmt.Sprintf(
+		"%s%s/%s",
+		a.address,
+		fmt.Sprintf(bgpEndpoint, a.as),
+		neighborIP,
+	)
+
+	a.logger.Debug(
+		"Making request to A10 to remove neighbor",
+		"neighbor", neighborIP,
+		"correlationID", correlationID(ctx),
+	)
+	_, err := a.sessionRequest(ctx, "DELETE", url, nil, retryDelete)
+	return err
+}
+
+// makeRequest makes an http request to the A10 device.
+// It adds the necessary headers to the request, and then
+// makes the request. When the device is busy, it waits for Retry-After or
+// backs off before retrying. Other failures are retried following the retry
+// policy.
+// Returns an error if the operation fails.
+func (a *A10) makeRequest(req *http.Request, signature Secret, retry retryPolicy) ([]byte, error) {
+	// add headers
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("content-type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("A10 %s", signature.Reveal()))
+	if id := correlationID(req.Context()); id != "" {
+		req.Header.Set(correlationHeader, id)
+	}
+
+	var lastErr error
+	var wait time.Duration
+	var busy bool
+	for i := 0; i < maxRequestRetries; i++ {
+		if lastErr != nil && !busy {
+			done, err := retry.canRetry(a.logger, lastErr)
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				return nil, nil
+			}
+		}
+		if lastErr != nil {
+			a.logger.Error(
+				"Retrying request",
+				"error", lastErr,
+				"attempt", i+1,
+				"wait", wait,
+				"correlationID", correlationID(req.Context()),
+			)
+			select {
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			case <-time.After(wait):
+			}
+			// rewind the request body consumed by the previous attempt
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, fmt.Errorf("rewinding request body: %w", err)
+				}
+				req.Body = body
+			}
+		}
+
+		start := time.Now()
+		resp, err := a.client.Do(req)
+		if err != nil {
+			a.observeRequest(req, start, 0)
+			lastErr = err
+			wait = 0
+			busy = false
+			continue
+		}
+
+		// Read response body into string
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			a.observeRequest(req, start, 0)
+			return nil, fmt.Errorf("error reading response body: %w", err)
+		}
+		a.observeRequest(req, start, resp.StatusCode)
+
+		// the session is rejected, retrying won't help
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, newStatusError(resp.StatusCode, false)
+		}
+
+		// the account lacks the privilege, retrying won't help either
+		if resp.StatusCode == http.StatusForbidden {
+			return nil, newStatusError(resp.StatusCode, false)
+		}
+
+		// the object doesn't exist, retrying won't help
+		if resp.StatusCode == http.StatusNotFound {
+			if retry.notFoundDone {
+				return nil, nil
+			}
+			return nil, newStatusError(resp.StatusCode, false)
+		}
+
+		// the management plane is busy, retry in the longer backoff class
+		if deviceBusy(resp.StatusCode, body) {
+			lastErr = newStatusError(resp.StatusCode, true)
+			wait = retryAfter(resp.Header.Get("Retry-After"), i)
+			busy = true
+			continue
+		}
+
+		// check if status code is ok
+		if resp.StatusCode != http.StatusOK {
+			lastErr = newStatusError(resp.StatusCode, false)
+			wait = 0
+			busy = false
+			continue
+		}
+
+		return body, nil
+	}
+
+	return nil, fmt.Errorf(
+		"error making http request after %d retries: %w",
+		maxRequestRetries,
+		lastErr,
+	)
+}
+
+// deviceBusy checks if the response means the management plane is busy:
+// 429, 503 or an aXAPI error saying the system is busy.
+func deviceBusy(statusCode int, body []byte) bool {
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable {
+		return true
+	}
+	return statusCode != http.StatusOK &&
+		bytes.Contains(bytes.ToLower(body), []byte("busy"))
+}
+
+// retryAfter gets the wait before retrying a busy device from the
+// Retry-After header, in seconds or as an HTTP date. Without the header it
+// backs off exponentially from busyBackoff. The wait is capped at
+// maxBusyWait.
+func retryAfter(header string, attempt int) time.Duration {
+	wait := busyBackoff << attempt
+	if seconds, err := strconv.Atoi(header); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		wait = time.Until(date)
+	}
+	return min(max(wait, 0), maxBusyWait)
+}
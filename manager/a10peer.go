@@ -0,0 +1,293 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+const (
+	defaultPeerStatusInterval = 30 * time.Second
+	// peerManagedByLabel marks the A10Peers written by the controller
+	peerManagedByLabel = "app.kubernetes.io/managed-by"
+	peerManagedBy      = "a10-bgp-neighbor-manager"
+)
+
+// a10Peers is the A10Peer resource, one per peered node, named after it.
+var a10Peers = schema.GroupVersionResource{
+	Group:    "a10bgp.rgeraskin.github.io",
+	Version:  "v1alpha1",
+	Resource: "a10peers",
+}
+
+// The phases of an A10Peer.
+const (
+	peerPhasePending     = "Pending"
+	peerPhaseConfigured  = "Configured"
+	peerPhaseEstablished = "Established"
+	peerPhaseError       = "Error"
+)
+
+// a10PeerSpec is the spec of an A10Peer.
+type a10PeerSpec struct {
+	NodeName string `json:"nodeName"`
+	Address  string `json:"address"`
+}
+
+// a10PeerStatus is the status of an A10Peer: Established once the neighbor
+// is configured on every device and all its sessions are up, Configured
+// while it's configured but the sessions aren't all up or aren't scraped,
+// Error if a device failed to sync it and Pending otherwise.
+type a10PeerStatus struct {
+	Phase              string          `json:"phase"`
+	Reason             string          `json:"reason,omitempty"`
+	Devices            []a10PeerDevice `json:"devices,omitempty"`
+	LastTransitionTime string          `json:"lastTransitionTime,omitempty"`
+}
+
+// a10PeerDevice is the sync and session state of the neighbor of an
+// A10Peer on a device.
+type a10PeerDevice struct {
+	Device        string `json:"device"`
+	State         string `json:"state"`
+	Session       string `json:"session,omitempty"`
+	LastSync      string `json:"lastSync,omitempty"`
+	LastError     string `json:"lastError,omitempty"`
+	LastErrorTime string `json:"lastErrorTime,omitempty"`
+}
+
+// peerStatusWriter mirrors the status of the neighbor of every eligible
+// node to an A10Peer, for kubectl-native visibility of the peerings, to a
+// Node condition other controllers can react to, and to Node annotations
+// for the operators inspecting the node. The A10Peers of the
+// nodes no longer peered are deleted, and the ones of the deleted nodes are
+// garbage collected with them.
+type peerStatusWriter struct {
+	logger    *log.Logger
+	client    dynamic.Interface
+	clientset kubernetes.Interface
+	nodes     corelisters.NodeLister
+	status    *statusTracker
+	interval  time.Duration
+	// crd writes the A10Peers
+	crd bool
+	// condition is the Node condition type written if set
+	condition string
+	// annotations writes the Node annotations
+	annotations bool
+}
+
+// start writes the peering status every interval until the context is
+// done.
+func (w *peerStatusWriter) start(ctx context.Context) {
+	w.logger.Info(
+		"Starting peering status writer",
+		"interval", w.interval,
+		"crd", w.crd,
+		"condition", w.condition,
+		"annotations", w.annotations,
+	)
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			report := w.status.report()
+			if w.crd {
+				if err := w.write(ctx, report); err != nil {
+					w.logger.Error("Error writing A10Peer status", "error", err)
+				}
+			}
+			if w.condition != "" {
+				w.writeConditions(ctx, report)
+			}
+			if w.annotations {
+				w.writeAnnotations(ctx, report)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// write creates, updates and deletes the A10Peers to match the current
+// status. Unchanged A10Peers aren't written.
+// Returns an error if listing the A10Peers fails, the failures of single
+// A10Peers are logged.
+func (w *peerStatusWriter) write(ctx context.Context, report statusReport) error {
+	peers := w.client.Resource(a10Peers)
+	existing, err := peers.List(ctx, metav1.ListOptions{LabelSelector: peerManagedByLabel + "=" + peerManagedBy})
+	if err != nil {
+		return fmt.Errorf("listing A10Peers: %w", err)
+	}
+	current := make(map[string]*unstructured.Unstructured, len(existing.Items))
+	for i := range existing.Items {
+		current[existing.Items[i].GetName()] = &existing.Items[i]
+	}
+
+	for nodeName, node := range report.Nodes {
+		if !node.Eligible || node.Address == "" {
+			continue
+		}
+		peer := current[nodeName]
+		delete(current, nodeName)
+		status := peerStatus(report, node)
+		if err := w.apply(ctx, nodeName, node.Address, peer, status); err != nil {
+			w.logger.Error("Error writing A10Peer", "node", nodeName, "error", err)
+		}
+	}
+	for name := range current {
+		if err := peers.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			w.logger.Error("Error deleting A10Peer", "node", name, "error", err)
+			continue
+		}
+		w.logger.Debug("Deleted A10Peer of node no longer peered", "node", name)
+	}
+	return nil
+}
+
+// apply creates the A10Peer of the node if it's missing and updates its
+// spec and status if they changed. The last transition time is kept while
+// the phase doesn't change.
+// Returns an error if the operation fails.
+func (w *peerStatusWriter) apply(
+	ctx context.Context,
+	nodeName, address string,
+	peer *unstructured.Unstructured,
+	status a10PeerStatus,
+) error {
+	peers := w.client.Resource(a10Peers)
+	spec := a10PeerSpec{NodeName: nodeName, Address: address}
+	if peer == nil {
+		node, err := w.nodes.Get(nodeName)
+		if err != nil {
+			return fmt.Errorf("getting node: %w", err)
+		}
+		peer = &unstructured.Unstructured{}
+		peer.SetAPIVersion(a10Peers.GroupVersion().String())
+		peer.SetKind("A10Peer")
+		peer.SetName(nodeName)
+		peer.SetLabels(map[string]string{peerManagedByLabel: peerManagedBy})
+		peer.SetOwnerReferences([]metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Node",
+			Name:       node.Name,
+			UID:        node.UID,
+		}})
+		if err := setPeerField(peer, "spec", &spec); err != nil {
+			return err
+		}
+		created, err := peers.Create(ctx, peer, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("creating A10Peer: %w", err)
+		}
+		peer = created
+	} else if current, _, _ := unstructured.NestedString(peer.Object, "spec", "address"); current != address {
+		if err := setPeerField(peer, "spec", &spec); err != nil {
+			return err
+		}
+		updated, err := peers.Update(ctx, peer, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("updating A10Peer: %w", err)
+		}
+		peer = updated
+	}
+
+	phase, _, _ := unstructured.NestedString(peer.Object, "status", "phase")
+	status.LastTransitionTime, _, _ = unstructured.NestedString(peer.Object, "status", "lastTransitionTime")
+	if phase != status.Phase || status.LastTransitionTime == "" {
+		status.LastTransitionTime = time.Now().UTC().Format(time.RFC3339)
+	}
+	desired, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		return fmt.Errorf("converting A10Peer status: %w", err)
+	}
+	if current, ok := peer.Object["status"]; ok && equality.Semantic.DeepEqual(current, desired) {
+		return nil
+	}
+	peer.Object["status"] = desired
+	if _, err := peers.UpdateStatus(ctx, peer, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating A10Peer status: %w", err)
+	}
+	if phase != status.Phase {
+		w.logger.Info("A10Peer phase changed", "node", nodeName, "from", phase, "to", status.Phase)
+	}
+	return nil
+}
+
+// setPeerField sets the field of the A10Peer to the value, a pointer to a
+// struct.
+// Returns an error if the value can't be converted.
+func setPeerField(peer *unstructured.Unstructured, field string, value interface{}) error {
+	converted, err := runtime.DefaultUnstructuredConverter.ToUnstructured(value)
+	if err != nil {
+		return fmt.Errorf("converting A10Peer %s: %w", field, err)
+	}
+	peer.Object[field] = converted
+	return nil
+}
+
+// peerStatus derives the A10Peer status of the eligible node from the
+// status report.
+func peerStatus(report statusReport, node nodeStatus) a10PeerStatus {
+	status := a10PeerStatus{Reason: node.Reason}
+	synced, established := 0, 0
+	var failed bool
+	for _, device := range slices.Sorted(maps.Keys(report.Devices)) {
+		neighbor, ok := report.Devices[device][node.Address]
+		if !ok || !neighbor.Present {
+			continue
+		}
+		entry := a10PeerDevice{
+			Device:    device,
+			State:     string(neighbor.State),
+			Session:   neighbor.Session,
+			LastError: neighbor.LastError,
+		}
+		if !neighbor.LastSync.IsZero() {
+			entry.LastSync = neighbor.LastSync.UTC().Format(time.RFC3339)
+		}
+		if !neighbor.LastErrorTime.IsZero() && neighbor.LastError != "" {
+			entry.LastErrorTime = neighbor.LastErrorTime.UTC().Format(time.RFC3339)
+		}
+		status.Devices = append(status.Devices, entry)
+		switch neighbor.State {
+		case syncSynced:
+			synced++
+		case syncError:
+			failed = true
+		}
+		if neighbor.Session == bgpEstablished {
+			established++
+		}
+	}
+	switch {
+	case failed:
+		status.Phase = peerPhaseError
+	case len(status.Devices) == 0 || synced < len(status.Devices):
+		status.Phase = peerPhasePending
+	case established == len(status.Devices):
+		status.Phase = peerPhaseEstablished
+	default:
+		status.Phase = peerPhaseConfigured
+	}
+	if _, ok := report.Quarantined[node.Address]; ok {
+		status.Reason = "neighbor is quarantined: " + report.Quarantined[node.Address].Reason
+	}
+	return status
+}
@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// deviceAdoption is the adoption report of a device.
+type deviceAdoption struct {
+	// Adopted are the pre-existing neighbors matching the desired state
+	Adopted []string `json:"adopted"`
+	// Create are the desired neighbors missing on the device
+	Create []string `json:"create"`
+	// Remove are the neighbors the controller removes, including the
+	// removals refused by the mass-removal guard
+	Remove []string `json:"remove"`
+}
+
+// adoptionReport is how the controller understood the devices on the first
+// reconcile, so operators can validate it before trusting it with
+// removals.
+type adoptionReport struct {
+	CorrelationID string                    `json:"correlationID"`
+	Time          time.Time                 `json:"time"`
+	Devices       map[string]deviceAdoption `json:"devices"`
+}
+
+// newAdoptionReport builds the adoption report of the plan from the device
+// neighbors found before it.
+func newAdoptionReport(plan *reconcilePlan, found map[string][]string) *adoptionReport {
+	report := &adoptionReport{
+		CorrelationID: plan.CorrelationID,
+		Time:          plan.Time,
+		Devices:       make(map[string]deviceAdoption, len(found)),
+	}
+	for device, neighbors := range found {
+		adoption := deviceAdoption{
+			Adopted: []string{},
+			Create:  changesOf(plan.Adds, device),
+			Remove:  append(changesOf(plan.Removes, device), changesOf(plan.Refused, device)...),
+		}
+		slices.Sort(adoption.Remove)
+		for _, neighborIP := range neighbors {
+			if _, removed := slices.BinarySearch(adoption.Remove, neighborIP); !removed {
+				adoption.Adopted = append(adoption.Adopted, neighborIP)
+			}
+		}
+		slices.Sort(adoption.Adopted)
+		report.Devices[device] = adoption
+	}
+	return report
+}
+
+// changesOf returns the IPs of the changes of the device.
+func changesOf(changes []planChange, device string) []string {
+	ips := []string{}
+	for _, change := range changes {
+		if slices.Contains(change.Devices, device) {
+			ips = append(ips, change.IP)
+		}
+	}
+	return ips
+}
+
+// reportAdoption logs, publishes and notifies the adoption report of the
+// first reconcile. Later reconciles are not reported.
+func (d *Devices) reportAdoption(ctx context.Context, plan *reconcilePlan, found map[string][]string) {
+	report := newAdoptionReport(plan, found)
+	if !d.status.setAdoption(report) {
+		return
+	}
+	for device, adoption := range report.Devices {
+		d.logger.Info(
+			"Adoption report",
+			"device", device,
+			"adopted", len(adoption.Adopted),
+			"create", len(adoption.Create),
+			"remove", len(adoption.Remove),
+			"adoptedNeighbors", adoption.Adopted,
+			"createNeighbors", adoption.Create,
+			"removeNeighbors", adoption.Remove,
+			"correlationID", report.CorrelationID,
+		)
+	}
+	d.notifier.notify(ctx, "adoption", report)
+}
@@ -0,0 +1,69 @@
+package manager
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// cidrAllowlist are the CIDRs the neighbor addresses must be in. An empty
+// allowlist allows any address.
+type cidrAllowlist []netip.Prefix
+
+// parseCIDRAllowlist parses a comma-separated list of CIDRs.
+// Returns an error if an entry is not a CIDR.
+func parseCIDRAllowlist(list string) (cidrAllowlist, error) {
+	var allowlist cidrAllowlist
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+		}
+		allowlist = append(allowlist, prefix.Masked())
+	}
+	return allowlist, nil
+}
+
+// allows checks if the neighbor address is in the allowlist.
+func (l cidrAllowlist) allows(neighborIP string) bool {
+	if len(l) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(neighborIP)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range l {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the allowlist for logs.
+func (l cidrAllowlist) String() string {
+	prefixes := make([]string, 0, len(l))
+	for _, prefix := range l {
+		prefixes = append(prefixes, prefix.String())
+	}
+	return strings.Join(prefixes, ",")
+}
+
+// refuse logs and counts refusing the neighbor outside the allowlist.
+func (l cidrAllowlist) refuse(logger *log.Logger, neighbor Neighbor, correlationID string) {
+	logger.Warn(
+		"Refusing neighbor outside ALLOWED_NEIGHBOR_CIDRS",
+		"neighbor", neighbor.IP,
+		"node", neighbor.NodeName,
+		"allowed", l,
+		"correlationID", correlationID,
+	)
+	disallowedNeighbors.Inc()
+}
@@ -0,0 +1,51 @@
+package manager
+
+import (
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// observeRequest records the latency and the status code of an aXAPI
+// request attempt, 0 if no response was received.
+func (a *A10) observeRequest(req *http.Request, start time.Time, statusCode int) {
+	endpoint := apiEndpoint(req.URL.Path)
+	apiRequestDuration.WithLabelValues(a.address, req.Method, endpoint).Observe(time.Since(start).Seconds())
+	code := "error"
+	if statusCode != 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	apiRequests.WithLabelValues(a.address, req.Method, endpoint, code).Inc()
+}
+
+// apiEndpoint returns the endpoint of the aXAPI path with the neighbor IPs,
+// AS numbers and partition names replaced by placeholders, so the metrics
+// have a label value per endpoint rather than per neighbor.
+func apiEndpoint(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		switch {
+		case i > 0 && segments[i-1] == "active-partition":
+			segments[i] = "{partition}"
+		case isNumber(segment):
+			segments[i] = "{as}"
+		case isIP(segment):
+			segments[i] = "{ip}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// isNumber checks if the path segment is a number.
+func isNumber(segment string) bool {
+	_, err := strconv.Atoi(segment)
+	return err == nil
+}
+
+// isIP checks if the path segment is an IP address.
+func isIP(segment string) bool {
+	_, err := netip.ParseAddr(segment)
+	return err == nil
+}
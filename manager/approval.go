@@ -0,0 +1,186 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"k8s.io/client-go/kubernetes"
+)
+
+// approveKey is the key of the approval ConfigMap holding the ID of the
+// approved removals.
+const approveKey = "approve"
+
+// errNoStagedRemovals is returned when approving removals that aren't
+// waiting for approval, e.g. superseded by a later reconcile.
+var errNoStagedRemovals = errors.New("no removals waiting for approval with this ID")
+
+// stagedRemovals are the removals of a reconcile waiting for approval.
+type stagedRemovals struct {
+	// ID is the correlation ID of the reconcile that staged them
+	ID      string       `json:"id"`
+	Time    time.Time    `json:"time"`
+	Changes []planChange `json:"changes"`
+}
+
+// ips returns the IPs of the staged removals.
+func (s *stagedRemovals) ips() []string {
+	return changeIPs(s.Changes)
+}
+
+// approvalGate stages the removals of a reconcile above a threshold until
+// an operator approves them, with the ID of the staged removals in the
+// approve key of a ConfigMap or on the status API, so destructive changes
+// go through the change approval process. A nil gate admits every removal.
+type approvalGate struct {
+	logger    *log.Logger
+	threshold int
+	// configMap carries the approvals if set
+	configMap *configMapRef
+	notifier  *notifier
+
+	mu sync.Mutex
+	// pending are the removals waiting for approval
+	pending *stagedRemovals
+	// approved are the IPs of the approved removals, admitted by the next
+	// reconcile
+	approved []string
+	// approvals is notified when removals are approved, to apply them
+	approvals chan struct{}
+}
+
+// newApprovalGate creates a gate staging more removals than the threshold,
+// following the ConfigMap reference if not empty.
+// Returns nil if the threshold is empty, and an error if a value is
+// invalid.
+func newApprovalGate(logger *log.Logger, threshold, ref, defaultNamespace string) (*approvalGate, error) {
+	if threshold == "" {
+		if ref != "" {
+			return nil, fmt.Errorf("APPROVAL_CONFIGMAP needs APPROVAL_THRESHOLD")
+		}
+		return nil, nil
+	}
+	g := &approvalGate{logger: logger, approvals: make(chan struct{}, 1)}
+	var err error
+	g.threshold, err = strconv.Atoi(threshold)
+	if err != nil || g.threshold < 0 {
+		return nil, fmt.Errorf("APPROVAL_THRESHOLD must be a non-negative number")
+	}
+	if ref != "" {
+		configMap, err := parseConfigMapRef(ref, defaultNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("APPROVAL_CONFIGMAP: %w", err)
+		}
+		g.configMap = &configMap
+	}
+	return g, nil
+}
+
+// start watches the approval ConfigMap, if any, and waits for its initial
+// state.
+// Returns an error if the cache doesn't sync.
+func (g *approvalGate) start(ctx context.Context, clientset kubernetes.Interface) error {
+	if g == nil || g.configMap == nil {
+		return nil
+	}
+	return g.configMap.watch(ctx, clientset, g.follow)
+}
+
+// follow approves the removals of the ID in the ConfigMap data. IDs of
+// removals no longer staged are ignored, e.g. an approval left in place.
+func (g *approvalGate) follow(data map[string]string) {
+	id := data[approveKey]
+	if id == "" {
+		return
+	}
+	if err := g.approve(id); err != nil {
+		g.logger.Debug("Ignoring approval", "configMap", g.configMap, "id", id, "error", err)
+	}
+}
+
+// admit checks if the removals of the reconcile may be applied: they're
+// within the threshold or approved. Otherwise they're staged under the ID
+// of the reconcile, unless the same removals are staged already.
+func (g *approvalGate) admit(ctx context.Context, id string, removals []planChange) bool {
+	if g == nil {
+		return true
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ips := changeIPs(removals)
+	if len(removals) <= g.threshold {
+		g.pending = nil
+		g.approved = nil
+		removalsPendingApproval.Set(0)
+		return true
+	}
+	if len(g.approved) > 0 && !slices.ContainsFunc(ips, func(ip string) bool {
+		return !slices.Contains(g.approved, ip)
+	}) {
+		g.logger.Info("Applying approved A10 neighbor removals", "removals", ips)
+		g.approved = nil
+		return true
+	}
+	if g.pending != nil && slices.Equal(g.pending.ips(), ips) {
+		return false
+	}
+	g.pending = &stagedRemovals{ID: id, Time: time.Now(), Changes: removals}
+	g.approved = nil
+	removalsPendingApproval.Set(float64(len(removals)))
+	g.logger.Warn(
+		"A10 neighbor removals are above the approval threshold, waiting for approval",
+		"id", id,
+		"removals", ips,
+		"threshold", g.threshold,
+	)
+	g.notifier.notify(ctx, "approval", g.pending)
+	return false
+}
+
+// approve approves the staged removals of the ID and triggers their
+// reconcile.
+// Returns an error if no removals are staged with the ID.
+func (g *approvalGate) approve(id string) error {
+	if g == nil {
+		return errNoStagedRemovals
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.pending == nil || g.pending.ID != id {
+		return errNoStagedRemovals
+	}
+	g.logger.Info("A10 neighbor removals approved", "id", id, "removals", len(g.pending.Changes))
+	g.approved = g.pending.ips()
+	g.pending = nil
+	removalsPendingApproval.Set(0)
+	select {
+	case g.approvals <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// report returns the removals waiting for approval, nil if none.
+func (g *approvalGate) report() *stagedRemovals {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.pending
+}
+
+// approvedRemovals returns the channel notified when removals are approved, nil
+// without a gate.
+func (g *approvalGate) approvedRemovals() <-chan struct{} {
+	if g == nil {
+		return nil
+	}
+	return g.approvals
+}
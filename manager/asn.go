@@ -0,0 +1,86 @@
+package manager
+
+import (
+	"cmp"
+	"strconv"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+// asnChecker cross-checks the ASN the node BGP speaker is configured with,
+// read from a node annotation maintained by the FRR or bird tooling, against
+// the remote AS the devices expect. A mismatch leaves the session Idle, so
+// it's worth a warning even though the neighbor is configured anyway.
+type asnChecker struct {
+	logger     *log.Logger
+	annotation string
+	remoteAS   int
+	// table overrides the remote AS of the node pools
+	table *remoteASTable
+
+	mu sync.Mutex
+	// warned maps the mismatching nodes to the annotation value already
+	// warned about, so node updates don't repeat the warning
+	warned map[string]string
+}
+
+// newASNChecker creates a checker reading the annotation, expecting the
+// remote AS of the table or the default one.
+// Returns nil if the annotation is empty.
+func newASNChecker(logger *log.Logger, annotation string, remoteAS int, table *remoteASTable) *asnChecker {
+	if annotation == "" {
+		return nil
+	}
+	return &asnChecker{
+		logger:     logger,
+		annotation: annotation,
+		remoteAS:   remoteAS,
+		table:      table,
+		warned:     map[string]string{},
+	}
+}
+
+// check warns when the node annotation doesn't match the remote AS.
+// Nodes without the annotation are not checked.
+func (c *asnChecker) check(node *v1.Node) {
+	if c == nil {
+		return
+	}
+	value, ok := node.Annotations[c.annotation]
+	asn, err := strconv.Atoi(value)
+	remoteAS := cmp.Or(c.table.lookup(node.Labels), c.remoteAS)
+	mismatch := ok && (err != nil || asn != remoteAS)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !mismatch {
+		delete(c.warned, node.Name)
+		nodeASNMismatch.DeleteLabelValues(node.Name)
+		return
+	}
+	nodeASNMismatch.WithLabelValues(node.Name).Set(1)
+	if warned, ok := c.warned[node.Name]; ok && warned == value {
+		return
+	}
+	c.warned[node.Name] = value
+	c.logger.Warn(
+		"Node BGP speaker ASN doesn't match the A10 remote AS, the session will stay Idle",
+		"node", node.Name,
+		"annotation", c.annotation,
+		"nodeASN", value,
+		"remoteAS", remoteAS,
+	)
+}
+
+// forget drops the state of a deleted node.
+func (c *asnChecker) forget(nodeName string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.warned, nodeName)
+	nodeASNMismatch.DeleteLabelValues(nodeName)
+}
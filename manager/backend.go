@@ -0,0 +1,54 @@
+package manager
+
+import "context"
+
+// Backend manages the BGP neighbors of a device in place of the built-in
+// protocols, e.g. the in-memory fake of the fake package to run the
+// manager without a device. Its errors should wrap the sentinels, like
+// ErrDeviceBusy, to be retried or resynced like the device ones.
+type Backend interface {
+	// Neighbors gets the neighbors of the device AS with their remote AS.
+	Neighbors(ctx context.Context) ([]BackendNeighbor, error)
+	// AddNeighbor configures the neighbor with its remote AS.
+	AddNeighbor(ctx context.Context, neighborIP string, remoteAS int) error
+	// RemoveNeighbor removes the neighbor configuration, succeeding if it
+	// doesn't exist.
+	RemoveNeighbor(ctx context.Context, neighborIP string) error
+}
+
+// BackendNeighbor is a BGP neighbor of a device.
+type BackendNeighbor struct {
+	IP       string
+	RemoteAS int
+}
+
+// BackendFactory returns the backend of the device at the address.
+type BackendFactory func(address string) Backend
+
+// externalBackend adapts a Backend to the device backends.
+type externalBackend struct {
+	Backend
+}
+
+// neighbors gets the neighbors of the device AS with their remote AS.
+func (b externalBackend) neighbors(ctx context.Context) ([]ipv4Neighbor, error) {
+	neighbors, err := b.Neighbors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]ipv4Neighbor, 0, len(neighbors))
+	for _, neighbor := range neighbors {
+		result = append(result, ipv4Neighbor{NeighborIPV4: neighbor.IP, RemoteAS: neighbor.RemoteAS})
+	}
+	return result, nil
+}
+
+// addNeighbor configures the neighbor with its remote AS.
+func (b externalBackend) addNeighbor(ctx context.Context, neighborIP string, remoteAS int) error {
+	return b.AddNeighbor(ctx, neighborIP, remoteAS)
+}
+
+// removeNeighbor removes the neighbor configuration.
+func (b externalBackend) removeNeighbor(ctx context.Context, neighborIP string) error {
+	return b.RemoveNeighbor(ctx, neighborIP)
+}
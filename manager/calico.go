@@ -0,0 +1,142 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// calicoDefaultASN is the ASN of Calico nodes when neither the node nor the
+// default BGPConfiguration sets one.
+const calicoDefaultASN = 64512
+
+var (
+	calicoBGPConfigurations = schema.GroupVersionResource{
+		Group:    "crd.projectcalico.org",
+		Version:  "v1",
+		Resource: "bgpconfigurations",
+	}
+	calicoNodes = schema.GroupVersionResource{
+		Group:    "crd.projectcalico.org",
+		Version:  "v1",
+		Resource: "nodes",
+	}
+)
+
+// calicoBGPConfiguration holds the fields of a Calico BGPConfiguration the
+// integration uses.
+type calicoBGPConfiguration struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		ASNumber int `json:"asNumber"`
+	} `json:"spec"`
+}
+
+// calicoNode holds the fields of a Calico Node the integration uses.
+type calicoNode struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		BGP *struct {
+			ASNumber int `json:"asNumber"`
+		} `json:"bgp"`
+	} `json:"spec"`
+}
+
+func init() {
+	registerPeerSource("calico", func(client dynamic.Interface, config *Config) (peerSource, error) {
+		return &calicoPeers{
+			logger:  config.logger,
+			watcher: newCRDWatcher(config.logger, client, calicoBGPConfigurations, calicoNodes),
+		}, nil
+	})
+}
+
+// calicoPeers peers the nodes Calico runs BGP on, with the node ASN, or the
+// ASN of the default BGPConfiguration, as the remote AS.
+type calicoPeers struct {
+	logger  *log.Logger
+	watcher *crdWatcher
+
+	mu sync.Mutex
+	// nodes are the Calico nodes by name, as of the generation of the
+	// watcher
+	nodes      map[string]calicoNode
+	generation uint64
+}
+
+func (c *calicoPeers) start(ctx context.Context) error {
+	return c.watcher.start(ctx)
+}
+
+func (c *calicoPeers) changed() <-chan struct{} {
+	return c.watcher.changed()
+}
+
+func (c *calicoPeers) peer(node *v1.Node) (bool, int, string) {
+	nodes, err := c.nodeIndex()
+	if err != nil {
+		c.logger.Error("Error listing Calico nodes", "error", err)
+		return false, 0, err.Error()
+	}
+	calico, ok := nodes[node.Name]
+	if !ok {
+		return false, 0, "no Calico node found"
+	}
+	if calico.Spec.BGP == nil {
+		return false, 0, "Calico doesn't run BGP on the node"
+	}
+	if calico.Spec.BGP.ASNumber != 0 {
+		return true, calico.Spec.BGP.ASNumber, "Calico node ASN"
+	}
+	asn, err := c.defaultASN()
+	if err != nil {
+		c.logger.Error("Error listing Calico BGP configurations", "error", err)
+		return false, 0, err.Error()
+	}
+	return true, asn, "Calico default ASN"
+}
+
+// nodeIndex returns the Calico nodes by name. The index is rebuilt only
+// after the watched resources changed, so a pass over all the nodes lists
+// the Calico nodes once instead of once per node.
+// Returns an error if the nodes can't be listed.
+func (c *calicoPeers) nodeIndex() (map[string]calicoNode, error) {
+	generation := c.watcher.generation.Load()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.nodes != nil && c.generation == generation {
+		return c.nodes, nil
+	}
+	nodes, err := list[calicoNode](c.watcher, calicoNodes)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]calicoNode, len(nodes))
+	for _, calico := range nodes {
+		index[calico.Name] = calico
+	}
+	c.nodes, c.generation = index, generation
+	return index, nil
+}
+
+// defaultASN returns the ASN of the default BGPConfiguration or the Calico
+// default ASN.
+// Returns an error if the configurations can't be listed.
+func (c *calicoPeers) defaultASN() (int, error) {
+	configurations, err := list[calicoBGPConfiguration](c.watcher, calicoBGPConfigurations)
+	if err != nil {
+		return 0, fmt.Errorf("listing BGP configurations: %w", err)
+	}
+	for _, configuration := range configurations {
+		if configuration.Name == "default" && configuration.Spec.ASNumber != 0 {
+			return configuration.Spec.ASNumber, nil
+		}
+	}
+	return calicoDefaultASN, nil
+}
@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// canaryPollInterval is how often the canary session state is polled.
+const canaryPollInterval = 5 * time.Second
+
+// canary returns the device the neighbor is added to first when fanning
+// out, the first device peered with it. Returns nil if canary apply is
+// disabled, the writes are paused or a single device is peered with it.
+func (d *Devices) canary(neighbor Neighbor) *A10 {
+	if d.canaryTimeout == 0 || d.pause.isPaused() {
+		return nil
+	}
+	var canary *A10
+	for _, a10 := range d.devices {
+		if !a10.selects(neighbor) {
+			continue
+		}
+		if canary != nil {
+			return canary
+		}
+		canary = a10
+	}
+	return nil
+}
+
+// verifyCanary waits for the BGP session of the neighbor to establish on
+// the canary device, alerting if it doesn't so the change isn't rolled to
+// the other devices.
+// Returns an error if the verification fails.
+func (d *Devices) verifyCanary(ctx context.Context, canary *A10, neighbor Neighbor) error {
+	logger := d.logger.With(
+		"device", canary.address,
+		"neighbor", neighbor.IP,
+		"node", neighbor.NodeName,
+		"correlationID", correlationID(ctx),
+	)
+	logger.Info("Verifying the neighbor session on the canary device", "timeout", d.canaryTimeout)
+	if err := canary.waitEstablished(ctx, neighbor.IP, d.canaryTimeout); err != nil {
+		canaryFailures.WithLabelValues(canary.address).Inc()
+		logger.Error("Canary verification failed, not rolling the neighbor out to the other devices", "error", err)
+		err = fmt.Errorf("canary device %s: %w", canary.address, err)
+		if d.rollback {
+			d.rollbackNeighbor(ctx, canary, neighbor, err)
+		}
+		return err
+	}
+	logger.Info("Canary verification passed, rolling the neighbor out to the other devices")
+	return nil
+}
+
+// waitEstablished polls the BGP sessions until the session of the neighbor
+// is established.
+// Returns an error if it isn't established within the timeout.
+func (a *A10) waitEstablished(ctx context.Context, neighborIP string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(canaryPollInterval)
+	defer ticker.Stop()
+	state := "unknown"
+	for {
+		sessions, err := a.GetSessions()
+		if err != nil {
+			a.logger.Warn("Error getting BGP sessions", "device", a.address, "error", err)
+		}
+		for _, session := range sessions {
+			if session.neighbor == neighborIP {
+				state = session.state
+			}
+		}
+		if state == bgpEstablished {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("BGP session not established within %s, last state %s", timeout, state)
+		case <-ticker.C:
+		}
+	}
+}
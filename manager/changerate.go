@@ -0,0 +1,49 @@
+package manager
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// newChangeLimiter creates the limiter capping the neighbor mutations of a
+// device per minute, independently of the API request rate, so flapping
+// nodes can't translate into hundreds of config changes per hour.
+// Returns nil if the limit is 0.
+func newChangeLimiter(perMinute int) *rate.Limiter {
+	if perMinute == 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(float64(perMinute)/time.Minute.Seconds()), perMinute)
+}
+
+// waitChangeBudget waits until the change rate limit of the device allows
+// another mutation. The waiting worker keeps the other changes queued.
+// Returns an error if the context is done while waiting.
+func (a *A10) waitChangeBudget(ctx context.Context) error {
+	if a.changeLimiter == nil {
+		return nil
+	}
+	reservation := a.changeLimiter.Reserve()
+	delay := reservation.Delay()
+	if delay == 0 {
+		return nil
+	}
+	changesRateLimited.WithLabelValues(a.address).Inc()
+	a.logger.Info(
+		"Change rate limit reached, delaying the neighbor change",
+		"device", a.address,
+		"delay", delay.Round(time.Second),
+		"correlationID", correlationID(ctx),
+	)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		reservation.Cancel()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,103 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// changeWindow is a recurring period during which the changes are
+// allowed, starting on a cron schedule.
+type changeWindow struct {
+	start    cron.Schedule
+	duration time.Duration
+}
+
+// changeWindows defer the neighbor changes outside of the maintenance
+// windows of the network team change-control rules. Removals are always
+// deferred, additions only if they aren't allowed outside the windows.
+type changeWindows struct {
+	raw     string
+	windows []changeWindow
+	// allowAdditions applies the additions outside the windows
+	allowAdditions bool
+}
+
+// parseChangeWindows parses semicolon-separated windows, each a standard
+// cron expression of the window start followed by the window duration,
+// e.g. "0 22 * * 1-5 4h;0 10 * * 6 2h". The expressions may be prefixed with
+// CRON_TZ=<zone>, UTC by default.
+// Returns nil if the windows are empty.
+// Returns an error if a window is invalid.
+func parseChangeWindows(raw string, allowAdditions bool) (*changeWindows, error) {
+	windows := &changeWindows{raw: raw, allowAdditions: allowAdditions}
+	for _, part := range strings.Split(raw, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		i := strings.LastIndex(part, " ")
+		if i < 0 {
+			return nil, fmt.Errorf("window %q must be a cron expression and a duration", part)
+		}
+		duration, err := time.ParseDuration(part[i+1:])
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("window %q duration must be a positive duration", part)
+		}
+		spec := strings.TrimSpace(part[:i])
+		if !strings.HasPrefix(spec, "CRON_TZ=") && !strings.HasPrefix(spec, "TZ=") {
+			spec = "CRON_TZ=UTC " + spec
+		}
+		start, err := cron.ParseStandard(spec)
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %w", part, err)
+		}
+		windows.windows = append(windows.windows, changeWindow{start: start, duration: duration})
+	}
+	if len(windows.windows) == 0 {
+		return nil, nil
+	}
+	return windows, nil
+}
+
+// open checks if a window is open at the time.
+func (w changeWindow) open(t time.Time) bool {
+	// the window is open if it started within its duration before
+	return !w.start.Next(t.Add(-w.duration)).After(t)
+}
+
+// allows checks if the change can be applied now.
+func (c *changeWindows) allows(present bool) bool {
+	if c == nil || (present && c.allowAdditions) {
+		return true
+	}
+	now := time.Now()
+	for _, window := range c.windows {
+		if window.open(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// untilOpen returns the time until the next window opens.
+func (c *changeWindows) untilOpen() time.Duration {
+	now := time.Now()
+	var next time.Time
+	for _, window := range c.windows {
+		if start := window.start.Next(now); next.IsZero() || start.Before(next) {
+			next = start
+		}
+	}
+	return max(time.Until(next), time.Second)
+}
+
+// String returns the windows as configured.
+func (c *changeWindows) String() string {
+	if c == nil {
+		return ""
+	}
+	return c.raw
+}
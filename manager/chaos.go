@@ -0,0 +1,165 @@
+package manager
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// chaosFault is a failure injected into the device requests.
+type chaosFault string
+
+const (
+	// faultTimeout holds the request until the client times out
+	faultTimeout chaosFault = "timeout"
+	// faultUnauthorized rejects the session with 401
+	faultUnauthorized chaosFault = "unauthorized"
+	// faultServerError fails the request with 500, 502 or 503
+	faultServerError chaosFault = "error"
+	// faultSlow delays the request by the chaos latency
+	faultSlow chaosFault = "slow"
+
+	defaultChaosLatency = 5 * time.Second
+)
+
+// chaosFaults are all the faults, injected by default.
+var chaosFaults = []chaosFault{faultTimeout, faultUnauthorized, faultServerError, faultSlow}
+
+// chaosServerErrors are the status codes of the server error fault.
+var chaosServerErrors = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+}
+
+// chaosInjector injects random failures into the aXAPI requests, so the
+// operators can validate the retries, the backoff and the alerts in staging
+// before production. A nil injector injects nothing.
+type chaosInjector struct {
+	// rate is the share of the requests failed, in (0, 1]
+	rate    float64
+	faults  []chaosFault
+	latency time.Duration
+}
+
+// parseChaos parses the failure rate, the comma-separated faults, all if
+// empty, and the latency of the slow fault.
+// Returns nil if the rate is empty or 0, and an error if a value is
+// invalid.
+func parseChaos(rate, faults, latency string) (*chaosInjector, error) {
+	if rate == "" {
+		return nil, nil
+	}
+	c := &chaosInjector{latency: defaultChaosLatency}
+	var err error
+	c.rate, err = strconv.ParseFloat(rate, 64)
+	if err != nil || c.rate < 0 || c.rate > 1 {
+		return nil, fmt.Errorf("failure rate must be a number in [0, 1], got %q", rate)
+	}
+	if c.rate == 0 {
+		return nil, nil
+	}
+	for _, name := range strings.Split(faults, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if !slices.Contains(chaosFaults, chaosFault(name)) {
+			return nil, fmt.Errorf("unknown fault %q, expected timeout, unauthorized, error or slow", name)
+		}
+		c.faults = append(c.faults, chaosFault(name))
+	}
+	if len(c.faults) == 0 {
+		c.faults = chaosFaults
+	}
+	if latency != "" {
+		c.latency, err = time.ParseDuration(latency)
+		if err != nil || c.latency <= 0 {
+			return nil, fmt.Errorf("latency must be a positive duration, got %q", latency)
+		}
+	}
+	return c, nil
+}
+
+// String lists the rate and the faults for the logs.
+func (c *chaosInjector) String() string {
+	if c == nil {
+		return "off"
+	}
+	return fmt.Sprintf("%g %v", c.rate, c.faults)
+}
+
+// wrap returns the transport injecting the faults into the requests of
+// the device, or the transport itself if the injector is nil.
+func (c *chaosInjector) wrap(logger *log.Logger, device string, next http.RoundTripper) http.RoundTripper {
+	if c == nil {
+		return next
+	}
+	return &chaosTransport{logger: logger, chaos: c, device: device, next: next}
+}
+
+// chaosTransport injects the faults into the requests of a device.
+type chaosTransport struct {
+	logger *log.Logger
+	chaos  *chaosInjector
+	device string
+	next   http.RoundTripper
+}
+
+// RoundTrip makes the request, or fails it with a random fault at the
+// failure rate.
+func (t *chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rand.Float64() >= t.chaos.rate {
+		return t.next.RoundTrip(req)
+	}
+	fault := t.chaos.faults[rand.N(len(t.chaos.faults))]
+	chaosFaultsInjected.WithLabelValues(t.device, string(fault)).Inc()
+	t.logger.Debug(
+		"Injecting chaos fault into A10 request",
+		"device", t.device,
+		"fault", fault,
+		"method", req.Method,
+		"url", req.URL.Path,
+	)
+	switch fault {
+	case faultTimeout:
+		<-req.Context().Done()
+		return nil, fmt.Errorf("chaos: injected timeout: %w", req.Context().Err())
+	case faultUnauthorized:
+		return chaosResponse(req, http.StatusUnauthorized), nil
+	case faultServerError:
+		return chaosResponse(req, chaosServerErrors[rand.N(len(chaosServerErrors))]), nil
+	default:
+		timer := time.NewTimer(t.chaos.latency)
+		defer timer.Stop()
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		return t.next.RoundTrip(req)
+	}
+}
+
+// chaosResponse returns an error response of the status code to the
+// request.
+func chaosResponse(req *http.Request, statusCode int) *http.Response {
+	body := fmt.Sprintf(`{"response": {"status": "fail", "err": {"msg": "chaos: injected %d"}}}`, statusCode)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
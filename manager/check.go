@@ -0,0 +1,178 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// bgpRouterEndpoint is the aXAPI endpoint of the BGP process.
+const bgpRouterEndpoint = "/axapi/v3/router/bgp/%d"
+
+// checkReport prints the pass/fail results of the check command.
+type checkReport struct {
+	failed bool
+}
+
+// result prints the result of a check.
+func (r *checkReport) result(name string, err error) {
+	if err != nil {
+		r.failed = true
+		fmt.Printf("FAIL  %s: %v\n", name, err)
+		return
+	}
+	fmt.Printf("PASS  %s\n", name)
+}
+
+// skip prints a check that can't run because a previous one failed.
+func (r *checkReport) skip(name, reason string) {
+	fmt.Printf("SKIP  %s: %s\n", name, reason)
+}
+
+// runCheck validates the configuration, the Kubernetes connectivity and
+// RBAC, and the connectivity, authentication and BGP process of every
+// device, printing a pass/fail report, e.g. for install pipelines before
+// enabling the controller. It makes no changes.
+// Returns the exit code, 1 if any check failed.
+func runCheck(ctx context.Context, logger *log.Logger) int {
+	report := &checkReport{}
+
+	config := Config{logger: logger}
+	err := config.Get()
+	if err == nil {
+		_, err = newEligibilityChecks(config.EligibilityChecks, &config, &nodeState{logger: logger})
+	}
+	report.result("configuration", err)
+	if err != nil {
+		report.skip("kubernetes", "invalid configuration")
+		report.skip("a10", "invalid configuration")
+		return 1
+	}
+	checkKubernetes(ctx, report)
+	checkDevices(ctx, report, &config)
+
+	if report.failed {
+		return 1
+	}
+	return 0
+}
+
+// checkKubernetes checks the API server is reachable and the controller
+// can list and watch the nodes.
+func checkKubernetes(ctx context.Context, report *checkReport) {
+	clientset, err := checkKubernetesConnectivity()
+	report.result("kubernetes connectivity", err)
+	if err != nil {
+		report.skip("kubernetes RBAC", "no connection")
+		return
+	}
+	for _, verb := range []string{"list", "watch"} {
+		report.result("kubernetes RBAC: "+verb+" nodes", checkNodeAccess(ctx, clientset, verb))
+	}
+}
+
+// checkKubernetesConnectivity connects to the API server.
+// Returns an error if it's unreachable.
+func checkKubernetesConnectivity() (*kubernetes.Clientset, error) {
+	kubeConfig, err := getKubernetesConfig()
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := getKubernetesClient(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := clientset.Discovery().ServerVersion(); err != nil {
+		return nil, fmt.Errorf("getting server version: %w", err)
+	}
+	return clientset, nil
+}
+
+// checkNodeAccess checks the controller is allowed the verb on the nodes.
+// Returns an error if it's denied.
+func checkNodeAccess(ctx context.Context, clientset kubernetes.Interface, verb string) error {
+	review, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(
+		ctx,
+		&authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:     verb,
+					Resource: "nodes",
+				},
+			},
+		},
+		metav1.CreateOptions{},
+	)
+	if err != nil {
+		return fmt.Errorf("reviewing access: %w", err)
+	}
+	if !review.Status.Allowed {
+		return fmt.Errorf("denied: %s", review.Status.Reason)
+	}
+	return nil
+}
+
+// checkDevices checks every device is reachable, accepts the credentials
+// and runs the BGP process of the configured AS.
+func checkDevices(ctx context.Context, report *checkReport, config *Config) {
+	provider, err := newCredentialsProvider(ctx, config)
+	var creds Credentials
+	if err == nil && sharedCredentials(config.Devices) {
+		creds, err = provider.Credentials(ctx)
+	}
+	report.result("a10 credentials", err)
+	if err != nil {
+		report.skip("a10 devices", "no credentials")
+		return
+	}
+
+	for _, device := range config.Devices {
+		name := "a10 " + device.address
+		deviceCreds := creds
+		if device.credentials != nil {
+			deviceCreds, err = device.credentials.Credentials(ctx)
+			report.result(name+": credentials", err)
+			if err != nil {
+				continue
+			}
+		}
+		a10 := newA10(ctx, device, deviceCreds, config)
+		if a10.backend != nil {
+			_, err := a10.backend.neighbors(ctx)
+			report.result(fmt.Sprintf("%s: login and BGP process AS %d", name, device.as), err)
+			continue
+		}
+		err := a10.login()
+		report.result(name+": login", err)
+		if err != nil {
+			report.skip(name+": BGP process", "not logged in")
+			continue
+		}
+		report.result(fmt.Sprintf("%s: BGP process AS %d", name, device.as), a10.checkBGPProcess())
+	}
+}
+
+// checkBGPProcess checks the BGP process of the device AS exists.
+// Returns an error if it doesn't or the request fails.
+func (a *A10) checkBGPProcess() error {
+	url := fmt.Sprintf("%s%s", a.address, fmt.Sprintf(bgpRouterEndpoint, a.as))
+	_, err := a.sessionRequest(a.ctx, "GET", url, nil, retryIdempotent)
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("no BGP process (router bgp %d) on the device", a.as)
+	}
+	return err
+}
+
+// checkCommand is the name of the subcommand running the check.
+const checkCommand = "check"
+
+// isCheckCommand checks if the check subcommand was requested.
+func isCheckCommand() bool {
+	return len(os.Args) > 1 && os.Args[1] == checkCommand
+}
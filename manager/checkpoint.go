@@ -0,0 +1,267 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// writeMemoryEndpoint saves the running configuration of the device.
+const writeMemoryEndpoint = "/axapi/v3/write/memory"
+
+// checkpointNeighbor is a neighbor of a checkpoint with its full
+// configuration, so it can be recreated as it was.
+type checkpointNeighbor struct {
+	remoteAS int
+	raw      json.RawMessage
+}
+
+// batchCheckpoint is the neighbor configuration of the devices before a
+// batch of changes and the progress of the batch.
+type batchCheckpoint struct {
+	logger  *log.Logger
+	taken   time.Time
+	devices map[*A10]map[string]checkpointNeighbor
+	// pending are the neighbors of the batch still being applied
+	pending map[string]struct{}
+	// failed are the neighbors of the batch the queue gave up on
+	failed []string
+}
+
+// checkpointer checkpoints the devices before a batch of changes, so a
+// batch failing midway can be restored instead of leaving a partial apply.
+// Batches starting while another is applied join it and share its
+// checkpoint. It is safe for concurrent use.
+type checkpointer struct {
+	logger *log.Logger
+	// profile is the startup-config profile the running configuration is
+	// saved to with every checkpoint, not saved if empty
+	profile string
+	// autoRestore restores the checkpoint when the batch fails, otherwise
+	// it's kept for restoreFailed
+	autoRestore bool
+
+	mu      sync.Mutex
+	current *batchCheckpoint
+	// lastFailed is the checkpoint of the last failed batch not restored
+	lastFailed *batchCheckpoint
+}
+
+// begin takes the checkpoint of the devices before the batch of the
+// neighbors, or adds them to the batch being applied.
+func (c *checkpointer) begin(devices []*A10, neighbors map[string]struct{}) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current != nil {
+		for neighborIP := range neighbors {
+			c.current.pending[neighborIP] = struct{}{}
+		}
+		return
+	}
+
+	checkpoint := &batchCheckpoint{
+		logger:  c.logger,
+		taken:   time.Now(),
+		devices: map[*A10]map[string]checkpointNeighbor{},
+		pending: map[string]struct{}{},
+	}
+	for neighborIP := range neighbors {
+		checkpoint.pending[neighborIP] = struct{}{}
+	}
+	for _, a10 := range devices {
+		if c.profile != "" {
+			if err := a10.writeMemory(c.profile); err != nil {
+				c.logger.Error("Error saving A10 configuration", "device", a10.address, "profile", c.profile, "error", err)
+			}
+		}
+		saved, err := a10.checkpointNeighbors()
+		if err != nil {
+			checkpointFailures.WithLabelValues(a10.address).Inc()
+			c.logger.Error("Error checkpointing A10 neighbors, the batch can't be restored", "device", a10.address, "error", err)
+			continue
+		}
+		checkpoint.devices[a10] = saved
+	}
+	c.current = checkpoint
+	c.logger.Info("Checkpointed A10 neighbors before batch", "neighbors", len(neighbors), "devices", len(checkpoint.devices))
+}
+
+// finish records the result of the change of the neighbor. When the last
+// change of the batch is done and some failed, the checkpoint is restored
+// or kept for restoreFailed.
+func (c *checkpointer) finish(ctx context.Context, neighborIP string, err error) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	checkpoint := c.current
+	if checkpoint == nil {
+		c.mu.Unlock()
+		return
+	}
+	if _, ok := checkpoint.pending[neighborIP]; !ok {
+		c.mu.Unlock()
+		return
+	}
+	delete(checkpoint.pending, neighborIP)
+	if err != nil {
+		checkpoint.failed = append(checkpoint.failed, neighborIP)
+	}
+	if len(checkpoint.pending) > 0 {
+		c.mu.Unlock()
+		return
+	}
+	c.current = nil
+	if len(checkpoint.failed) == 0 {
+		c.mu.Unlock()
+		c.logger.Debug("Batch applied, discarding checkpoint")
+		return
+	}
+	if !c.autoRestore {
+		c.lastFailed = checkpoint
+		c.mu.Unlock()
+		c.logger.Error(
+			"Batch failed midway, POST /checkpoint/restore to restore the checkpoint",
+			"failed", checkpoint.failed,
+			"checkpoint", checkpoint.taken,
+		)
+		return
+	}
+	c.mu.Unlock()
+	c.logger.Error("Batch failed midway, restoring the checkpoint", "failed", checkpoint.failed)
+	if err := checkpoint.restore(ctx); err != nil {
+		c.logger.Error("Error restoring the checkpoint", "error", err)
+	}
+}
+
+// restoreFailed restores the checkpoint of the last failed batch.
+// Returns an error if there is no such checkpoint or the restore fails.
+func (c *checkpointer) restoreFailed(ctx context.Context) error {
+	if c == nil {
+		return fmt.Errorf("checkpoints are disabled")
+	}
+	c.mu.Lock()
+	checkpoint := c.lastFailed
+	c.lastFailed = nil
+	c.mu.Unlock()
+	if checkpoint == nil {
+		return fmt.Errorf("no failed batch to restore")
+	}
+	c.logger.Warn("Restoring the checkpoint of the failed batch", "checkpoint", checkpoint.taken)
+	return checkpoint.restore(ctx)
+}
+
+// restore restores the neighbors of every checkpointed device.
+// Returns the joined errors of the devices that failed.
+func (b *batchCheckpoint) restore(ctx context.Context) error {
+	var errs []error
+	for a10, saved := range b.devices {
+		if err := a10.restoreNeighbors(ctx, saved); err != nil {
+			errs = append(errs, fmt.Errorf("device %s: %w", a10.address, err))
+			continue
+		}
+		checkpointRestores.WithLabelValues(a10.address).Inc()
+		b.logger.Info("Restored A10 neighbors from checkpoint", "device", a10.address, "checkpoint", b.taken)
+	}
+	return errors.Join(errs...)
+}
+
+// writeMemory saves the running configuration to the startup-config
+// profile.
+// Returns an error if the operation fails.
+func (a *A10) writeMemory(profile string) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"memory": map[string]string{"profile": profile},
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling request data: %w", err)
+	}
+	url := a.address + writeMemoryEndpoint
+	if _, err := a.sessionRequest(a.ctx, "POST", url, data, retryIdempotent); err != nil {
+		return fmt.Errorf("writing memory: %w", err)
+	}
+	return nil
+}
+
+// checkpointNeighbors gets the neighbors of the device with their full
+// configuration.
+// Returns an error if the operation fails.
+func (a *A10) checkpointNeighbors() (map[string]checkpointNeighbor, error) {
+	url := fmt.Sprintf("%s%s", a.address, fmt.Sprintf(bgpEndpoint, a.as))
+	body, err := a.sessionRequest(a.ctx, "GET", url, nil, retryIdempotent)
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		Ipv4NeighborList []json.RawMessage `json:"ipv4-neighbor-list"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("unmarshaling JSON from A10 to get neighbors: %w", err)
+	}
+	saved := make(map[string]checkpointNeighbor, len(response.Ipv4NeighborList))
+	for _, raw := range response.Ipv4NeighborList {
+		var neighbor ipv4Neighbor
+		if err := json.Unmarshal(raw, &neighbor); err != nil {
+			return nil, fmt.Errorf("unmarshaling neighbor: %w", err)
+		}
+		if neighbor.NeighborIPV4 == "" {
+			continue
+		}
+		saved[neighbor.NeighborIPV4] = checkpointNeighbor{remoteAS: neighbor.RemoteAS, raw: raw}
+	}
+	return saved, nil
+}
+
+// restoreNeighbors makes the managed neighbors of the device match the
+// checkpoint: the neighbors created since are deleted and the deleted ones
+// are recreated with their checkpointed configuration. Protected and
+// unmanaged neighbors are left alone.
+// Returns an error if the operation fails.
+func (a *A10) restoreNeighbors(ctx context.Context, saved map[string]checkpointNeighbor) error {
+	current, err := a.fetchNeighbors()
+	if err != nil {
+		return fmt.Errorf("getting neighbors: %w", err)
+	}
+	var errs []error
+	for _, neighbor := range current {
+		if _, ok := saved[neighbor.NeighborIPV4]; ok ||
+			a.protected.contains(neighbor.NeighborIPV4) || !a.managesAS(neighbor.RemoteAS) {
+			continue
+		}
+		a.logger.Info("Deleting neighbor created since the checkpoint", "device", a.address, "neighbor", neighbor.NeighborIPV4)
+		if err := a.deleteNeighbor(ctx, neighbor.NeighborIPV4); err != nil {
+			errs = append(errs, fmt.Errorf("deleting neighbor %s: %w", neighbor.NeighborIPV4, err))
+		}
+	}
+	onDevice := make(map[string]struct{}, len(current))
+	for _, neighbor := range current {
+		onDevice[neighbor.NeighborIPV4] = struct{}{}
+	}
+	url := fmt.Sprintf("%s%s", a.address, fmt.Sprintf(bgpEndpoint, a.as))
+	for neighborIP, neighbor := range saved {
+		if _, ok := onDevice[neighborIP]; ok ||
+			a.protected.contains(neighborIP) || !a.managesAS(neighbor.remoteAS) {
+			continue
+		}
+		a.logger.Info("Recreating neighbor deleted since the checkpoint", "device", a.address, "neighbor", neighborIP)
+		data, err := json.Marshal(map[string]json.RawMessage{"ipv4-neighbor": neighbor.raw})
+		if err != nil {
+			return fmt.Errorf("marshaling request data: %w", err)
+		}
+		if _, err := a.sessionRequest(ctx, "POST", url, data, a.retryCreate(ctx, neighborIP)); err != nil {
+			errs = append(errs, fmt.Errorf("recreating neighbor %s: %w", neighborIP, err))
+		}
+	}
+	if err := a.GetNeighbors(); err != nil {
+		errs = append(errs, fmt.Errorf("refreshing neighbors: %w", err))
+	}
+	return errors.Join(errs...)
+}
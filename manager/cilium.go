@@ -0,0 +1,158 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	ciliumBGPPeeringPolicies = schema.GroupVersionResource{
+		Group:    "cilium.io",
+		Version:  "v2alpha1",
+		Resource: "ciliumbgppeeringpolicies",
+	}
+	ciliumBGPClusterConfigsV2alpha1 = schema.GroupVersionResource{
+		Group:    "cilium.io",
+		Version:  "v2alpha1",
+		Resource: "ciliumbgpclusterconfigs",
+	}
+	ciliumBGPClusterConfigsV2 = schema.GroupVersionResource{
+		Group:    "cilium.io",
+		Version:  "v2",
+		Resource: "ciliumbgpclusterconfigs",
+	}
+)
+
+// ciliumBGPPeeringPolicy holds the fields of a CiliumBGPPeeringPolicy, the
+// BGP control plane v1 API, the integration uses.
+type ciliumBGPPeeringPolicy struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		NodeSelector   *metav1.LabelSelector `json:"nodeSelector"`
+		VirtualRouters []struct {
+			LocalASN  int `json:"localASN"`
+			Neighbors []struct {
+				PeerASN int `json:"peerASN"`
+			} `json:"neighbors"`
+		} `json:"virtualRouters"`
+	} `json:"spec"`
+}
+
+// ciliumBGPClusterConfig holds the fields of a CiliumBGPClusterConfig, the
+// BGP control plane v2 API, the integration uses.
+type ciliumBGPClusterConfig struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		NodeSelector *metav1.LabelSelector `json:"nodeSelector"`
+		BGPInstances []struct {
+			LocalASN *int `json:"localASN"`
+			Peers    []struct {
+				PeerASN *int `json:"peerASN"`
+			} `json:"peers"`
+		} `json:"bgpInstances"`
+	} `json:"spec"`
+}
+
+func init() {
+	registerPeerSource("cilium", func(client dynamic.Interface, config *Config) (peerSource, error) {
+		return &ciliumPeers{
+			logger: config.logger,
+			watcher: newCRDWatcher(config.logger, client).withOptional(
+				ciliumBGPPeeringPolicies,
+				ciliumBGPClusterConfigsV2alpha1,
+				ciliumBGPClusterConfigsV2,
+			),
+			as: config.AS,
+		}, nil
+	})
+}
+
+// ciliumPeers peers the nodes selected by a Cilium BGP peering policy or
+// cluster config with a virtual router or BGP instance peering the device
+// AS. The neighbor remote AS is the local ASN of the router or instance.
+// Both the v1 and the v2 BGP control plane APIs are supported, whichever
+// the cluster serves.
+type ciliumPeers struct {
+	logger  *log.Logger
+	watcher *crdWatcher
+	as      int
+}
+
+func (c *ciliumPeers) start(ctx context.Context) error {
+	return c.watcher.start(ctx)
+}
+
+func (c *ciliumPeers) changed() <-chan struct{} {
+	return c.watcher.changed()
+}
+
+func (c *ciliumPeers) peer(node *v1.Node) (bool, int, string) {
+	policies, err := list[ciliumBGPPeeringPolicy](c.watcher, ciliumBGPPeeringPolicies)
+	if err != nil {
+		c.logger.Error("Error listing Cilium BGP peering policies", "error", err)
+		return false, 0, err.Error()
+	}
+	for _, policy := range policies {
+		if !c.selects(node, policy.Spec.NodeSelector, "CiliumBGPPeeringPolicy", policy.Name) {
+			continue
+		}
+		for _, router := range policy.Spec.VirtualRouters {
+			for _, neighbor := range router.Neighbors {
+				if neighbor.PeerASN == c.as {
+					return true, router.LocalASN, fmt.Sprintf(
+						"selected by CiliumBGPPeeringPolicy %s", policy.Name,
+					)
+				}
+			}
+		}
+	}
+
+	for _, resource := range []schema.GroupVersionResource{
+		ciliumBGPClusterConfigsV2,
+		ciliumBGPClusterConfigsV2alpha1,
+	} {
+		configs, err := list[ciliumBGPClusterConfig](c.watcher, resource)
+		if err != nil {
+			c.logger.Error("Error listing Cilium BGP cluster configs", "error", err)
+			return false, 0, err.Error()
+		}
+		for _, config := range configs {
+			if !c.selects(node, config.Spec.NodeSelector, "CiliumBGPClusterConfig", config.Name) {
+				continue
+			}
+			for _, instance := range config.Spec.BGPInstances {
+				if instance.LocalASN == nil {
+					continue
+				}
+				for _, peer := range instance.Peers {
+					if peer.PeerASN != nil && *peer.PeerASN == c.as {
+						return true, *instance.LocalASN, fmt.Sprintf(
+							"selected by CiliumBGPClusterConfig %s", config.Name,
+						)
+					}
+				}
+			}
+		}
+	}
+	return false, 0, fmt.Sprintf("no Cilium BGP configuration peers the node with ASN %d", c.as)
+}
+
+// selects checks if the node selector of the resource selects the node.
+// A missing selector selects every node.
+func (c *ciliumPeers) selects(node *v1.Node, selector *metav1.LabelSelector, kind, name string) bool {
+	if selector == nil {
+		return true
+	}
+	selected, err := selectedBy(node, []metav1.LabelSelector{*selector})
+	if err != nil {
+		c.logger.Warn("Invalid Cilium node selector", "kind", kind, "name", name, "error", err)
+		return false
+	}
+	return selected
+}
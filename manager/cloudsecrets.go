@@ -0,0 +1,167 @@
+package manager
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+const (
+	gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	gcpSecretAccessURL  = "https://secretmanager.googleapis.com/v1/%s:access"
+)
+
+// secretCredentials is the JSON format of the credentials stored in cloud
+// secret managers.
+type secretCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// parseSecretCredentials parses the credentials from a secret value.
+// Returns an error if the value isn't valid or misses a key.
+func parseSecretCredentials(data []byte) (Credentials, error) {
+	var secret secretCredentials
+	if err := json.Unmarshal(data, &secret); err != nil {
+		return Credentials{}, fmt.Errorf("unmarshaling secret JSON: %w", err)
+	}
+	if secret.Username == "" || secret.Password == "" {
+		return Credentials{}, fmt.Errorf("secret must have username and password keys")
+	}
+	return Credentials{Username: secret.Username, Password: Secret(secret.Password)}, nil
+}
+
+// awsSecretCredentials resolves the A10 credentials from an AWS Secrets
+// Manager secret. AWS credentials and region come from the default chain,
+// e.g. IRSA or the AWS_* environment variables.
+type awsSecretCredentials struct {
+	secretID string
+	client   *secretsmanager.Client
+}
+
+// newAWSSecretCredentials creates the AWS Secrets Manager provider.
+// Returns an error if the AWS config can't be loaded.
+func newAWSSecretCredentials(ctx context.Context, config *Config) (*awsSecretCredentials, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+	return &awsSecretCredentials{
+		secretID: config.AWSSecretID,
+		client:   secretsmanager.NewFromConfig(cfg),
+	}, nil
+}
+
+// Credentials reads the credentials from the AWS secret.
+// Returns an error if the operation fails.
+func (a *awsSecretCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	out, err := a.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: &a.secretID,
+	})
+	if err != nil {
+		return Credentials{}, fmt.Errorf("getting AWS secret %s: %w", a.secretID, err)
+	}
+	var data []byte
+	if out.SecretString != nil {
+		data = []byte(*out.SecretString)
+	} else {
+		data = out.SecretBinary
+	}
+	creds, err := parseSecretCredentials(data)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("parsing AWS secret %s: %w", a.secretID, err)
+	}
+	return creds, nil
+}
+
+// gcpSecretCredentials resolves the A10 credentials from a GCP Secret
+// Manager secret version using the access token of the workload service
+// account from the metadata server.
+type gcpSecretCredentials struct {
+	name string
+	gcpClient
+}
+
+// gcpClient calls the GCP APIs with the access token of the workload
+// service account.
+type gcpClient struct {
+	client *http.Client
+}
+
+// Credentials reads the credentials from the GCP secret.
+// Returns an error if the operation fails.
+func (g *gcpSecretCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	token, err := g.accessToken(ctx)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("getting GCP access token: %w", err)
+	}
+
+	var response struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	header := http.Header{"Authorization": {fmt.Sprintf("Bearer %s", token.Reveal())}}
+	if err := g.get(ctx, fmt.Sprintf(gcpSecretAccessURL, g.name), header, &response); err != nil {
+		return Credentials{}, fmt.Errorf("accessing GCP secret %s: %w", g.name, err)
+	}
+	data, err := base64.StdEncoding.DecodeString(response.Payload.Data)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("decoding GCP secret %s: %w", g.name, err)
+	}
+	creds, err := parseSecretCredentials(data)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("parsing GCP secret %s: %w", g.name, err)
+	}
+	return creds, nil
+}
+
+// accessToken gets an access token from the GCP metadata server.
+// Returns an error if the operation fails.
+func (g gcpClient) accessToken(ctx context.Context) (Secret, error) {
+	var response struct {
+		AccessToken string `json:"access_token"`
+	}
+	header := http.Header{"Metadata-Flavor": {"Google"}}
+	if err := g.get(ctx, gcpMetadataTokenURL, header, &response); err != nil {
+		return "", err
+	}
+	return Secret(response.AccessToken), nil
+}
+
+// get makes a GET request and unmarshals the JSON response.
+// Returns an error if the operation fails.
+func (g gcpClient) get(
+	ctx context.Context,
+	url string,
+	header http.Header,
+	v interface{},
+) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header = header
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request failed: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %w", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("unmarshaling JSON: %w", err)
+	}
+	return nil
+}
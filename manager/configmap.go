@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// configMapRef references a ConfigMap operators use to control the
+// controller at runtime.
+type configMapRef struct {
+	namespace, name string
+}
+
+// parseConfigMapRef parses a "namespace/name" reference. Without a
+// namespace, the controller namespace is used.
+// Returns an error if the reference is incomplete.
+func parseConfigMapRef(ref, defaultNamespace string) (configMapRef, error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		namespace, name = defaultNamespace, ref
+	}
+	if namespace == "" || name == "" {
+		return configMapRef{}, fmt.Errorf("must be namespace/name, got %q", ref)
+	}
+	return configMapRef{namespace: namespace, name: name}, nil
+}
+
+// String returns the reference as "namespace/name".
+func (r configMapRef) String() string {
+	return r.namespace + "/" + r.name
+}
+
+// watch follows the data of the ConfigMap, nil when it doesn't exist, and
+// waits for its initial state.
+// Returns an error if the cache doesn't sync.
+func (r configMapRef) watch(
+	ctx context.Context,
+	clientset kubernetes.Interface,
+	follow func(data map[string]string),
+) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		clientset,
+		10*time.Minute,
+		informers.WithNamespace(r.namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", r.name).String()
+		}),
+	)
+	informer := factory.Core().V1().ConfigMaps().Informer()
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { follow(obj.(*v1.ConfigMap).Data) },
+		UpdateFunc: func(_, obj interface{}) { follow(obj.(*v1.ConfigMap).Data) },
+		DeleteFunc: func(_ interface{}) { follow(nil) },
+	}); err != nil {
+		return fmt.Errorf("adding ConfigMap %s event handler: %w", r, err)
+	}
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return fmt.Errorf("timed out waiting for the ConfigMap %s cache to sync", r)
+	}
+	return nil
+}
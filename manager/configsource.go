@@ -0,0 +1,191 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// configFileKey is the environment variable of the config file
+	configFileKey = "CONFIG_FILE"
+	// configFileFlag is the flag of the config file, overriding CONFIG_FILE
+	configFileFlag = "config"
+)
+
+// listSeparators are the separators of the settings whose items aren't
+// separated by commas, since a comma is part of an item, e.g. the
+// requirements of a label selector.
+var listSeparators = map[string]string{
+	"NODES_LABEL_SELECTOR":    ";",
+	"A10_REMOTE_AS_SELECTORS": ";",
+	"CHANGE_WINDOWS":          ";",
+}
+
+// configSource looks the configuration keys up in layers, each overriding
+// the previous one: the defaults of Config.Get, the config file, the
+// environment and the flags. The keys are the environment variable names,
+// e.g. A10_ADDRESS, and are written as a10-address in flags and either way
+// in the config file. It is safe for concurrent use.
+type configSource struct {
+	file  map[string]string
+	flags map[string]string
+	env   func(string) string
+
+	mu sync.Mutex
+	// used are the keys looked up
+	used map[string]bool
+}
+
+// loadConfigSource loads the flags of the arguments and the config file they
+// or CONFIG_FILE point to. The check subcommand and --validate-config are
+// not configuration keys.
+// Returns an error if a flag or the config file is invalid.
+func loadConfigSource(args []string) (*configSource, error) {
+	s := &configSource{
+		file:  map[string]string{},
+		flags: map[string]string{},
+		env:   os.Getenv,
+		used:  map[string]bool{},
+	}
+	if len(args) > 0 && args[0] == checkCommand {
+		args = args[1:]
+	}
+	path := s.env(configFileKey)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == validateConfigFlag {
+			continue
+		}
+		name, ok := strings.CutPrefix(arg, "--")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("unexpected argument %q", arg)
+		}
+		name, value, hasValue := strings.Cut(name, "=")
+		if !hasValue {
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+				i++
+				value = args[i]
+			} else {
+				value = "true"
+			}
+		}
+		if name == configFileFlag {
+			path = value
+			continue
+		}
+		s.flags[configKey(name)] = value
+	}
+	if path != "" {
+		if err := s.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// loadFile loads the keys of the YAML config file. Lists are joined with
+// the separator of their setting, a comma by default, and objects are
+// encoded as JSON, like their environment variables.
+// Returns an error if the file is invalid.
+func (s *configSource) loadFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	var file map[string]interface{}
+	if err := yaml.Unmarshal(raw, &file); err != nil {
+		return fmt.Errorf("parsing config file: %w", err)
+	}
+	for key, value := range file {
+		separator, ok := listSeparators[configKey(key)]
+		if !ok {
+			separator = ","
+		}
+		s.file[configKey(key)], err = configValue(value, separator)
+		if err != nil {
+			return fmt.Errorf("config file key %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// configKey normalizes a flag or config file key to its environment
+// variable name.
+func configKey(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// configValue formats a config file value like its environment variable,
+// joining the lists with the separator.
+// Returns an error if the value can't be formatted.
+func configValue(value interface{}, separator string) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			formatted, err := configValue(item, separator)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, formatted)
+		}
+		return strings.Join(items, separator), nil
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(encoded), nil
+	}
+}
+
+// get returns the value of the key from the topmost layer setting it, or an
+// empty string for the default.
+func (s *configSource) get(key string) string {
+	s.mu.Lock()
+	s.used[key] = true
+	s.mu.Unlock()
+	if value, ok := s.flags[key]; ok {
+		return value
+	}
+	if value := s.env(key); value != "" {
+		return value
+	}
+	return s.file[key]
+}
+
+// unused returns the keys of the flags and the config file that were never
+// looked up, usually typos or settings of disabled features.
+func (s *configSource) unused() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key := range maps.Keys(s.flags) {
+		if !s.used[key] {
+			keys = append(keys, key)
+		}
+	}
+	for key := range maps.Keys(s.file) {
+		if !s.used[key] && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
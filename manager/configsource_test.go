@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigFileLists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := `
+nodes-label-selector: [bgp=cilium, "pool=edge,zone=a"]
+A10_REMOTE_AS_SELECTORS: ["pool=a:64601", "pool=b:64602"]
+change-windows: ["0 22 * * 1-5 4h", "0 10 * * 6 2h"]
+a10-static-neighbors: [10.1.1.1, 10.1.1.2]
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	source, err := loadConfigSource([]string{"--config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"NODES_LABEL_SELECTOR", "bgp=cilium;pool=edge,zone=a"},
+		{"A10_REMOTE_AS_SELECTORS", "pool=a:64601;pool=b:64602"},
+		{"CHANGE_WINDOWS", "0 22 * * 1-5 4h;0 10 * * 6 2h"},
+		{"A10_STATIC_NEIGHBORS", "10.1.1.1,10.1.1.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := source.get(tt.key); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("selectors are a union", func(t *testing.T) {
+		selector, err := parseNodeSelector(source.get("NODES_LABEL_SELECTOR"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes := []struct {
+			labels map[string]string
+			want   bool
+		}{
+			{map[string]string{"bgp": "cilium"}, true},
+			{map[string]string{"pool": "edge", "zone": "a"}, true},
+			{map[string]string{"pool": "edge"}, false},
+		}
+		for _, node := range nodes {
+			if got := selector.matches(node.labels); got != node.want {
+				t.Errorf("selector matches %v = %t, want %t", node.labels, got, node.want)
+			}
+		}
+	})
+	t.Run("change windows parse", func(t *testing.T) {
+		windows, err := parseChangeWindows(source.get("CHANGE_WINDOWS"), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(windows.windows) != 2 {
+			t.Errorf("windows = %d, want 2", len(windows.windows))
+		}
+	})
+}
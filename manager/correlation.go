@@ -0,0 +1,39 @@
+package manager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// correlationHeader carries the correlation ID of an aXAPI request, so the
+// device and proxy access logs can be matched with the controller logs.
+const correlationHeader = "X-Request-ID"
+
+// correlationKey is the context key of the correlation ID.
+type correlationKey struct{}
+
+// newCorrelationID generates a correlation ID for a node event or a
+// reconcile cycle. Every log line and aXAPI request of the resulting
+// neighbor changes carries it, so multi-step operations can be traced.
+func newCorrelationID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+// withCorrelationID returns a context carrying the correlation ID.
+func withCorrelationID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// correlationID returns the correlation ID of the context, empty if none.
+func correlationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
@@ -0,0 +1,197 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	credentialsSourceEnv   = "env"
+	credentialsSourceVault = "vault"
+	credentialsSourceAWS   = "aws-secrets-manager"
+	credentialsSourceGCP   = "gcp-secret-manager"
+	credentialsSourceToken = "token"
+
+	defaultCredentialsRefresh = 5 * time.Minute
+)
+
+// Credentials are the A10 login credentials.
+// If Token is set, it's a pre-issued aXAPI signature used instead of
+// logging in with the username and password.
+type Credentials struct {
+	Username string
+	Password Secret
+	Token    Secret
+}
+
+// CredentialsProvider resolves the A10 credentials from a source.
+type CredentialsProvider interface {
+	// Credentials returns the current credentials.
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// envCredentials are the credentials from the environment.
+// Each of them may instead be read from a file, e.g. a mounted Secret, which
+// is read again on every call to pick up rotations.
+type envCredentials struct {
+	credentials  Credentials
+	usernameFile string
+	passwordFile string
+}
+
+// Credentials returns the credentials, reading them from files if configured.
+// Returns an error if a file can't be read.
+func (e *envCredentials) Credentials(_ context.Context) (Credentials, error) {
+	creds := e.credentials
+	if e.usernameFile != "" {
+		username, err := readCredentialsFile(e.usernameFile)
+		if err != nil {
+			return Credentials{}, err
+		}
+		creds.Username = username
+	}
+	if e.passwordFile != "" {
+		password, err := readCredentialsFile(e.passwordFile)
+		if err != nil {
+			return Credentials{}, err
+		}
+		creds.Password = Secret(password)
+	}
+	return creds, nil
+}
+
+// tokenCredentials is a pre-issued aXAPI token from the environment or from
+// a file, which is read again on every call to pick up rotations.
+type tokenCredentials struct {
+	token     Secret
+	tokenFile string
+}
+
+// Credentials returns the token, reading it from a file if configured.
+// Returns an error if the file can't be read.
+func (t *tokenCredentials) Credentials(_ context.Context) (Credentials, error) {
+	if t.tokenFile == "" {
+		return Credentials{Token: t.token}, nil
+	}
+	token, err := readCredentialsFile(t.tokenFile)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{Token: Secret(token)}, nil
+}
+
+// readCredentialsFile reads a credential from a file, trimming the trailing
+// newline.
+// Returns an error if the file can't be read or is empty.
+func readCredentialsFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading credentials file: %w", err)
+	}
+	value := strings.TrimRight(string(data), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("credentials file %s is empty", path)
+	}
+	return value, nil
+}
+
+// newCredentialsProvider creates the provider for the configured source.
+// Returns an error if the source is unknown or misconfigured.
+func newCredentialsProvider(ctx context.Context, config *Config) (CredentialsProvider, error) {
+	switch config.CredentialsSource {
+	case credentialsSourceEnv:
+		return &envCredentials{
+			credentials: Credentials{
+				Username: config.Username,
+				Password: config.Password,
+			},
+			usernameFile: config.UsernameFile,
+			passwordFile: config.PasswordFile,
+		}, nil
+	case credentialsSourceToken:
+		return &tokenCredentials{token: config.Token, tokenFile: config.TokenFile}, nil
+	case credentialsSourceVault:
+		return newVaultCredentials(ctx, config)
+	case credentialsSourceAWS:
+		return newAWSSecretCredentials(ctx, config)
+	case credentialsSourceGCP:
+		return &gcpSecretCredentials{
+			name:      config.GCPSecretName,
+			gcpClient: gcpClient{client: &http.Client{Timeout: defaultTimeout}},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown credentials source %q", config.CredentialsSource)
+	}
+}
+
+// watchCredentials periodically resolves the credentials and updates the
+// devices when they are rotated. It returns when the context is done.
+func watchCredentials(
+	ctx context.Context,
+	provider CredentialsProvider,
+	devices *Devices,
+	interval time.Duration,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			devices.refreshCredentials(ctx, provider)
+		}
+	}
+}
+
+// refreshCredentials resolves the credentials of every device, from its
+// own provider if it has one or else from the shared one, and updates the
+// rotated ones.
+func (d *Devices) refreshCredentials(ctx context.Context, shared CredentialsProvider) {
+	resolved := map[CredentialsProvider]Credentials{}
+	for _, a10 := range d.devices {
+		provider := shared
+		if a10.credentials != nil {
+			provider = a10.credentials
+		}
+		creds, ok := resolved[provider]
+		if !ok {
+			var err error
+			creds, err = provider.Credentials(ctx)
+			if err != nil {
+				d.logger.Error("Error refreshing A10 credentials", "device", a10.address, "error", err)
+				continue
+			}
+			resolved[provider] = creds
+		}
+		a10.setCredentials(creds)
+	}
+}
+
+// setCredentials updates the device credentials.
+// If they changed, the current session is dropped so the next operation
+// logs in with the new credentials.
+func (a *A10) setCredentials(creds Credentials) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.username == creds.Username && a.password == creds.Password && a.token == creds.Token {
+		return
+	}
+	a.logger.Info("A10 credentials rotated", "device", a.address, "username", creds.Username)
+	a.username = creds.Username
+	a.password = creds.Password
+	a.token = creds.Token
+	a.signature = ""
+	a.resetLockout()
+}
+
+// currentCredentials returns the device credentials.
+func (a *A10) currentCredentials() Credentials {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return Credentials{Username: a.username, Password: a.password, Token: a.token}
+}
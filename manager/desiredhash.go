@@ -0,0 +1,77 @@
+package manager
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// convergedState remembers the state hash of the last reconcile cycle that
+// found nothing to change, so the next cycles of the same state are skipped.
+type convergedState struct {
+	mu   sync.Mutex
+	hash [sha256.Size]byte
+	set  bool
+}
+
+// matches checks if the hash is the one of the converged state.
+func (c *convergedState) matches(hash [sha256.Size]byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.set && c.hash == hash
+}
+
+// record records the hash as the converged state, or forgets it unless
+// the cycle converged.
+func (c *convergedState) record(hash [sha256.Size]byte, converged bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hash = hash
+	c.set = converged
+}
+
+// forget forgets the converged state, so the next cycle runs whatever the
+// state.
+func (c *convergedState) forget() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set = false
+}
+
+// stateHash hashes what a reconcile cycle depends on: the desired neighbors
+// with the labels and annotations the devices select them by, the cached
+// neighbors of every device with their remote AS and the quarantined neighbors. The annotations
+// written by the controller are left out, they don't change the desired
+// state and would change the hash on every peer status write.
+func stateHash(devices *Devices, kubeNodes *KubeNodes) [sha256.Size]byte {
+	h := sha256.New()
+	for _, address := range slices.Sorted(slices.Values(kubeNodes.Nodes)) {
+		neighbor := kubeNodes.Neighbors[address]
+		fmt.Fprintf(h, "node %s %s %s %d\n", address, neighbor.IP, neighbor.NodeName, neighbor.RemoteAS)
+		for _, key := range slices.Sorted(maps.Keys(neighbor.Labels)) {
+			fmt.Fprintf(h, "label %s=%s\n", key, neighbor.Labels[key])
+		}
+		for _, key := range slices.Sorted(maps.Keys(neighbor.Annotations)) {
+			if strings.HasPrefix(key, peerAnnotationPrefix) {
+				continue
+			}
+			fmt.Fprintf(h, "annotation %s=%s\n", key, neighbor.Annotations[key])
+		}
+	}
+	for _, a10 := range devices.devices {
+		fmt.Fprintf(h, "device %s\n", a10.address)
+		neighbors := a10.neighborSet()
+		for _, neighbor := range slices.Sorted(maps.Keys(neighbors)) {
+			fmt.Fprintf(h, "neighbor %s %d\n", neighbor, neighbors[neighbor])
+		}
+	}
+	for _, neighbor := range slices.Sorted(maps.Keys(devices.quarantine.report())) {
+		fmt.Fprintf(h, "quarantined %s\n", neighbor)
+	}
+	var hash [sha256.Size]byte
+	h.Sum(hash[:0])
+	return hash
+}
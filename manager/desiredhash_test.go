@@ -0,0 +1,60 @@
+package manager
+
+import "testing"
+
+func TestStateHash(t *testing.T) {
+	kubeNodes := func(annotations map[string]string) *KubeNodes {
+		neighbor := Neighbor{
+			IP:          "10.0.0.1",
+			NodeName:    "node-1",
+			Labels:      map[string]string{"bgp": "a10"},
+			Annotations: annotations,
+		}
+		return &KubeNodes{
+			Nodes:     []string{neighbor.IP},
+			Neighbors: map[string]Neighbor{neighbor.IP: neighbor},
+		}
+	}
+	base := map[string]string{"example.com/local-as": "65010"}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		changed     bool
+	}{
+		{
+			name:        "same annotations",
+			annotations: map[string]string{"example.com/local-as": "65010"},
+		},
+		{
+			name: "peering annotations written",
+			annotations: map[string]string{
+				"example.com/local-as":     "65010",
+				peerIPAnnotation:           "10.0.0.1",
+				peerLastSyncAnnotation:     "2026-01-01T00:00:00Z",
+				peerSessionAnnotation:      "https://a10=Established",
+				peerAnnotationPrefix + "x": "y",
+			},
+		},
+		{
+			name:        "annotation changed",
+			annotations: map[string]string{"example.com/local-as": "65020"},
+			changed:     true,
+		},
+		{
+			name:        "annotation removed",
+			annotations: map[string]string{},
+			changed:     true,
+		},
+	}
+	devices := &Devices{}
+	want := stateHash(devices, kubeNodes(base))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := stateHash(devices, kubeNodes(tt.annotations))
+			if changed := got != want; changed != tt.changed {
+				t.Errorf("hash changed = %t, want %t", changed, tt.changed)
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Devices fans out neighbor operations to every configured A10 device.
+// Each device is synced independently and its result is tracked per
+// neighbor, so one device being down doesn't affect the others.
+type Devices struct {
+	logger  *log.Logger
+	devices []*A10
+	status  *statusTracker
+	// nodeState holds the allowlist and the scope of the neighbors
+	nodeState *nodeState
+	// removalGuard limits the removals of a single reconcile per device
+	removalGuard removalGuard
+	// approvals stage the removals of a reconcile above a threshold until
+	// approved if set
+	approvals *approvalGate
+	// pause halts the writes, the changes are dropped until they resume
+	pause *pauseSwitch
+	// canaryTimeout is how long the session of a neighbor added to the
+	// canary device may take to establish, 0 disables canary apply
+	canaryTimeout time.Duration
+	// verifyTimeout is how long the session of an added neighbor may take
+	// to establish, 0 disables the verification
+	verifyTimeout time.Duration
+	// rollback removes and quarantines the neighbors that fail to be added
+	// or verified
+	rollback   bool
+	quarantine *quarantine
+	// flaps quarantine the neighbors flapping too often if set
+	flaps *flapDetector
+	// tombstones keep the neighbors of the deleted nodes shut down until
+	// they expire if set
+	tombstones *tombstones
+	// checkpoints checkpoint the devices before every batch if set
+	checkpoints *checkpointer
+	// notifier posts the notable events if set
+	notifier *notifier
+	// recorder records the Events on the Nodes if set
+	recorder *nodeEventRecorder
+	// converged is the state of the last reconcile that changed nothing
+	converged convergedState
+}
+
+// GetNeighbors gets the neighbors from every device.
+// Returns an error if the operation fails for any device.
+func (d *Devices) GetNeighbors() error {
+	return d.getNeighborsOf(nil)
+}
+
+// getNeighborsOf gets the neighbors of the devices with the addresses, all
+// of them if empty.
+// Returns the joined errors of the devices that failed.
+func (d *Devices) getNeighborsOf(addresses []string) error {
+	var errs []error
+	for _, a10 := range d.devices {
+		if len(addresses) > 0 && !slices.Contains(addresses, a10.address) {
+			continue
+		}
+		if err := a10.GetNeighbors(); err != nil {
+			errs = append(errs, fmt.Errorf("device %s: %w", a10.address, err))
+			continue
+		}
+		for _, neighbor := range a10.listNeighbors() {
+			d.status.setPending(a10.address, neighbor, "", true)
+			d.status.setSynced(a10.address, neighbor, false)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// beginBatch makes sure every device has a valid session for a batch of
+// operations on the neighbors, and checkpoints the devices if enabled.
+// Devices that fail to log in retry per operation.
+func (d *Devices) beginBatch(neighbors map[string]struct{}) {
+	for _, a10 := range d.devices {
+		if err := a10.beginBatch(); err != nil {
+			d.logger.Error("Error logging in to A10 for batch", "device", a10.address, "error", err)
+		}
+	}
+	d.checkpoints.begin(d.devices, neighbors)
+}
+
+// invalidateSessions drops the sessions of the devices so the next
+// operations log in again.
+func (d *Devices) invalidateSessions() {
+	for _, a10 := range d.devices {
+		a10.invalidateSession()
+	}
+}
+
+// AddNeighbor adds the neighbor to every device peered with its node, and
+// removes it from the devices no longer peered with it. With canary apply,
+// it's added to the canary device first and only rolled out to the others
+// once its session is verified.
+// Returns the joined errors of the devices that failed.
+func (d *Devices) AddNeighbor(ctx context.Context, neighbor Neighbor) error {
+	if d.quarantine.contains(neighbor.IP) {
+		d.logger.Info(
+			"Neighbor is quarantined, not adding it",
+			"neighbor", neighbor.IP,
+			"node", neighbor.NodeName,
+			"correlationID", correlationID(ctx),
+		)
+		return nil
+	}
+	if !d.nodeState.allowlist.allows(neighbor.IP) {
+		d.nodeState.allowlist.refuse(d.logger, neighbor, correlationID(ctx))
+		return nil
+	}
+	canary := d.canary(neighbor)
+	if canary != nil {
+		if err := d.addNeighbor(ctx, canary, neighbor); err != nil {
+			return err
+		}
+		if err := d.verifyCanary(ctx, canary, neighbor); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	for _, a10 := range d.devices {
+		if a10 == canary {
+			continue
+		}
+		if !a10.selects(neighbor) {
+			if a10.containsNeighbor(neighbor.IP) {
+				if err := d.removeNeighbor(ctx, a10, neighbor.IP, neighbor.NodeName); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			continue
+		}
+		if err := d.addNeighbor(ctx, a10, neighbor); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RemoveNeighbor removes the neighbor from every device.
+// Returns the joined errors of the devices that failed.
+func (d *Devices) RemoveNeighbor(ctx context.Context, neighborIP string, nodeName string) error {
+	var errs []error
+	for _, a10 := range d.devices {
+		if err := d.removeNeighbor(ctx, a10, neighborIP, nodeName); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// addNeighbor adds the neighbor to a single device and records the result.
+// A created neighbor is verified if enabled, and rolled back if it fails.
+// Returns errPaused while the writes are paused.
+func (d *Devices) addNeighbor(ctx context.Context, a10 *A10, neighbor Neighbor) error {
+	d.status.setPending(a10.address, neighbor.IP, neighbor.NodeName, true)
+	if d.pause.isPaused() {
+		d.logger.Info(
+			"A10 writes paused, not adding neighbor",
+			"device", a10.address,
+			"neighbor", neighbor.IP,
+			"correlationID", correlationID(ctx),
+		)
+		return errPaused
+	}
+	created := !a10.peersWith(neighbor)
+	if err := a10.AddNeighbor(ctx, neighbor); err != nil {
+		d.status.setError(a10.address, neighbor.IP, err)
+		if d.rollback {
+			d.rollbackFailedAdd(ctx, a10, neighbor, err)
+		}
+		return fmt.Errorf("device %s: %w", a10.address, err)
+	}
+	created = created && a10.peersWith(neighbor)
+	d.status.setSynced(a10.address, neighbor.IP, created)
+	if created && d.verifyTimeout > 0 {
+		return d.verifyAdd(ctx, a10, neighbor)
+	}
+	return nil
+}
+
+// removeNeighbor removes the neighbor from a single device and records the
+// result.
+// Returns errPaused while the writes are paused.
+func (d *Devices) removeNeighbor(ctx context.Context, a10 *A10, neighborIP string, nodeName string) error {
+	d.status.setPending(a10.address, neighborIP, nodeName, false)
+	if d.pause.isPaused() {
+		d.logger.Info(
+			"A10 writes paused, not removing neighbor",
+			"device", a10.address,
+			"neighbor", neighborIP,
+			"correlationID", correlationID(ctx),
+		)
+		return errPaused
+	}
+	if err := a10.RemoveNeighbor(ctx, neighborIP, nodeName); err != nil {
+		d.status.setError(a10.address, neighborIP, err)
+		return fmt.Errorf("device %s: %w", a10.address, err)
+	}
+	d.status.setSynced(a10.address, neighborIP, true)
+	return nil
+}
+
+// reconcileNeighbors queues the changes that make the devices match k8s.
+// Eligible nodes missing on any device are added and device neighbors that
+// are not in k8s are removed. The changes are applied in parallel by the
+// workers of the queue.
+// Only the nodes and neighbors of this replica's shard are changed.
+// Removals are applied to every device, so if they exceed the removal
+// guard on any device, all of them are refused, and if they exceed the
+// approval threshold, they are staged until approved.
+// The consolidated plan is logged and published on the status API before
+// the changes are queued, and all of them share the correlation ID and the
+// deadline of the reconcile cycle.
+// The cycle is skipped if the nodes and the cached device neighbors are the
+// same as in the last cycle that found nothing to change.
+// Returns the neighbors that were queued.
+func reconcileNeighbors(
+	devices *Devices,
+	kubeNodes *KubeNodes,
+	queue *WorkQueue,
+	sharder *Sharder,
+) []string {
+	id := newCorrelationID()
+	logger := devices.logger.With("correlationID", id)
+	hash := stateHash(devices, kubeNodes)
+	if devices.converged.matches(hash) {
+		logger.Debug("Nodes and A10 neighbors unchanged since the last converged reconcile, skipping")
+		reconcilesSkipped.Inc()
+		return nil
+	}
+	logger.Info("Reconciling A10 neighbors with k8s")
+
+	plan := newReconcilePlan(id)
+	removalsAllowed := true
+	found := map[string][]string{}
+	for _, a10 := range devices.devices {
+		onDevice := a10.neighborSet()
+		a10Neighbors := slices.Sorted(maps.Keys(onDevice))
+		found[a10.address] = a10Neighbors
+		logger.Debug("A10 neighbors", "device", a10.address, "neighbors", a10Neighbors)
+
+		// Add k8s nodes that are missing in A10
+		for _, address := range kubeNodes.Nodes {
+			if devices.quarantine.contains(address) {
+				logger.Debug("Skipping quarantined neighbor", "device", a10.address, "neighbor", address)
+				continue
+			}
+			if !devices.nodeState.allowlist.allows(address) {
+				logger.Debug("Skipping neighbor outside the allowed CIDRs", "device", a10.address, "neighbor", address)
+				continue
+			}
+			desired := kubeNodes.Neighbors[address]
+			if !a10.selects(desired) || !sharder.owns(desired) {
+				continue
+			}
+			if remoteAS, ok := onDevice[address]; !ok {
+				logger.Info("k8s node not found in A10", "device", a10.address, "neighbor", address)
+				plan.add(a10, desired)
+			} else if remoteAS != a10.neighborRemoteAS(desired) {
+				logger.Info(
+					"A10 neighbor has another remote AS than its k8s node",
+					"device", a10.address,
+					"neighbor", address,
+					"remoteAS", remoteAS,
+					"desiredRemoteAS", a10.neighborRemoteAS(desired),
+				)
+				plan.replace(a10, desired, remoteAS)
+			}
+		}
+
+		// Remove neighbors from A10 that are not in k8s
+		var deviceRemovals []string
+		for _, neighbor := range a10Neighbors {
+			logger.Debug("Checking neighbor", "device", a10.address, "address", neighbor)
+			if a10.protected.contains(neighbor) {
+				logger.Info("Skipping protected A10 neighbor", "device", a10.address, "neighbor", neighbor)
+				continue
+			}
+			if devices.tombstones.contains(neighbor) {
+				logger.Debug("Skipping tombstoned A10 neighbor", "device", a10.address, "neighbor", neighbor)
+				continue
+			}
+			if nodeName, ok := devices.nodeState.scope.foreignNeighbor(neighbor); ok {
+				logger.Debug("Skipping A10 neighbor of node out of scope", "device", a10.address, "neighbor", neighbor, "node", nodeName)
+				continue
+			}
+			if _, desired := kubeNodes.Neighbors[neighbor]; !desired &&
+				sharder.ownsRemoval(neighbor, devices.status.neighborNode(a10.address, neighbor)) {
+				logger.Info("A10 neighbor not found in k8s", "device", a10.address, "neighbor", neighbor)
+				deviceRemovals = append(deviceRemovals, neighbor)
+				plan.remove(a10, neighbor, devices.status.neighborNode(a10.address, neighbor))
+				continue
+			}
+			// Remove the nodes the device is no longer peered with
+			if desired, ok := kubeNodes.Neighbors[neighbor]; ok && !a10.selects(desired) &&
+				sharder.owns(desired) {
+				logger.Info("k8s node not selected by the device", "device", a10.address, "neighbor", neighbor)
+				deviceRemovals = append(deviceRemovals, neighbor)
+				plan.deselect(a10, desired)
+			}
+		}
+		if !devices.removalGuard.allows(len(deviceRemovals), len(a10Neighbors)) {
+			logger.Error(
+				"Refusing to remove too many A10 neighbors at once, set ALLOW_MASS_REMOVAL=true to apply",
+				"device", a10.address,
+				"removals", len(deviceRemovals),
+				"managed", len(a10Neighbors),
+				"limit", devices.removalGuard,
+			)
+			massRemovalsBlocked.WithLabelValues(a10.address).Inc()
+			removalsAllowed = false
+		}
+	}
+	ctx := withCorrelationID(context.Background(), id)
+	if !removalsAllowed {
+		plan.refuseRemovals()
+	} else if !devices.approvals.admit(ctx, id, sortedChanges(plan.removes)) {
+		plan.stageRemovals()
+	}
+
+	plan.finish()
+	devices.converged.record(hash, len(plan.Adds)+len(plan.Removes)+len(plan.Refused)+len(plan.Staged) == 0)
+	devices.status.setPlan(plan)
+	devices.reportAdoption(ctx, plan, found)
+	addIPs := plan.addIPs()
+	removeIPs := plan.removeIPs()
+	logger.Info(
+		"Reconciliation plan",
+		"adds", len(addIPs),
+		"removes", len(removeIPs),
+		"add", addIPs,
+		"remove", removeIPs,
+	)
+	deadline := queue.cycleDeadline()
+	for _, change := range plan.Adds {
+		queue.ReconcileNeighbor(change.neighbor, true, id, deadline)
+	}
+	for _, change := range plan.Removes {
+		// the nodes still desired on other devices are removed from the
+		// devices not selecting them by adding them
+		if change.neighbor.IP != "" {
+			queue.ReconcileNeighbor(change.neighbor, true, id, deadline)
+			continue
+		}
+		queue.ReconcileNeighbor(Neighbor{IP: change.IP}, false, id, deadline)
+	}
+	return append(addIPs, removeIPs...)
+}
@@ -0,0 +1,113 @@
+package manager_test
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/rgeraskin/a10-bgp-neighbor-manager/manager"
+	"github.com/rgeraskin/a10-bgp-neighbor-manager/manager/fake"
+)
+
+const (
+	// smallCluster and largeCluster are the node counts the reconcile
+	// scaling is measured between
+	smallCluster = 2000
+	largeCluster = 10 * smallCluster
+	// maxReconcileScaling is how many times longer the reconcile of the
+	// large cluster may take than the small one: 10 for a linear reconcile,
+	// a bit more for sorting the plan, while scanning a list of the device
+	// neighbors per node already takes about 60 times longer
+	maxReconcileScaling = 30
+)
+
+// testIP returns the i-th address of the prefix, a /8.
+func testIP(prefix, i int) string {
+	return fmt.Sprintf("%d.%d.%d.%d", prefix, i>>16&0xff, i>>8&0xff, i&0xff)
+}
+
+// clusterOf creates two fake devices peering with the first half of the
+// nodes and as many neighbors of deleted nodes, and the nodes, so a
+// reconcile adds half of the nodes and removes the other neighbors.
+func clusterOf(tb testing.TB, nodes int) (*manager.Devices, *manager.KubeNodes) {
+	tb.Helper()
+	var neighbors []manager.Neighbor
+	var configured []manager.BackendNeighbor
+	for i := range nodes {
+		neighbors = append(neighbors, manager.Neighbor{IP: testIP(10, i), NodeName: fmt.Sprintf("node-%d", i)})
+		if i < nodes/2 {
+			configured = append(configured, manager.BackendNeighbor{IP: testIP(10, i), RemoteAS: manager.TestRemoteAS})
+		} else {
+			configured = append(configured, manager.BackendNeighbor{IP: testIP(172, i), RemoteAS: manager.TestRemoteAS})
+		}
+	}
+	devices, err := manager.NewTestDevices(
+		context.Background(),
+		fake.NewDevice(configured...),
+		fake.NewDevice(configured...),
+	)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return devices, manager.NewTestKubeNodes(neighbors...)
+}
+
+func TestReconcileNeighborsRemoteAS(t *testing.T) {
+	fakes, devices := devicesWith(t, 2, node1.IP, node2.IP)
+	moved := node1
+	moved.RemoteAS = manager.TestManagedRemoteAS
+	kubeNodes := manager.NewTestKubeNodes(moved, node2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := manager.NewTestQueue(ctx, devices, 0)
+	queue.Start()
+
+	if queued := manager.ReconcileNeighbors(devices, kubeNodes, queue); len(queued) != 1 || queued[0] != moved.IP {
+		t.Fatalf("queued = %v, want [%s]", queued, moved.IP)
+	}
+	waitApplied(t, queue)
+	want := map[string]int{moved.IP: manager.TestManagedRemoteAS, node2.IP: manager.TestRemoteAS}
+	for i, device := range fakes {
+		if got := device.State(); !maps.Equal(got, want) {
+			t.Errorf("device %d neighbors = %v, want %v", i, got, want)
+		}
+		if removes, adds := countCalls(device, fake.OpRemoveNeighbor), countCalls(device, fake.OpAddNeighbor); removes != 1 || adds != 1 {
+			t.Errorf("device %d removes = %d, adds = %d, want 1 and 1", i, removes, adds)
+		}
+	}
+	if queued := manager.ReconcileNeighbors(devices, kubeNodes, queue); len(queued) != 0 {
+		t.Errorf("queued after the replacement = %v, want none", queued)
+	}
+}
+
+// BenchmarkReconcileNeighbors measures the reconcile time of growing
+// clusters, and checks it grows linearly with the cluster, as documented
+// for large clusters, when both the small and the large one are measured.
+func BenchmarkReconcileNeighbors(b *testing.B) {
+	perReconcile := map[int]time.Duration{}
+	for _, nodes := range []int{smallCluster, 5 * smallCluster, largeCluster} {
+		b.Run(fmt.Sprintf("nodes=%d", nodes), func(b *testing.B) {
+			devices, kubeNodes := clusterOf(b, nodes)
+			queue := manager.NewTestQueue(context.Background(), devices, 0)
+			b.ResetTimer()
+			for range b.N {
+				manager.ReconcileNeighbors(devices, kubeNodes, queue)
+			}
+			perReconcile[nodes] = b.Elapsed() / time.Duration(b.N)
+		})
+	}
+	small, large := perReconcile[smallCluster], perReconcile[largeCluster]
+	if small == 0 || large == 0 {
+		return
+	}
+	scaling := float64(large) / float64(small)
+	b.Logf("reconcile of %d nodes: %s, of %d nodes: %s (x%.1f)", smallCluster, small, largeCluster, large, scaling)
+	if scaling > maxReconcileScaling {
+		b.Errorf(
+			"reconcile of %d nodes takes %.1f times as long as of %d nodes, want at most %d",
+			largeCluster, scaling, smallCluster, maxReconcileScaling,
+		)
+	}
+}
@@ -0,0 +1,127 @@
+package manager
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+// defaultDNSCacheTTL is how long the resolved node addresses are cached
+// before they are resolved again.
+const defaultDNSCacheTTL = time.Minute
+
+// dnsAddressType checks if the node address type is a DNS name.
+func dnsAddressType(addressType v1.NodeAddressType) bool {
+	return addressType == v1.NodeInternalDNS || addressType == v1.NodeExternalDNS
+}
+
+// dnsEntry is a resolved node address.
+type dnsEntry struct {
+	ip       string
+	lastUsed time.Time
+}
+
+// dnsResolver resolves the DNS names of the nodes to their IPv4 addresses
+// for environments where DNS is the source of truth for node addressing.
+// The addresses are cached and resolved again every TTL, and a change is
+// notified so the neighbors follow it. It is safe for concurrent use.
+type dnsResolver struct {
+	logger   *log.Logger
+	ttl      time.Duration
+	resolver *net.Resolver
+
+	mu    sync.Mutex
+	cache map[string]*dnsEntry
+	// changed is notified when a cached address changes
+	changed chan struct{}
+}
+
+// newDNSResolver creates a resolver caching the addresses for the TTL.
+func newDNSResolver(logger *log.Logger, ttl time.Duration) *dnsResolver {
+	return &dnsResolver{
+		logger:   logger,
+		ttl:      ttl,
+		resolver: net.DefaultResolver,
+		cache:    map[string]*dnsEntry{},
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+// resolve returns the cached IPv4 address of the host, resolving it if it
+// isn't cached.
+// Returns an empty string if the host can't be resolved.
+func (r *dnsResolver) resolve(host string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.cache[host]; ok {
+		entry.lastUsed = time.Now()
+		return entry.ip
+	}
+	ip, err := r.lookup(host)
+	if err != nil {
+		r.logger.Error("Error resolving node address", "host", host, "error", err)
+		return ""
+	}
+	r.cache[host] = &dnsEntry{ip: ip, lastUsed: time.Now()}
+	return ip
+}
+
+// lookup resolves the first IPv4 address of the host.
+// Returns an error if the host can't be resolved.
+func (r *dnsResolver) lookup(host string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	ips, err := r.resolver.LookupIP(ctx, "ip4", host)
+	if err != nil {
+		return "", err
+	}
+	return ips[0].String(), nil
+}
+
+// run resolves the cached addresses again every TTL until the context is
+// done. Addresses unused for 10 TTLs, e.g. of deleted nodes, are dropped.
+func (r *dnsResolver) run(ctx context.Context) {
+	ticker := time.NewTicker(r.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.refresh()
+		}
+	}
+}
+
+// refresh resolves the cached addresses again and notifies the changes.
+func (r *dnsResolver) refresh() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := false
+	for host, entry := range r.cache {
+		if time.Since(entry.lastUsed) > 10*r.ttl {
+			delete(r.cache, host)
+			continue
+		}
+		ip, err := r.lookup(host)
+		if err != nil {
+			r.logger.Warn("Error resolving node address again, keeping the cached one", "host", host, "ip", entry.ip, "error", err)
+			continue
+		}
+		if ip != entry.ip {
+			r.logger.Info("Node address changed in DNS", "host", host, "old", entry.ip, "new", ip)
+			entry.ip = ip
+			changed = true
+		}
+	}
+	if changed {
+		select {
+		case r.changed <- struct{}{}:
+		default:
+		}
+	}
+}
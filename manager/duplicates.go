@@ -0,0 +1,149 @@
+package manager
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/charmbracelet/log"
+)
+
+// addressClaims tracks the nodes peering with every address. By default,
+// when two eligible nodes report the same address, only the first one
+// manages its neighbor, the other one is ineligible and reported until the
+// address is released. With shared addresses, e.g. nodes behind a NAT, all
+// the nodes claim the address and its neighbor is removed only when the
+// last of them releases it. It is safe for concurrent use.
+type addressClaims struct {
+	logger *log.Logger
+	// shared lets several nodes claim the same address
+	shared bool
+
+	mu sync.Mutex
+	// owners maps the claimed addresses to their nodes
+	owners map[string]map[string]struct{}
+	// claims maps the nodes to their claimed address
+	claims map[string]string
+	// duplicates maps the refused nodes to the address they reported
+	duplicates map[string]string
+}
+
+// newAddressClaims creates an empty claim tracker, letting the nodes share
+// their addresses if shared.
+func newAddressClaims(logger *log.Logger, shared bool) *addressClaims {
+	return &addressClaims{
+		logger:     logger,
+		shared:     shared,
+		owners:     map[string]map[string]struct{}{},
+		claims:     map[string]string{},
+		duplicates: map[string]string{},
+	}
+}
+
+// settle claims the address for the eligible node, or releases the claim
+// of the ineligible one. Unless the addresses are shared, an eligible node
+// reporting an address claimed by another node becomes ineligible.
+// Returns the eligibility and the reason of the node.
+func (c *addressClaims) settle(nodeName, address string, eligible bool, reason string) (bool, string) {
+	if !eligible || address == "" {
+		c.release(nodeName)
+		return eligible, reason
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if claimed := c.claims[nodeName]; claimed != "" && claimed != address {
+		c.releaseLocked(nodeName)
+	}
+	owners := c.owners[address]
+	if _, owned := owners[nodeName]; !owned && len(owners) > 0 && !c.shared {
+		owner := c.ownerLocked(address)
+		if c.duplicates[nodeName] != address {
+			c.logger.Warn(
+				"Node reports the address of another node, not managing it twice",
+				"node", nodeName,
+				"address", address,
+				"owner", owner,
+			)
+		}
+		c.duplicates[nodeName] = address
+		duplicateNodeAddress.WithLabelValues(nodeName).Set(1)
+		return false, fmt.Sprintf("duplicate: address %s is already peered by node %s", address, owner)
+	}
+	if owners == nil {
+		owners = map[string]struct{}{}
+		c.owners[address] = owners
+	}
+	owners[nodeName] = struct{}{}
+	c.claims[nodeName] = address
+	delete(c.duplicates, nodeName)
+	duplicateNodeAddress.DeleteLabelValues(nodeName)
+	sharedNodeAddresses.Set(float64(c.sharedLocked()))
+	return true, reason
+}
+
+// release releases the claim of the node, e.g. ineligible or deleted.
+func (c *addressClaims) release(nodeName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.releaseLocked(nodeName)
+}
+
+// releaseLocked releases the claim of the node with the lock held.
+func (c *addressClaims) releaseLocked(nodeName string) {
+	if address, ok := c.claims[nodeName]; ok {
+		delete(c.owners[address], nodeName)
+		if len(c.owners[address]) == 0 {
+			delete(c.owners, address)
+		}
+	}
+	delete(c.claims, nodeName)
+	delete(c.duplicates, nodeName)
+	duplicateNodeAddress.DeleteLabelValues(nodeName)
+	sharedNodeAddresses.Set(float64(c.sharedLocked()))
+}
+
+// ownerLocked returns the first node, by name, claiming the address with
+// the lock held.
+func (c *addressClaims) ownerLocked(address string) string {
+	owners := slices.Sorted(maps.Keys(c.owners[address]))
+	if len(owners) == 0 {
+		return ""
+	}
+	return owners[0]
+}
+
+// sharedLocked counts the addresses claimed by several nodes with the lock
+// held.
+func (c *addressClaims) sharedLocked() int {
+	count := 0
+	for _, owners := range c.owners {
+		if len(owners) > 1 {
+			count++
+		}
+	}
+	return count
+}
+
+// managedByOther checks if the address is claimed by another node than
+// this one, so this node must not remove its neighbor.
+func (c *addressClaims) managedByOther(nodeName, address string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for owner := range c.owners[address] {
+		if owner != nodeName {
+			return true
+		}
+	}
+	return false
+}
+
+// report maps the refused nodes to the address they reported.
+func (c *addressClaims) report() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.duplicates) == 0 {
+		return nil
+	}
+	return maps.Clone(c.duplicates)
+}
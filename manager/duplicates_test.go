@@ -0,0 +1,136 @@
+package manager
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestAddressClaimsSettle(t *testing.T) {
+	// claim is a node settling its address, in order
+	type claim struct {
+		node     string
+		address  string
+		eligible bool
+		// want is the eligibility after settling
+		want bool
+	}
+	tests := []struct {
+		name   string
+		shared bool
+		claims []claim
+		// duplicates are the refused nodes and their address at the end
+		duplicates map[string]string
+		// managedByOther are the addresses managed by another node than the
+		// key at the end
+		managedByOther map[string]string
+	}{
+		{
+			name: "distinct addresses",
+			claims: []claim{
+				{"node-1", "10.0.0.1", true, true},
+				{"node-2", "10.0.0.2", true, true},
+			},
+		},
+		{
+			name: "duplicate address is refused",
+			claims: []claim{
+				{"node-1", "10.0.0.1", true, true},
+				{"node-2", "10.0.0.1", true, false},
+				{"node-1", "10.0.0.1", true, true},
+			},
+			duplicates:     map[string]string{"node-2": "10.0.0.1"},
+			managedByOther: map[string]string{"node-2": "10.0.0.1"},
+		},
+		{
+			name: "released address is claimed by the duplicate",
+			claims: []claim{
+				{"node-1", "10.0.0.1", true, true},
+				{"node-2", "10.0.0.1", true, false},
+				{"node-1", "10.0.0.1", false, false},
+				{"node-2", "10.0.0.1", true, true},
+			},
+			managedByOther: map[string]string{"node-1": "10.0.0.1"},
+		},
+		{
+			name: "changed address releases the old one",
+			claims: []claim{
+				{"node-1", "10.0.0.1", true, true},
+				{"node-1", "10.0.0.2", true, true},
+				{"node-2", "10.0.0.1", true, true},
+			},
+			managedByOther: map[string]string{"node-1": "10.0.0.1", "node-2": "10.0.0.2"},
+		},
+		{
+			name: "ineligible node doesn't claim",
+			claims: []claim{
+				{"node-1", "10.0.0.1", false, false},
+				{"node-2", "10.0.0.1", true, true},
+			},
+		},
+		{
+			name: "node without address doesn't claim",
+			claims: []claim{
+				{"node-1", "", true, true},
+				{"node-2", "10.0.0.1", true, true},
+			},
+		},
+		{
+			name:   "shared address",
+			shared: true,
+			claims: []claim{
+				{"node-1", "10.0.0.1", true, true},
+				{"node-2", "10.0.0.1", true, true},
+			},
+			managedByOther: map[string]string{"node-1": "10.0.0.1", "node-2": "10.0.0.1"},
+		},
+		{
+			name:   "shared address kept until the last node releases it",
+			shared: true,
+			claims: []claim{
+				{"node-1", "10.0.0.1", true, true},
+				{"node-2", "10.0.0.1", true, true},
+				{"node-1", "10.0.0.1", false, false},
+			},
+			managedByOther: map[string]string{"node-1": "10.0.0.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := newAddressClaims(testLogger(), tt.shared)
+			for i, c := range tt.claims {
+				got, reason := claims.settle(c.node, c.address, c.eligible, "checked")
+				if got != c.want {
+					t.Errorf("claim %d of %s: eligible = %t, want %t (reason %q)", i, c.node, got, c.want, reason)
+				}
+			}
+			if got := claims.report(); !maps.Equal(got, tt.duplicates) {
+				t.Errorf("duplicates = %v, want %v", got, tt.duplicates)
+			}
+			for node, address := range tt.managedByOther {
+				if !claims.managedByOther(node, address) {
+					t.Errorf("%s is not managed by another node than %s", address, node)
+				}
+			}
+		})
+	}
+}
+
+func TestAddressClaimsRelease(t *testing.T) {
+	claims := newAddressClaims(testLogger(), false)
+	claims.settle("node-1", "10.0.0.1", true, "")
+	if eligible, _ := claims.settle("node-2", "10.0.0.1", true, ""); eligible {
+		t.Fatal("duplicate node is eligible")
+	}
+
+	claims.release("node-2")
+	if got := claims.report(); got != nil {
+		t.Errorf("duplicates after releasing the duplicate = %v, want none", got)
+	}
+	claims.release("node-1")
+	if claims.managedByOther("node-2", "10.0.0.1") {
+		t.Error("released address is still managed")
+	}
+	if eligible, _ := claims.settle("node-2", "10.0.0.1", true, ""); !eligible {
+		t.Error("released address can't be claimed")
+	}
+}
@@ -0,0 +1,245 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+const defaultEligibilityChecks = "ready,cordon,label,address"
+
+// eligibilityCheck is a single named step of the node eligibility chain.
+// check returns whether the node passes and a human readable reason.
+type eligibilityCheck struct {
+	name  string
+	check func(node *v1.Node) (bool, string)
+}
+
+// eligibilityChecks is an ordered chain of eligibility checks.
+// A node is eligible only if it passes every check in the chain.
+type eligibilityChecks []eligibilityCheck
+
+// eligibilityCheckFactory builds a check from its optional argument,
+// e.g. "NoSchedule" for "taints:NoSchedule", with the node state of the
+// manager.
+type eligibilityCheckFactory func(
+	arg string,
+	config *Config,
+	nodes *nodeState,
+) (func(node *v1.Node) (bool, string), error)
+
+// eligibilityCheckRegistry holds all known checks by name.
+// New checks are plugged in with registerEligibilityCheck.
+var eligibilityCheckRegistry = map[string]eligibilityCheckFactory{}
+
+// registerEligibilityCheck adds a check factory to the registry.
+func registerEligibilityCheck(name string, factory eligibilityCheckFactory) {
+	eligibilityCheckRegistry[name] = factory
+}
+
+func init() {
+	registerEligibilityCheck("ready", func(_ string, _ *Config, _ *nodeState) (func(*v1.Node) (bool, string), error) {
+		return func(node *v1.Node) (bool, string) {
+			if !nodeReady(node) {
+				return false, "node is not ready"
+			}
+			return true, "node is ready"
+		}, nil
+	})
+	registerEligibilityCheck("cordon", func(_ string, _ *Config, _ *nodeState) (func(*v1.Node) (bool, string), error) {
+		return func(node *v1.Node) (bool, string) {
+			if nodeCordoned(node) {
+				return false, "node is cordoned"
+			}
+			return true, "node is not cordoned"
+		}, nil
+	})
+	registerEligibilityCheck("label", func(_ string, config *Config, _ *nodeState) (func(*v1.Node) (bool, string), error) {
+		selector := config.NodeSelector
+		return func(node *v1.Node) (bool, string) {
+			if !nodeLabeled(node, selector) {
+				return false, fmt.Sprintf("node doesn't match %s", selector)
+			}
+			return true, fmt.Sprintf("node matches %s", selector)
+		}, nil
+	})
+	registerEligibilityCheck("address", func(_ string, _ *Config, nodes *nodeState) (func(*v1.Node) (bool, string), error) {
+		return func(node *v1.Node) (bool, string) {
+			if nodes.address(node) == "" {
+				return false, fmt.Sprintf("node has no %s address", nodes.addressType)
+			}
+			return true, fmt.Sprintf("node has an %s address", nodes.addressType)
+		}, nil
+	})
+	registerEligibilityCheck("taints", newTaintsCheck)
+	registerEligibilityCheck("condition", newConditionCheck)
+	registerEligibilityCheck("annotation", newAnnotationCheck)
+}
+
+// newTaintsCheck rejects nodes with taints of the given effects.
+// The argument is a "|" separated list of effects, NoSchedule and NoExecute
+// by default.
+func newTaintsCheck(arg string, _ *Config, _ *nodeState) (func(*v1.Node) (bool, string), error) {
+	effects := []v1.TaintEffect{v1.TaintEffectNoSchedule, v1.TaintEffectNoExecute}
+	if arg != "" {
+		effects = nil
+		for _, effect := range strings.Split(arg, "|") {
+			switch e := v1.TaintEffect(effect); e {
+			case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+				effects = append(effects, e)
+			default:
+				return nil, fmt.Errorf("unknown taint effect %q", effect)
+			}
+		}
+	}
+	return func(node *v1.Node) (bool, string) {
+		for _, taint := range node.Spec.Taints {
+			for _, effect := range effects {
+				if taint.Effect == effect {
+					return false, fmt.Sprintf("node has taint %s:%s", taint.Key, taint.Effect)
+				}
+			}
+		}
+		return true, "node has no rejected taints"
+	}, nil
+}
+
+// newConditionCheck is a custom check requiring a node condition to have the
+// given status, e.g. "condition:NetworkUnavailable=False".
+func newConditionCheck(arg string, _ *Config, _ *nodeState) (func(*v1.Node) (bool, string), error) {
+	conditionType, status, ok := strings.Cut(arg, "=")
+	if !ok || conditionType == "" || status == "" {
+		return nil, fmt.Errorf("condition check must be in the format condition:Type=Status")
+	}
+	return func(node *v1.Node) (bool, string) {
+		for _, condition := range node.Status.Conditions {
+			if string(condition.Type) == conditionType {
+				if string(condition.Status) == status {
+					return true, fmt.Sprintf("node condition %s is %s", conditionType, status)
+				}
+				return false, fmt.Sprintf(
+					"node condition %s is %s, want %s", conditionType, condition.Status, status,
+				)
+			}
+		}
+		return false, fmt.Sprintf("node condition %s not found", conditionType)
+	}, nil
+}
+
+// newAnnotationCheck is a custom check requiring a node annotation to have
+// the given value, e.g. "annotation:example.com/bgp=enabled".
+func newAnnotationCheck(arg string, _ *Config, _ *nodeState) (func(*v1.Node) (bool, string), error) {
+	key, value, ok := strings.Cut(arg, "=")
+	if !ok || key == "" {
+		return nil, fmt.Errorf("annotation check must be in the format annotation:key=value")
+	}
+	return func(node *v1.Node) (bool, string) {
+		if node.Annotations[key] != value {
+			return false, fmt.Sprintf("node annotation %s is not %s", key, value)
+		}
+		return true, fmt.Sprintf("node annotation %s is %s", key, value)
+	}, nil
+}
+
+// newEligibilityChecks builds the eligibility chain from its specification.
+// The specification is a list of check names in evaluation order, where each
+// name may be followed by an argument after a colon, e.g. "taints:NoExecute".
+// The surrounding spaces are ignored. The checks read the node addresses
+// and Leases from the node state. The label check is required, since the
+// nodes and neighbors outside NODES_LABEL_SELECTOR are never managed, so a
+// chain without it would add neighbors that are removed right away.
+// Returns an error if a check is unknown or misconfigured, or the label
+// check is missing.
+func newEligibilityChecks(spec []string, config *Config, nodes *nodeState) (eligibilityChecks, error) {
+	var checks eligibilityChecks
+	for _, item := range spec {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(item, ":")
+		factory, ok := eligibilityCheckRegistry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown eligibility check %q", name)
+		}
+		check, err := factory(arg, config, nodes)
+		if err != nil {
+			return nil, fmt.Errorf("configuring eligibility check %q: %w", name, err)
+		}
+		checks = append(checks, eligibilityCheck{name: item, check: check})
+	}
+	if !checks.uses("label") {
+		return nil, errors.New("the label eligibility check is required, the nodes are selected by NODES_LABEL_SELECTOR")
+	}
+	return checks, nil
+}
+
+// uses checks if the chain has the check, with or without an argument.
+func (c eligibilityChecks) uses(name string) bool {
+	for _, check := range c {
+		if checkName, _, _ := strings.Cut(check.name, ":"); checkName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate runs the chain against the node in order, logging every check
+// with the logger of the node event.
+// It stops at the first failing check.
+// Returns whether the node is eligible and the name and reason of the
+// deciding check.
+func (c eligibilityChecks) evaluate(logger *log.Logger, node *v1.Node) (bool, string, string) {
+	for _, check := range c {
+		passed, reason := check.check(node)
+		logger.Debug("Eligibility check", "check", check.name, "passed", passed, "reason", reason)
+		if !passed {
+			return false, check.name, reason
+		}
+	}
+	return true, "", "all checks passed"
+}
+
+// nodeEligible checks if a node is eligible to be added to the A10 device.
+// It runs the configured eligibility chain and gets the node address. A node
+// without an address can't be peered, so it's never eligible, even if the
+// chain has no address check. Listing the address check only sets its
+// position in the chain, and so which reason a node failing several checks
+// gets.
+// The decision is logged with the logger of the node event, which carries
+// the node and the correlation ID.
+// Returns true if the node is eligible, false otherwise, the node address and
+// the reason of the decision.
+func nodeEligible(
+	logger *log.Logger,
+	nodes *nodeState,
+	node *v1.Node,
+	checks eligibilityChecks,
+) (bool, string, string) {
+	logger.Debug("Checking node eligibility")
+	eligible, check, reason := checks.evaluate(logger, node)
+	address := nodes.address(node)
+	if address == "" {
+		if eligible {
+			eligible, check, reason = false, "address", fmt.Sprintf("node has no %s address", nodes.addressType)
+		}
+	} else if eligible && !nodes.allowlist.allows(address) {
+		eligible, check, reason = false, "allowlist", fmt.Sprintf("address %s is outside the allowed CIDRs", address)
+		nodes.allowlist.refuse(logger, Neighbor{IP: address, NodeName: node.Name}, "")
+	} else if allowed, violation := nodes.subnets.check(node, address); eligible && !allowed {
+		eligible, check, reason = false, "subnet", violation
+	}
+	logger.Info(
+		"Node eligible to add to A10",
+		"eligible", eligible,
+		"check", check,
+		"reason", reason,
+	)
+	if check != "" {
+		reason = fmt.Sprintf("%s: %s", check, reason)
+	}
+	return eligible, address, reason
+}
@@ -0,0 +1,214 @@
+package manager
+
+import (
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/charmbracelet/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testLogger discards the logs of the tests.
+func testLogger() *log.Logger {
+	return log.NewWithOptions(io.Discard, log.Options{Level: log.FatalLevel})
+}
+
+// testNode creates a node with the labels and the external IP, ready unless
+// notReady.
+func testNode(name, externalIP string, labels map[string]string, notReady bool) *v1.Node {
+	status := v1.ConditionTrue
+	if notReady {
+		status = v1.ConditionFalse
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: status}},
+		},
+	}
+	if externalIP != "" {
+		node.Status.Addresses = []v1.NodeAddress{{Type: v1.NodeExternalIP, Address: externalIP}}
+	}
+	return node
+}
+
+func TestNodeEligible(t *testing.T) {
+	logger := testLogger()
+	selector, err := parseNodeSelector("bgp=a10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowlist, err := parseCIDRAllowlist("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subnets, err := parseSubnetPolicy(logger, `{"zone-a": ["10.1.0.0/16"]}`, v1.LabelTopologyZone, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit, err := parseSubnetPolicy(logger, `{"zone-a": ["10.1.0.0/16"]}`, v1.LabelTopologyZone, subnetPolicyAudit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zoneA := map[string]string{"bgp": "a10", v1.LabelTopologyZone: "zone-a"}
+
+	tests := []struct {
+		name     string
+		nodes    *nodeState
+		spec     []string
+		node     *v1.Node
+		eligible bool
+		address  string
+		reason   string
+	}{
+		{
+			name:     "eligible",
+			node:     testNode("node-1", "10.1.0.1", zoneA, false),
+			eligible: true,
+			address:  "10.1.0.1",
+			reason:   "all checks passed",
+		},
+		{
+			name:    "not ready",
+			node:    testNode("node-1", "10.1.0.1", zoneA, true),
+			address: "10.1.0.1",
+			reason:  "ready: node is not ready",
+		},
+		{
+			name:    "not labeled",
+			node:    testNode("node-1", "10.1.0.1", map[string]string{"bgp": "other"}, false),
+			address: "10.1.0.1",
+			reason:  "label: node doesn't match bgp=a10",
+		},
+		{
+			name:   "no address",
+			node:   testNode("node-1", "", zoneA, false),
+			reason: "address: node has no ExternalIP address",
+		},
+		{
+			name:   "no address without the address check",
+			spec:   []string{"ready", "label"},
+			node:   testNode("node-1", "", zoneA, false),
+			reason: "address: node has no ExternalIP address",
+		},
+		{
+			name:   "failing check is not overridden by the address",
+			spec:   []string{"ready", "label"},
+			node:   testNode("node-1", "", zoneA, true),
+			reason: "ready: node is not ready",
+		},
+		{
+			name:    "address outside the allowlist",
+			nodes:   &nodeState{allowlist: allowlist},
+			node:    testNode("node-1", "192.168.0.1", zoneA, false),
+			address: "192.168.0.1",
+			reason:  "allowlist: address 192.168.0.1 is outside the allowed CIDRs",
+		},
+		{
+			name:    "address outside the zone subnets",
+			nodes:   &nodeState{subnets: subnets},
+			node:    testNode("node-1", "10.2.0.1", zoneA, false),
+			address: "10.2.0.1",
+			reason:  "subnet: address 10.2.0.1 is outside the subnets of topology.kubernetes.io/zone zone-a",
+		},
+		{
+			name:     "subnet violation audited",
+			nodes:    &nodeState{subnets: audit},
+			node:     testNode("node-1", "10.2.0.1", zoneA, false),
+			eligible: true,
+			address:  "10.2.0.1",
+			reason:   "all checks passed",
+		},
+		{
+			name:   "invalid address is skipped",
+			node:   testNode("node-1", "not-an-ip", zoneA, false),
+			reason: "address: node has no ExternalIP address",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes := tt.nodes
+			if nodes == nil {
+				nodes = &nodeState{}
+			}
+			nodes.logger = logger
+			nodes.addressType = v1.NodeExternalIP
+			spec := tt.spec
+			if spec == nil {
+				spec = []string{"ready", "cordon", "label", "address"}
+			}
+			checks, err := newEligibilityChecks(spec, &Config{NodeSelector: selector}, nodes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			eligible, address, reason := nodeEligible(logger, nodes, tt.node, checks)
+			if eligible != tt.eligible {
+				t.Errorf("eligible = %t, want %t (reason %q)", eligible, tt.eligible, reason)
+			}
+			if address != tt.address {
+				t.Errorf("address = %q, want %q", address, tt.address)
+			}
+			if reason != tt.reason {
+				t.Errorf("reason = %q, want %q", reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestNewEligibilityChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    []string
+		checks  []string
+		wantErr bool
+	}{
+		{
+			name:   "default",
+			spec:   []string{"ready", "cordon", "label", "address"},
+			checks: []string{"ready", "cordon", "label", "address"},
+		},
+		{
+			name:   "spaces and empty items",
+			spec:   []string{" ready ", "", "label", "taints:NoExecute"},
+			checks: []string{"ready", "label", "taints:NoExecute"},
+		},
+		{
+			name:    "without label",
+			spec:    []string{"ready", "cordon", "address"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown check",
+			spec:    []string{"ready", "healthy", "label"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid argument",
+			spec:    []string{"taints:Sometimes", "label"},
+			wantErr: true,
+		},
+		{
+			name:    "condition without status",
+			spec:    []string{"condition:NetworkUnavailable", "label"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks, err := newEligibilityChecks(tt.spec, &Config{}, &nodeState{logger: testLogger()})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %t", err, tt.wantErr)
+			}
+			var names []string
+			for _, check := range checks {
+				names = append(names, check.name)
+			}
+			if !slices.Equal(names, tt.checks) {
+				t.Errorf("checks = %v, want %v", names, tt.checks)
+			}
+		})
+	}
+}
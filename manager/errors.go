@@ -0,0 +1,143 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The errors of the device operations wrap one of these sentinels when the
+// device gave a reason, whatever the backend, so the callers can branch
+// with errors.Is.
+var (
+	// ErrUnauthorized is returned when the device rejects the session or
+	// the credentials.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when the account lacks the privilege for
+	// the operation.
+	ErrForbidden = errors.New("forbidden")
+	// ErrNotFound is returned when the object doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrDeviceBusy is returned when the management plane is too busy to
+	// apply the request.
+	ErrDeviceBusy = errors.New("device is busy")
+	// ErrConflict is returned when the request conflicts with the current
+	// state of the device, e.g. the object already exists.
+	ErrConflict = errors.New("conflict")
+)
+
+// StatusError is an HTTP error response of a device. It wraps the sentinel
+// of the status code, if any, and errors.As gets the status code.
+type StatusError struct {
+	StatusCode int
+	Err        error
+}
+
+// Error formats the status code and the sentinel.
+func (e *StatusError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("HTTP request failed: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("HTTP request failed: %d: %v", e.StatusCode, e.Err)
+}
+
+// Unwrap returns the sentinel of the status code.
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// newStatusError returns the error of the HTTP status code, wrapping its
+// sentinel. A busy device is told by the caller, as aXAPI reports it in the
+// body too.
+func newStatusError(statusCode int, busy bool) error {
+	err := &StatusError{StatusCode: statusCode}
+	switch {
+	case busy:
+		err.Err = ErrDeviceBusy
+	case statusCode == http.StatusUnauthorized:
+		err.Err = ErrUnauthorized
+	case statusCode == http.StatusForbidden:
+		err.Err = ErrForbidden
+	case statusCode == http.StatusNotFound:
+		err.Err = ErrNotFound
+	case statusCode == http.StatusConflict:
+		err.Err = ErrConflict
+	}
+	return err
+}
+
+// grpcError wraps the gRPC error of a gNMI request with the sentinel of its
+// status code, if any.
+func grpcError(err error) error {
+	var sentinel error
+	switch status.Code(err) {
+	case codes.Unauthenticated:
+		sentinel = ErrUnauthorized
+	case codes.PermissionDenied:
+		sentinel = ErrForbidden
+	case codes.NotFound:
+		sentinel = ErrNotFound
+	case codes.Unavailable, codes.ResourceExhausted:
+		sentinel = ErrDeviceBusy
+	case codes.AlreadyExists, codes.Aborted, codes.FailedPrecondition:
+		sentinel = ErrConflict
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
+
+// errorClass is how a failed operation is handled.
+type errorClass int
+
+const (
+	// errorRetryable failures are transient, e.g. a busy or unreachable
+	// device, and retried with backoff
+	errorRetryable errorClass = iota
+	// errorReauth failures need a new session: the sessions are dropped
+	// and the operation is retried
+	errorReauth
+	// errorResync failures mean the cached device state is stale: the
+	// operation is dropped and the devices are re-fetched and reconciled
+	errorResync
+	// errorFatal failures can't succeed by retrying, e.g. a missing
+	// privilege: the operation is given up right away
+	errorFatal
+)
+
+// String returns the name of the class for logs and metrics.
+func (c errorClass) String() string {
+	switch c {
+	case errorReauth:
+		return "reauth"
+	case errorResync:
+		return "resync"
+	case errorFatal:
+		return "fatal"
+	default:
+		return "retryable"
+	}
+}
+
+// classifyError returns the class of the error of an operation. The error
+// of an operation on several devices gets the most severe class of them,
+// fatal before resync before reauth. Removing a missing neighbor succeeds,
+// so a not found operation means the BGP process itself is missing.
+func classifyError(err error) errorClass {
+	switch {
+	case errors.Is(err, ErrForbidden),
+		errors.Is(err, ErrNotFound),
+		errors.Is(err, errAuthLockedOut),
+		errors.Is(err, errUnexpectedSchema):
+		return errorFatal
+	case errors.Is(err, ErrConflict):
+		return errorResync
+	case errors.Is(err, ErrUnauthorized):
+		return errorReauth
+	default:
+		return errorRetryable
+	}
+}
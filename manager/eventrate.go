@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// eventRateWindow is the window the node events are counted in
+	eventRateWindow = time.Minute
+	// eventRateSmoothing is the weight of the last window in the baseline
+	eventRateSmoothing = 0.2
+	// eventRateSpikeFactor is how many times the baseline a window must
+	// count to be a spike
+	eventRateSpikeFactor = 5
+	// defaultEventSpikeMin is the fewest node events per window that can be
+	// a spike, so small clusters don't warn on a handful of events
+	defaultEventSpikeMin = 30
+)
+
+const (
+	nodeEventAdd    = "add"
+	nodeEventUpdate = "update"
+	nodeEventDelete = "delete"
+	// nodeEventResync is an update of the periodic informer resync, not a
+	// change of the node
+	nodeEventResync = "resync"
+)
+
+// eventRate counts the node events and warns when their rate spikes above
+// its moving baseline, e.g. when the API server flaps and the informer
+// relists, to tell cluster problems from device problems. It is safe for
+// concurrent use.
+type eventRate struct {
+	logger *log.Logger
+	// spikeMin is the fewest events per window that can be a spike, 0
+	// disables the warnings
+	spikeMin int
+
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+	baseline    float64
+	warned      bool
+}
+
+// observe counts the node event. Resyncs are counted, but not rated.
+func (r *eventRate) observe(event string) {
+	nodeEvents.WithLabelValues(event).Inc()
+	if r == nil || r.spikeMin == 0 || event == nodeEventResync {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if r.windowStart.IsZero() {
+		r.windowStart = now
+	}
+	if elapsed := now.Sub(r.windowStart); elapsed >= eventRateWindow {
+		// fold the finished window and the empty ones after it into the
+		// baseline
+		r.baseline += eventRateSmoothing * (float64(r.count) - r.baseline)
+		for range int(elapsed/eventRateWindow) - 1 {
+			r.baseline -= eventRateSmoothing * r.baseline
+		}
+		r.windowStart = now
+		r.count = 0
+		r.warned = false
+	}
+	r.count++
+	if !r.warned && r.count >= r.spikeMin && float64(r.count) > eventRateSpikeFactor*r.baseline {
+		r.warned = true
+		nodeEventSpikes.Inc()
+		r.logger.Warn(
+			"Node event rate spiked, the API server or the informer may be flapping",
+			"events", r.count,
+			"window", eventRateWindow,
+			"baseline", int(r.baseline),
+		)
+	}
+}
@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	eventComponent = "a10-bgp-neighbor-manager"
+	// correlationAnnotation carries the correlation ID of the node event or
+	// reconcile cycle an Event was recorded for
+	correlationAnnotation = peerAnnotationPrefix + "correlation-id"
+)
+
+// The reasons of the Events recorded on the Nodes.
+const (
+	eventReasonNeighborAdded   = "NeighborAdded"
+	eventReasonNeighborRemoved = "NeighborRemoved"
+	eventReasonSyncFailed      = "NeighborSyncFailed"
+	eventReasonFlapping        = "NeighborFlapping"
+)
+
+// nodeEventRecorder records Kubernetes Events on the Nodes of the neighbor
+// changes, annotated with their correlation ID, so kubectl describe node
+// shows what happened to the node peering and the logs of the operation can
+// be found. It is nil-safe, a nil recorder records nothing.
+type nodeEventRecorder struct {
+	recorder record.EventRecorder
+}
+
+// newNodeEventRecorder creates a recorder sending the Events to the API
+// server until the context is done.
+func newNodeEventRecorder(ctx context.Context, clientset kubernetes.Interface) *nodeEventRecorder {
+	broadcaster := record.NewBroadcaster(record.WithContext(ctx))
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	return &nodeEventRecorder{
+		recorder: broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: eventComponent}),
+	}
+}
+
+// record records an Event on the node, annotated with the correlation ID of
+// the context. Neighbors without a node, e.g. the static ones, have no
+// Events.
+func (r *nodeEventRecorder) record(
+	ctx context.Context,
+	nodeName, eventType, reason, messageFmt string,
+	args ...interface{},
+) {
+	if r == nil || nodeName == "" {
+		return
+	}
+	// The kubelet refers to the Nodes by their name as UID too
+	ref := &v1.ObjectReference{Kind: "Node", Name: nodeName, UID: types.UID(nodeName)}
+	var annotations map[string]string
+	if id := correlationID(ctx); id != "" {
+		annotations = map[string]string{correlationAnnotation: id}
+	}
+	r.recorder.AnnotatedEventf(ref, annotations, eventType, reason, messageFmt, args...)
+}
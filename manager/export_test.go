@@ -0,0 +1,145 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// The tests running the sync logic against the fake backend are in the
+// manager_test package, since the fake package imports this one. These are
+// the internals they use.
+
+const (
+	// TestRemoteAS is the remote AS of the test devices.
+	TestRemoteAS = 65001
+	// TestManagedRemoteAS is another remote AS the test devices manage.
+	TestManagedRemoteAS = 65002
+)
+
+// NewTestDevices creates a device per backend, at https://a10-<index>,
+// peering with TestRemoteAS and managing TestManagedRemoteAS, and gets their
+// neighbors.
+// Returns an error if getting the neighbors fails.
+func NewTestDevices(ctx context.Context, backends ...Backend) (*Devices, error) {
+	logger := testLogger()
+	devices := &Devices{
+		logger:    logger,
+		status:    newStatusTracker(),
+		nodeState: &nodeState{logger: logger},
+	}
+	for i, backend := range backends {
+		config := &Config{
+			logger:          logger,
+			RemoteAS:        TestRemoteAS,
+			ManagedRemoteAS: []int{TestManagedRemoteAS},
+			backendFactory:  func(string) Backend { return backend },
+		}
+		device := deviceConfig{address: fmt.Sprintf("https://a10-%d", i), as: 65000}
+		// a pre-issued token doesn't log in
+		creds := Credentials{Token: "token"}
+		devices.devices = append(devices.devices, newA10(ctx, device, creds, config))
+	}
+	return devices, devices.GetNeighbors()
+}
+
+// SetPaused pauses or resumes the writes to the devices.
+func (d *Devices) SetPaused(paused bool) {
+	if d.pause == nil {
+		d.pause = &pauseSwitch{
+			logger:  d.logger,
+			resumed: make(chan struct{}, 1),
+			lifted:  make(chan struct{}, 1),
+		}
+	}
+	d.pause.set(paused)
+}
+
+// RecordEvents records the Events of the neighbor changes, sent to the
+// returned channel.
+func (d *Devices) RecordEvents() <-chan string {
+	recorder := record.NewFakeRecorder(100)
+	d.recorder = &nodeEventRecorder{recorder: recorder}
+	return recorder.Events
+}
+
+// NewTestQueue creates a work queue of the devices applying the changes
+// without coalescing them, removing the neighbors after the removal delay
+// when scheduled. It's started by Start.
+func NewTestQueue(ctx context.Context, devices *Devices, removalDelay time.Duration) *WorkQueue {
+	return newWorkQueue(ctx, devices.logger, devices, defaultWorkers, 0, removalDelay)
+}
+
+// Depth counts the neighbors with changes left to apply.
+func (q *WorkQueue) Depth() int {
+	return q.depth()
+}
+
+// NewTestKubeNodes creates the eligible nodes of the neighbors.
+func NewTestKubeNodes(neighbors ...Neighbor) *KubeNodes {
+	kubeNodes := &KubeNodes{
+		logger:    testLogger(),
+		Neighbors: make(map[string]Neighbor, len(neighbors)),
+	}
+	for _, neighbor := range neighbors {
+		kubeNodes.Nodes = append(kubeNodes.Nodes, neighbor.IP)
+		kubeNodes.Neighbors[neighbor.IP] = neighbor
+	}
+	return kubeNodes
+}
+
+// NewTestNeighbors creates the node event handlers queueing the changes of
+// the nodes matching the selector, peered from their address of the type
+// with the remote AS of the table, as the only replica.
+// Returns an error if the selector or the table is invalid.
+func NewTestNeighbors(
+	devices *Devices,
+	queue *WorkQueue,
+	addressType v1.NodeAddressType,
+	selector, remoteASTable string,
+) (*Neighbors, error) {
+	nodeSelector, err := parseNodeSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	table, err := parseRemoteASTable(remoteASTable)
+	if err != nil {
+		return nil, err
+	}
+	nodes := &nodeState{
+		logger:      devices.logger,
+		addressType: addressType,
+		claims:      newAddressClaims(devices.logger, false),
+		remoteAS:    table,
+	}
+	config := &Config{logger: devices.logger, NodeSelector: nodeSelector}
+	checks, err := newEligibilityChecks([]string{"ready", "label", "address"}, config, nodes)
+	if err != nil {
+		return nil, err
+	}
+	return &Neighbors{
+		logger:    devices.logger,
+		ctx:       context.Background(),
+		queue:     queue,
+		status:    devices.status,
+		sharder:   &Sharder{},
+		nodeState: nodes,
+		selector:  nodeSelector,
+		checks:    checks,
+	}, nil
+}
+
+// Update handles the update event of the node.
+func (n *Neighbors) Update(oldNode, node *v1.Node) {
+	n.update(oldNode, node)
+}
+
+// ReconcileNeighbors queues the changes that make the devices match the
+// nodes, as the only replica.
+// Returns the neighbors that were queued.
+func ReconcileNeighbors(devices *Devices, kubeNodes *KubeNodes, queue *WorkQueue) []string {
+	return reconcileNeighbors(devices, kubeNodes, queue, &Sharder{})
+}
@@ -0,0 +1,193 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/charmbracelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	addressSourceLabel      = "label"
+	addressSourceAnnotation = "annotation"
+	addressSourceEC2        = "ec2"
+	addressSourceGCE        = "gce"
+	// providerAddressTTL is how long an address resolved from the cloud
+	// provider is cached
+	providerAddressTTL = 10 * time.Minute
+	// providerFailureTTL is how long a failed resolution is cached, so the
+	// node events don't hammer the cloud provider API
+	providerFailureTTL = time.Minute
+	gceInstanceURL     = "https://compute.googleapis.com/compute/v1/projects/%s/zones/%s/instances/%s"
+)
+
+// providerAddress is an address resolved from the cloud provider.
+type providerAddress struct {
+	address string
+	expires time.Time
+}
+
+// addressFallback resolves the node address from a node label or
+// annotation, or from the cloud provider API by the node provider ID, for
+// managed node pools that don't publish external IPs. It is safe for
+// concurrent use.
+type addressFallback struct {
+	logger *log.Logger
+	source string
+	// key is the label or annotation holding the address
+	key string
+	// resolve gets the address of the instance of the provider ID
+	resolve func(ctx context.Context, providerID string) (string, error)
+
+	mu    sync.Mutex
+	cache map[string]providerAddress
+}
+
+// parseAddressFallback parses the fallback: label:<key>, annotation:<key>,
+// ec2 or gce.
+// Returns nil if the fallback is empty.
+// Returns an error if the fallback is invalid or its client can't be
+// configured.
+func parseAddressFallback(ctx context.Context, logger *log.Logger, raw string) (*addressFallback, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	source, key, _ := strings.Cut(raw, ":")
+	fallback := &addressFallback{logger: logger, source: source, key: key, cache: map[string]providerAddress{}}
+	switch source {
+	case addressSourceLabel, addressSourceAnnotation:
+		if key == "" {
+			return nil, fmt.Errorf("%s fallback needs a key, e.g. %s:example.com/public-ip", source, source)
+		}
+	case addressSourceEC2:
+		cfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("loading AWS config: %w", err)
+		}
+		fallback.resolve = ec2Resolver(ec2.NewFromConfig(cfg))
+	case addressSourceGCE:
+		fallback.resolve = gceResolver(gcpClient{client: &http.Client{Timeout: defaultTimeout}})
+	default:
+		return nil, fmt.Errorf("unknown address fallback %q", raw)
+	}
+	return fallback, nil
+}
+
+// String returns the fallback as configured.
+func (f *addressFallback) String() string {
+	if f == nil {
+		return ""
+	}
+	if f.key == "" {
+		return f.source
+	}
+	return f.source + ":" + f.key
+}
+
+// address resolves the address of the node.
+// Returns an empty string if it can't be resolved.
+func (f *addressFallback) address(node *v1.Node) string {
+	if f == nil {
+		return ""
+	}
+	switch f.source {
+	case addressSourceLabel:
+		return node.Labels[f.key]
+	case addressSourceAnnotation:
+		return node.Annotations[f.key]
+	}
+	providerID := node.Spec.ProviderID
+	if providerID == "" {
+		return ""
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if cached, ok := f.cache[providerID]; ok && time.Now().Before(cached.expires) {
+		return cached.address
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	address, err := f.resolve(ctx, providerID)
+	ttl := providerAddressTTL
+	if err != nil {
+		f.logger.Error(
+			"Error resolving node address from the cloud provider",
+			"node", node.Name,
+			"providerID", providerID,
+			"source", f.source,
+			"error", err,
+		)
+		ttl = providerFailureTTL
+	}
+	f.cache[providerID] = providerAddress{address: address, expires: time.Now().Add(ttl)}
+	return address
+}
+
+// ec2Resolver resolves the public IP of the EC2 instance of the provider
+// ID, aws:///<zone>/<instance>.
+func ec2Resolver(client *ec2.Client) func(context.Context, string) (string, error) {
+	return func(ctx context.Context, providerID string) (string, error) {
+		if !strings.HasPrefix(providerID, "aws://") {
+			return "", fmt.Errorf("not an AWS provider ID")
+		}
+		instanceID := providerID[strings.LastIndex(providerID, "/")+1:]
+		out, err := client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+			InstanceIds: []string{instanceID},
+		})
+		if err != nil {
+			return "", fmt.Errorf("describing instance %s: %w", instanceID, err)
+		}
+		for _, reservation := range out.Reservations {
+			for _, instance := range reservation.Instances {
+				if address := aws.ToString(instance.PublicIpAddress); address != "" {
+					return address, nil
+				}
+			}
+		}
+		return "", nil
+	}
+}
+
+// gceResolver resolves the external IP of the GCE instance of the provider
+// ID, gce://<project>/<zone>/<instance>.
+func gceResolver(client gcpClient) func(context.Context, string) (string, error) {
+	return func(ctx context.Context, providerID string) (string, error) {
+		parts := strings.Split(strings.TrimPrefix(providerID, "gce://"), "/")
+		if !strings.HasPrefix(providerID, "gce://") || len(parts) != 3 {
+			return "", fmt.Errorf("not a GCE provider ID")
+		}
+		token, err := client.accessToken(ctx)
+		if err != nil {
+			return "", fmt.Errorf("getting GCP access token: %w", err)
+		}
+		var instance struct {
+			NetworkInterfaces []struct {
+				AccessConfigs []struct {
+					NatIP string `json:"natIP"`
+				} `json:"accessConfigs"`
+			} `json:"networkInterfaces"`
+		}
+		header := http.Header{"Authorization": {fmt.Sprintf("Bearer %s", token.Reveal())}}
+		url := fmt.Sprintf(gceInstanceURL, parts[0], parts[1], parts[2])
+		if err := client.get(ctx, url, header, &instance); err != nil {
+			return "", fmt.Errorf("getting instance %s: %w", parts[2], err)
+		}
+		for _, networkInterface := range instance.NetworkInterfaces {
+			for _, accessConfig := range networkInterface.AccessConfigs {
+				if accessConfig.NatIP != "" {
+					return accessConfig.NatIP, nil
+				}
+			}
+		}
+		return "", nil
+	}
+}
@@ -0,0 +1,233 @@
+// Package fake provides an in-memory A10 device implementing the
+// manager.Backend interface, to run the manager and exercise its sync
+// logic without a device:
+//
+//	devices := fake.NewFleet()
+//	m, err := manager.New(manager.WithBackend(devices.Backend), ...)
+//
+// Failures are injected with hooks, e.g. to fail the next neighbor
+// addition with a busy device:
+//
+//	devices.Device("https://a10").FailNext(fake.OpAddNeighbor, manager.ErrDeviceBusy)
+package fake
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rgeraskin/a10-bgp-neighbor-manager/manager"
+)
+
+// Op is an operation of the device.
+type Op string
+
+const (
+	OpNeighbors      Op = "neighbors"
+	OpAddNeighbor    Op = "add"
+	OpRemoveNeighbor Op = "remove"
+)
+
+// Call is an operation made on the device. NeighborIP and RemoteAS are
+// empty for OpNeighbors, RemoteAS for OpRemoveNeighbor.
+type Call struct {
+	Op         Op
+	NeighborIP string
+	RemoteAS   int
+	// Err is the error the operation returned
+	Err error
+}
+
+// Hook is called before every operation of the device. An error fails the
+// operation without applying it.
+type Hook func(ctx context.Context, call Call) error
+
+// Device is an in-memory A10 device. It is safe for concurrent use.
+type Device struct {
+	mu        sync.Mutex
+	neighbors map[string]int
+	calls     []Call
+	hooks     []Hook
+	failures  map[Op][]error
+	latency   time.Duration
+}
+
+var _ manager.Backend = (*Device)(nil)
+
+// NewDevice creates a device with the neighbors.
+func NewDevice(neighbors ...manager.BackendNeighbor) *Device {
+	d := &Device{
+		neighbors: map[string]int{},
+		failures:  map[Op][]error{},
+	}
+	for _, neighbor := range neighbors {
+		d.neighbors[neighbor.IP] = neighbor.RemoteAS
+	}
+	return d
+}
+
+// OnCall adds a hook called before every operation, e.g. to fail the
+// operations of a neighbor or to block until the test proceeds.
+func (d *Device) OnCall(hook Hook) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks = append(d.hooks, hook)
+}
+
+// FailNext fails the next operations of the kind with the errors, one per
+// operation, in order.
+func (d *Device) FailNext(op Op, errs ...error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.failures[op] = append(d.failures[op], errs...)
+}
+
+// SetLatency delays every operation, returning the context error if it's
+// done first.
+func (d *Device) SetLatency(latency time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.latency = latency
+}
+
+// Reset clears the hooks, the pending failures, the latency and the calls,
+// keeping the neighbors.
+func (d *Device) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hooks = nil
+	d.failures = map[Op][]error{}
+	d.latency = 0
+	d.calls = nil
+}
+
+// SetNeighbor configures the neighbor as if by the operator, without
+// recording a call.
+func (d *Device) SetNeighbor(neighborIP string, remoteAS int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.neighbors[neighborIP] = remoteAS
+}
+
+// DeleteNeighbor removes the neighbor as if by the operator, without
+// recording a call.
+func (d *Device) DeleteNeighbor(neighborIP string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.neighbors, neighborIP)
+}
+
+// State returns the configured neighbors mapped to their remote AS.
+func (d *Device) State() map[string]int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return maps.Clone(d.neighbors)
+}
+
+// Calls returns the operations made on the device, in order.
+func (d *Device) Calls() []Call {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.calls)
+}
+
+// Neighbors gets the neighbors of the device, sorted by address.
+func (d *Device) Neighbors(ctx context.Context) ([]manager.BackendNeighbor, error) {
+	var neighbors []manager.BackendNeighbor
+	err := d.do(ctx, Call{Op: OpNeighbors}, func() {
+		for _, ip := range slices.Sorted(maps.Keys(d.neighbors)) {
+			neighbors = append(neighbors, manager.BackendNeighbor{IP: ip, RemoteAS: d.neighbors[ip]})
+		}
+	})
+	return neighbors, err
+}
+
+// AddNeighbor configures the neighbor with its remote AS, replacing the
+// remote AS of an existing one.
+func (d *Device) AddNeighbor(ctx context.Context, neighborIP string, remoteAS int) error {
+	call := Call{Op: OpAddNeighbor, NeighborIP: neighborIP, RemoteAS: remoteAS}
+	return d.do(ctx, call, func() {
+		d.neighbors[neighborIP] = remoteAS
+	})
+}
+
+// RemoveNeighbor removes the neighbor, succeeding if it doesn't exist.
+func (d *Device) RemoveNeighbor(ctx context.Context, neighborIP string) error {
+	return d.do(ctx, Call{Op: OpRemoveNeighbor, NeighborIP: neighborIP}, func() {
+		delete(d.neighbors, neighborIP)
+	})
+}
+
+// do runs the hooks and the pending failure of the operation, then applies
+// it with the lock held and records the call.
+func (d *Device) do(ctx context.Context, call Call, apply func()) error {
+	d.mu.Lock()
+	hooks := slices.Clone(d.hooks)
+	latency := d.latency
+	var err error
+	if failures := d.failures[call.Op]; len(failures) > 0 {
+		err = failures[0]
+		d.failures[call.Op] = failures[1:]
+	}
+	d.mu.Unlock()
+
+	if err == nil && latency > 0 {
+		timer := time.NewTimer(latency)
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+	for _, hook := range hooks {
+		if err != nil {
+			break
+		}
+		err = hook(ctx, call)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		apply()
+	}
+	call.Err = err
+	d.calls = append(d.calls, call)
+	return err
+}
+
+// Fleet is a set of in-memory devices created on demand by their address.
+// It is safe for concurrent use.
+type Fleet struct {
+	mu      sync.Mutex
+	devices map[string]*Device
+}
+
+// NewFleet creates an empty fleet.
+func NewFleet() *Fleet {
+	return &Fleet{devices: map[string]*Device{}}
+}
+
+// Backend returns the device at the address, creating it if needed. It's
+// the manager.BackendFactory of the fleet.
+func (f *Fleet) Backend(address string) manager.Backend {
+	return f.Device(address)
+}
+
+// Device returns the device at the address, creating it if needed.
+func (f *Fleet) Device(address string) *Device {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.devices[address]
+	if !ok {
+		d = NewDevice()
+		f.devices[address] = d
+	}
+	return d
+}
@@ -0,0 +1,119 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	defaultFlapWindow   = 10 * time.Minute
+	defaultFlapCooldown = 30 * time.Minute
+)
+
+// flapHistory is the add/remove churn of a neighbor: its last desired
+// presence and the times it changed within the window.
+type flapHistory struct {
+	present bool
+	changes []time.Time
+}
+
+// flapDetector tracks the add/remove churn of the neighbors and reports
+// the ones changing more than threshold times within the window, so the
+// unstable nodes can be quarantined for the cool-down. It is safe for
+// concurrent use.
+type flapDetector struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	neighbors map[string]*flapHistory
+}
+
+// newFlapDetector creates a flap detector.
+// Returns nil if the threshold is 0.
+func newFlapDetector(threshold int, window time.Duration, cooldown time.Duration) *flapDetector {
+	if threshold == 0 {
+		return nil
+	}
+	return &flapDetector{
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		neighbors: map[string]*flapHistory{},
+	}
+}
+
+// record records the desired presence of the neighbor. Only changes of the
+// presence count, so retries and reconciles of the same state don't.
+// Returns the number of changes within the window if the neighbor flaps,
+// 0 otherwise.
+func (f *flapDetector) record(neighborIP string, present bool) int {
+	if f == nil {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	history, ok := f.neighbors[neighborIP]
+	if !ok {
+		f.neighbors[neighborIP] = &flapHistory{present: present}
+		return 0
+	}
+	if history.present == present {
+		return 0
+	}
+	history.present = present
+	now := time.Now()
+	recent := history.changes[:0]
+	for _, change := range history.changes {
+		if now.Sub(change) < f.window {
+			recent = append(recent, change)
+		}
+	}
+	history.changes = append(recent, now)
+	changes := len(history.changes)
+	if changes <= f.threshold {
+		return 0
+	}
+	// Start over, the quarantine covers the cool-down
+	history.changes = nil
+	return changes
+}
+
+// flapEvent is the notification of a quarantined flapping neighbor.
+type flapEvent struct {
+	Neighbor string    `json:"neighbor"`
+	Node     string    `json:"node,omitempty"`
+	Changes  int       `json:"changes"`
+	Window   string    `json:"window"`
+	Until    time.Time `json:"until"`
+}
+
+// checkFlapping records the change of the neighbor about to be applied and
+// quarantines it for the cool-down once it flaps, with a warning Event on
+// its node. Quarantined neighbors aren't added, so the neighbor is left
+// removed.
+func (d *Devices) checkFlapping(ctx context.Context, neighbor Neighbor, present bool) {
+	changes := d.flaps.record(neighbor.IP, present)
+	if changes == 0 {
+		return
+	}
+	flappingNeighbors.Inc()
+	reason := fmt.Sprintf("flapped %d times within %s", changes, d.flaps.window)
+	until := d.quarantine.addFor(neighbor, reason, d.flaps.cooldown)
+	d.recorder.record(
+		ctx, neighbor.NodeName, v1.EventTypeWarning, eventReasonFlapping,
+		"BGP neighbor %s %s, quarantined until %s", neighbor.IP, reason, until.UTC().Format(time.RFC3339),
+	)
+	d.notifier.notify(ctx, "flap", flapEvent{
+		Neighbor: neighbor.IP,
+		Node:     neighbor.NodeName,
+		Changes:  changes,
+		Window:   d.flaps.window.String(),
+		Until:    until,
+	})
+}
@@ -0,0 +1,271 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	backendGNMI = "gnmi"
+
+	// defaultGNMIPort is the IANA port of gNMI
+	defaultGNMIPort            = 9339
+	defaultGNMINetworkInstance = "default"
+	defaultGNMIProtocol        = "BGP"
+)
+
+// gnmiOptions locate the BGP neighbors in the OpenConfig tree of the
+// devices.
+type gnmiOptions struct {
+	port            int
+	networkInstance string
+	// protocol is the name of the BGP protocol instance
+	protocol string
+}
+
+// gnmiBackend programs the OpenConfig BGP neighbors with gNMI, so the
+// controller can peer the nodes with SONiC, Arista or Nokia ToR switches.
+// It authenticates with the username and password metadata, so pre-issued
+// tokens aren't supported.
+type gnmiBackend struct {
+	// address is the host:port of the gNMI server
+	address     string
+	options     gnmiOptions
+	credentials func() Credentials
+	client      gpb.GNMIClient
+	// err is the error creating the client, returned by every operation
+	err error
+}
+
+// newGNMIBackend creates the gNMI backend of the device at the address, a
+// URL like https://a10 or a host name. The connection is made on the first
+// operation, with the TLS config of the device.
+func newGNMIBackend(a *A10, options gnmiOptions) *gnmiBackend {
+	host := a.address
+	if u, err := url.Parse(a.address); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	g := &gnmiBackend{
+		address:     net.JoinHostPort(host, strconv.Itoa(options.port)),
+		options:     options,
+		credentials: a.currentCredentials,
+	}
+	conn, err := grpc.NewClient(
+		g.address,
+		grpc.WithTransportCredentials(credentials.NewTLS(a.tlsConfig())),
+	)
+	if err != nil {
+		g.err = fmt.Errorf("creating gNMI client of %s: %w", g.address, err)
+		return g
+	}
+	g.client = gpb.NewGNMIClient(conn)
+	return g
+}
+
+// requestContext returns the context of a request, with the credentials
+// and a timeout.
+func (g *gnmiBackend) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	creds := g.credentials()
+	ctx = metadata.AppendToOutgoingContext(
+		ctx,
+		"username", creds.Username,
+		"password", creds.Password.Reveal(),
+	)
+	return context.WithTimeout(ctx, defaultTimeout)
+}
+
+// neighborsPath returns the path of the BGP neighbors, or of the neighbor
+// if the IP is set.
+func (g *gnmiBackend) neighborsPath(neighborIP string) *gpb.Path {
+	elems := []*gpb.PathElem{
+		{Name: "network-instances"},
+		{Name: "network-instance", Key: map[string]string{"name": g.options.networkInstance}},
+		{Name: "protocols"},
+		{
+			Name: "protocol",
+			Key:  map[string]string{"identifier": "BGP", "name": g.options.protocol},
+		},
+		{Name: "bgp"},
+		{Name: "neighbors"},
+	}
+	if neighborIP != "" {
+		elems = append(elems, &gpb.PathElem{
+			Name: "neighbor",
+			Key:  map[string]string{"neighbor-address": neighborIP},
+		})
+	}
+	return &gpb.Path{Origin: "openconfig", Elem: elems}
+}
+
+// neighbors gets the configured neighbors and their peer AS.
+// Returns an error if the operation fails.
+func (g *gnmiBackend) neighbors(ctx context.Context) ([]ipv4Neighbor, error) {
+	if g.err != nil {
+		return nil, g.err
+	}
+	ctx, cancel := g.requestContext(ctx)
+	defer cancel()
+	resp, err := g.client.Get(ctx, &gpb.GetRequest{
+		Path:     []*gpb.Path{g.neighborsPath("")},
+		Type:     gpb.GetRequest_CONFIG,
+		Encoding: gpb.Encoding_JSON_IETF,
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting neighbors: %w", grpcError(err))
+	}
+
+	var neighbors []ipv4Neighbor
+	for _, notification := range resp.GetNotification() {
+		for _, update := range notification.GetUpdate() {
+			found, err := parseGNMINeighbors(update)
+			if err != nil {
+				return nil, err
+			}
+			neighbors = append(neighbors, found...)
+		}
+	}
+	return neighbors, nil
+}
+
+// parseGNMINeighbors parses the neighbors of an update, either the
+// neighbors container or a single neighbor.
+// Returns an error if the value isn't JSON.
+func parseGNMINeighbors(update *gpb.Update) ([]ipv4Neighbor, error) {
+	raw := update.GetVal().GetJsonIetfVal()
+	if raw == nil {
+		raw = update.GetVal().GetJsonVal()
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, fmt.Errorf("unmarshaling neighbors: %w", err)
+	}
+	value = unqualifyJSON(value)
+
+	elems := update.GetPath().GetElem()
+	if len(elems) > 0 && elems[len(elems)-1].GetName() == "neighbor" {
+		item, _ := value.(map[string]interface{})
+		neighbor, ok := gnmiNeighbor(item, elems[len(elems)-1].GetKey()["neighbor-address"])
+		if !ok {
+			return nil, nil
+		}
+		return []ipv4Neighbor{neighbor}, nil
+	}
+
+	container, _ := value.(map[string]interface{})
+	if neighbors, ok := container["neighbors"].(map[string]interface{}); ok {
+		container = neighbors
+	}
+	items, _ := container["neighbor"].([]interface{})
+	neighbors := make([]ipv4Neighbor, 0, len(items))
+	for _, item := range items {
+		item, _ := item.(map[string]interface{})
+		if neighbor, ok := gnmiNeighbor(item, ""); ok {
+			neighbors = append(neighbors, neighbor)
+		}
+	}
+	return neighbors, nil
+}
+
+// gnmiNeighbor returns the address and peer AS of the neighbor item, the
+// address defaulting to the path key.
+func gnmiNeighbor(item map[string]interface{}, address string) (ipv4Neighbor, bool) {
+	config, _ := item["config"].(map[string]interface{})
+	if ip, ok := item["neighbor-address"].(string); ok {
+		address = ip
+	}
+	if address == "" {
+		return ipv4Neighbor{}, false
+	}
+	neighbor := ipv4Neighbor{NeighborIPV4: address}
+	// uint32 leaves are numbers in JSON_IETF, some devices quote them
+	switch peerAS := config["peer-as"].(type) {
+	case float64:
+		neighbor.RemoteAS = int(peerAS)
+	case string:
+		neighbor.RemoteAS, _ = strconv.Atoi(peerAS)
+	}
+	return neighbor, true
+}
+
+// unqualifyJSON strips the module names from the JSON_IETF member names,
+// like openconfig-network-instance:neighbor.
+func unqualifyJSON(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		unqualified := make(map[string]interface{}, len(value))
+		for key, member := range value {
+			if i := strings.LastIndex(key, ":"); i >= 0 {
+				key = key[i+1:]
+			}
+			unqualified[key] = unqualifyJSON(member)
+		}
+		return unqualified
+	case []interface{}:
+		for i := range value {
+			value[i] = unqualifyJSON(value[i])
+		}
+	}
+	return value
+}
+
+// addNeighbor configures the neighbor with its peer AS.
+// Returns an error if the operation fails.
+func (g *gnmiBackend) addNeighbor(ctx context.Context, neighborIP string, remoteAS int) error {
+	if g.err != nil {
+		return g.err
+	}
+	raw, err := json.Marshal(map[string]interface{}{
+		"neighbor-address": neighborIP,
+		"config": map[string]interface{}{
+			"neighbor-address": neighborIP,
+			"peer-as":          remoteAS,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling neighbor: %w", err)
+	}
+	ctx, cancel := g.requestContext(ctx)
+	defer cancel()
+	_, err = g.client.Set(ctx, &gpb.SetRequest{
+		Update: []*gpb.Update{{
+			Path: g.neighborsPath(neighborIP),
+			Val:  &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: raw}},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("setting neighbor: %w", grpcError(err))
+	}
+	return nil
+}
+
+// removeNeighbor deletes the neighbor, deleting a missing one succeeds.
+// Returns an error if the operation fails.
+func (g *gnmiBackend) removeNeighbor(ctx context.Context, neighborIP string) error {
+	if g.err != nil {
+		return g.err
+	}
+	ctx, cancel := g.requestContext(ctx)
+	defer cancel()
+	_, err := g.client.Set(ctx, &gpb.SetRequest{
+		Delete: []*gpb.Path{g.neighborsPath(neighborIP)},
+	})
+	if err != nil {
+		return fmt.Errorf("deleting neighbor: %w", grpcError(err))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	healthOK       = "ok"
+	healthStarting = "starting"
+	healthDegraded = "degraded"
+)
+
+// healthState collects the health of the controller components as they
+// start, for the health endpoint. It is safe for concurrent use.
+type healthState struct {
+	mu            sync.RWMutex
+	informer      cache.InformerSynced
+	devices       *Devices
+	queue         *WorkQueue
+	lastReconcile time.Time
+}
+
+// deviceHealth is the health of the connection to a device.
+type deviceHealth struct {
+	Reachable    bool `json:"reachable"`
+	SessionValid bool `json:"sessionValid"`
+}
+
+// healthReport is the health endpoint payload.
+type healthReport struct {
+	Status         string                  `json:"status"`
+	InformerSynced bool                    `json:"informerSynced"`
+	Devices        map[string]deviceHealth `json:"devices"`
+	LastReconcile  time.Time               `json:"lastReconcile,omitempty"`
+	ReconcileAge   string                  `json:"reconcileAge,omitempty"`
+	QueueDepth     int                     `json:"queueDepth"`
+	PendingChanges int                     `json:"pendingChanges"`
+}
+
+// setInformer records the node informer to report its sync state.
+func (h *healthState) setInformer(synced cache.InformerSynced) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.informer = synced
+}
+
+// setDevices records the devices to report their connection state.
+func (h *healthState) setDevices(devices *Devices) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.devices = devices
+}
+
+// setQueue records the work queue to report its depth.
+func (h *healthState) setQueue(queue *WorkQueue) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.queue = queue
+}
+
+// reconciled records a finished reconcile.
+func (h *healthState) reconciled() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastReconcile = time.Now()
+}
+
+// report returns the health of the components. The status is starting
+// until the informer syncs and the first reconcile is done, and degraded
+// while a device is unreachable.
+func (h *healthState) report() healthReport {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	report := healthReport{
+		Status:        healthOK,
+		Devices:       map[string]deviceHealth{},
+		LastReconcile: h.lastReconcile,
+	}
+	report.InformerSynced = h.informer != nil && h.informer()
+	if h.devices != nil {
+		for _, a10 := range h.devices.devices {
+			health := deviceHealth{
+				Reachable:    !a10.unreachable.Load(),
+				SessionValid: a10.sessionValid(),
+			}
+			if !health.Reachable {
+				report.Status = healthDegraded
+			}
+			report.Devices[a10.address] = health
+		}
+	}
+	if !h.lastReconcile.IsZero() {
+		report.ReconcileAge = time.Since(h.lastReconcile).Round(time.Second).String()
+	}
+	if h.queue != nil {
+		report.QueueDepth = h.queue.queue.Len()
+		report.PendingChanges = h.queue.depth()
+	}
+	if !report.InformerSynced || h.lastReconcile.IsZero() {
+		report.Status = healthStarting
+	}
+	return report
+}
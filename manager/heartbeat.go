@@ -0,0 +1,172 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// heartbeat renews a Lease and pings a monitoring URL after every finished
+// reconcile, so external watchdogs can alert when the controller is alive
+// but stopped making progress. The beats are sent in the background and
+// coalesced, a slow target never delays a reconcile.
+type heartbeat struct {
+	logger *log.Logger
+	// lease is the renewed Lease if set
+	lease     string
+	namespace string
+	holder    string
+	// duration is the leaseDurationSeconds advertised to the watchdogs, 0
+	// leaves it unset
+	duration time.Duration
+	// url is the pinged URL if set
+	url    string
+	client *http.Client
+
+	clientset kubernetes.Interface
+	beats     chan struct{}
+}
+
+// newHeartbeat creates a heartbeat renewing the Lease, "namespace/name" or
+// a name in the controller namespace, and pinging the URL.
+// Returns nil if both are empty, or an error if the Lease reference is
+// incomplete.
+func newHeartbeat(
+	logger *log.Logger,
+	lease, defaultNamespace, url string,
+	duration time.Duration,
+) (*heartbeat, error) {
+	if lease == "" && url == "" {
+		return nil, nil
+	}
+	h := &heartbeat{
+		logger:   logger,
+		url:      url,
+		duration: duration,
+		holder:   metricsInstance(defaultMetricsPushJob),
+		client:   &http.Client{Timeout: defaultWebhookTimeout},
+		beats:    make(chan struct{}, 1),
+	}
+	if lease != "" {
+		ref, err := parseConfigMapRef(lease, defaultNamespace)
+		if err != nil {
+			return nil, err
+		}
+		h.namespace, h.lease = ref.namespace, ref.name
+	}
+	return h, nil
+}
+
+// start sends the beats in the background until the context is done.
+func (h *heartbeat) start(ctx context.Context, clientset kubernetes.Interface) {
+	if h == nil {
+		return
+	}
+	h.clientset = clientset
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-h.beats:
+				h.send(ctx)
+			}
+		}
+	}()
+}
+
+// beat requests a heartbeat.
+func (h *heartbeat) beat() {
+	if h == nil {
+		return
+	}
+	select {
+	case h.beats <- struct{}{}:
+	default:
+	}
+}
+
+// send renews the Lease and pings the URL. Failures are logged and
+// counted, the next reconcile beats again.
+func (h *heartbeat) send(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, defaultWebhookTimeout)
+	defer cancel()
+	if h.lease != "" {
+		if err := h.renew(ctx); err != nil {
+			heartbeatFailures.WithLabelValues("lease").Inc()
+			h.logger.Error("Error renewing heartbeat Lease", "lease", h.namespace+"/"+h.lease, "error", err)
+		} else {
+			lastHeartbeat.WithLabelValues("lease").SetToCurrentTime()
+		}
+	}
+	if h.url != "" {
+		if err := h.ping(ctx); err != nil {
+			heartbeatFailures.WithLabelValues("url").Inc()
+			h.logger.Error("Error pinging heartbeat URL", "url", h.url, "error", err)
+		} else {
+			lastHeartbeat.WithLabelValues("url").SetToCurrentTime()
+		}
+	}
+}
+
+// renew sets the renew time of the Lease to now, creating it if needed.
+// Returns an error if the operation fails.
+func (h *heartbeat) renew(ctx context.Context) error {
+	leases := h.clientset.CoordinationV1().Leases(h.namespace)
+	now := metav1.NewMicroTime(time.Now())
+	holder := h.holder
+	spec := coordinationv1.LeaseSpec{
+		HolderIdentity: &holder,
+		RenewTime:      &now,
+	}
+	if h.duration > 0 {
+		seconds := int32(h.duration.Seconds())
+		spec.LeaseDurationSeconds = &seconds
+	}
+	lease, err := leases.Get(ctx, h.lease, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		spec.AcquireTime = &now
+		_, err = leases.Create(ctx, &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: h.lease, Namespace: h.namespace},
+			Spec:       spec,
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	spec.AcquireTime = lease.Spec.AcquireTime
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != h.holder {
+		spec.AcquireTime = &now
+	}
+	lease.Spec = spec
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+// ping gets the URL, expecting a 2xx answer.
+// Returns an error if the operation fails.
+func (h *heartbeat) ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", h.url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
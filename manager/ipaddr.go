@@ -0,0 +1,34 @@
+package manager
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// parseNeighborIP validates a neighbor address and returns its canonical
+// form. The devices peer with IPv4 unicast addresses, so zones, masks,
+// IPv6 and IPv6-mapped IPv4 addresses are rejected rather than turned into
+// a broken neighbor entry.
+// Returns an error if the address is invalid.
+func parseNeighborIP(address string) (string, error) {
+	if strings.Contains(address, "/") {
+		return "", fmt.Errorf("address %q has a mask", address)
+	}
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %w", address, err)
+	}
+	switch {
+	case addr.Zone() != "":
+		return "", fmt.Errorf("address %q has a zone", address)
+	case addr.Is4In6():
+		return "", fmt.Errorf("address %q is an IPv6-mapped IPv4 address", address)
+	case !addr.Is4():
+		return "", fmt.Errorf("address %q is not an IPv4 address", address)
+	case addr.IsUnspecified(), addr.IsLoopback(), addr.IsMulticast(),
+		addr == netip.AddrFrom4([4]byte{255, 255, 255, 255}):
+		return "", fmt.Errorf("address %q is not a unicast address", address)
+	}
+	return addr.String(), nil
+}
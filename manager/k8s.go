@@ -0,0 +1,471 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// defaultInformerResync is how often the node informer replays the cached
+// nodes to the handlers by default.
+const defaultInformerResync = 10 * time.Minute
+
+type Neighbors struct {
+	logger    *log.Logger
+	ctx       context.Context
+	clientset *kubernetes.Clientset
+	lister    corelisters.NodeLister
+	synced    cache.InformerSynced
+	queue     *WorkQueue
+	status    *statusTracker
+	sharder   *Sharder
+	nodeState *nodeState
+	selector  nodeSelector
+	checks    eligibilityChecks
+	asn       *asnChecker
+	peers     peerSource
+	static    staticNeighbors
+	overrides *peerOverrides
+	events    *eventRate
+	watch     *watchHealth
+	// resyncPeriod is how often the informer replays the cached nodes to
+	// the handlers, 0 disables it
+	resyncPeriod time.Duration
+}
+
+type InformerManager interface {
+	StartInformer() error
+	add(obj interface{})
+	update(_ interface{}, obj interface{})
+	delete(obj interface{})
+}
+
+// add adds a new node to the A10 device.
+// It first checks if the node is eligible, and if so,
+// adds the node to the A10 device.
+func (n *Neighbors) add(obj interface{}) {
+	n.events.observe(nodeEventAdd)
+	n.watch.seen()
+	node := obj.(*v1.Node)
+	id := newCorrelationID()
+	logger := n.logger.With(
+		"node", node.Name,
+		"correlationID", id,
+	)
+	n.sharder.observe(node, n.nodeState)
+	if !n.sharder.ownsNode(node.Name) {
+		logger.Debug("Node is managed by another shard, skipping add event")
+		return
+	}
+	if !n.nodeState.inScope(node) {
+		logger.Debug("Node is out of the managed regions and zones, skipping add event")
+		return
+	}
+	logger.Info("Node add event")
+	n.asn.check(node)
+	eligible, neighbor, reason := desiredNeighbor(logger, n.nodeState, node, n.checks, n.peers)
+	n.status.setNode(node.Name, nodeStatus{
+		Address:  neighbor.IP,
+		Eligible: eligible,
+		Reason:   reason,
+	})
+	if eligible && n.overrides.removes(neighbor.IP) {
+		logger.Info("Neighbor is removed by the peer overrides, skipping add")
+		return
+	}
+	if eligible {
+		logger.Info("Node should be added")
+		n.queue.AddNeighbor(neighbor, id)
+	}
+}
+
+// update updates a node in the A10 device.
+// It first checks if the node is eligible, and if so,
+// adds the node to the A10 device.
+// If the node is not eligible, it removes the node from the A10 device.
+// If the address of the node changed, the neighbor of its old address is
+// removed.
+func (n *Neighbors) update(oldObj interface{}, obj interface{}) {
+	node := obj.(*v1.Node)
+	oldNode := oldObj.(*v1.Node)
+	n.watch.seen()
+	if oldNode.ResourceVersion == node.ResourceVersion {
+		n.events.observe(nodeEventResync)
+	} else {
+		n.events.observe(nodeEventUpdate)
+	}
+	id := newCorrelationID()
+	logger := n.logger.With(
+		"node", node.Name,
+		"correlationID", id,
+	)
+	if peerAnnotationsUpdate(oldNode, node) {
+		logger.Debug("Only the peering annotations of the node changed, skipping update event")
+		return
+	}
+	n.sharder.observe(node, n.nodeState)
+	if !n.sharder.ownsNode(node.Name) {
+		logger.Debug("Node is managed by another shard, skipping update event")
+		return
+	}
+	if !n.nodeState.inScope(node) {
+		logger.Debug("Node is out of the managed regions and zones, skipping update event")
+		return
+	}
+	logger.Info("Node update event")
+	n.asn.check(node)
+	eligible, neighbor, reason := desiredNeighbor(logger, n.nodeState, node, n.checks, n.peers)
+	n.status.setNode(node.Name, nodeStatus{
+		Address:  neighbor.IP,
+		Eligible: eligible,
+		Reason:   reason,
+	})
+	if eligible && n.overrides.removes(neighbor.IP) {
+		logger.Info("Neighbor is removed by the peer overrides")
+		eligible = false
+	}
+	if eligible {
+		logger.Info("Node should be added")
+		n.queue.AddNeighbor(neighbor, id)
+	} else if address := n.nodeState.address(node); n.kept(address) {
+		logger.Info("Node address is a static or added neighbor, keeping it", "address", address)
+	} else if n.nodeState.claims.managedByOther(node.Name, address) {
+		logger.Info("Node address is peered by another node, keeping it", "address", address)
+	} else {
+		logger.Info("Node should be removed")
+		n.queue.ScheduleRemoveNeighbor(address, node.Name, id)
+	}
+	n.removeOldAddress(logger, oldNode, node, id)
+}
+
+// removeOldAddress removes the neighbor of the old address of the node, if
+// the node was managed and its address changed, unless the address must be
+// kept or is peered by another node.
+func (n *Neighbors) removeOldAddress(logger *log.Logger, oldNode, node *v1.Node, id string) {
+	if !nodeLabeled(oldNode, n.selector) {
+		return
+	}
+	oldAddress := n.nodeState.address(oldNode)
+	if oldAddress == "" || oldAddress == n.nodeState.address(node) {
+		return
+	}
+	logger = logger.With("address", oldAddress)
+	if n.kept(oldAddress) {
+		logger.Info("Old node address is a static or added neighbor, keeping it")
+	} else if n.nodeState.claims.managedByOther(node.Name, oldAddress) {
+		logger.Info("Old node address is peered by another node, keeping it")
+	} else {
+		logger.Info("Node address changed, old neighbor should be removed")
+		n.queue.ScheduleRemoveNeighbor(oldAddress, node.Name, id)
+	}
+}
+
+// delete deletes a node from the A10 device.
+// It first checks if the node is labeled, and if so,
+// removes the node from the A10 device.
+// Nodes deleted while the watch was down are handled from their last known
+// state.
+func (n *Neighbors) delete(obj interface{}) {
+	n.events.observe(nodeEventDelete)
+	n.watch.seen()
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		n.logger.Error("Unexpected object in node delete event", "object", obj)
+		return
+	}
+	id := newCorrelationID()
+	logger := n.logger.With(
+		"node", node.Name,
+		"correlationID", id,
+	)
+	if !n.sharder.ownsNode(node.Name) {
+		logger.Debug("Node is managed by another shard, skipping delete event")
+		return
+	}
+	if !n.nodeState.scope.contains(node) {
+		n.nodeState.scope.forget(node.Name)
+		logger.Debug("Node is out of the managed regions and zones, skipping delete event")
+		return
+	}
+	logger.Info("Node delete event")
+	n.status.deleteNode(node.Name)
+	n.asn.forget(node.Name)
+	n.nodeState.subnets.forget(node.Name)
+	n.nodeState.claims.release(node.Name)
+	if address := n.nodeState.address(node); n.kept(address) {
+		logger.Info("Node address is a static or added neighbor, keeping it", "address", address)
+	} else if n.nodeState.claims.managedByOther(node.Name, address) {
+		logger.Info("Node address is peered by another node, keeping it", "address", address)
+	} else if nodeLabeled(node, n.selector) {
+		logger.Info("Node should be removed")
+		n.queue.TombstoneNeighbor(address, node.Name, id)
+	}
+}
+
+// kept checks if the neighbor must exist regardless of its node: it's a
+// static neighbor or added by the peer overrides, and not removed by them.
+func (n *Neighbors) kept(neighborIP string) bool {
+	if n.overrides.removes(neighborIP) {
+		return false
+	}
+	return n.static.contains(neighborIP) || n.overrides.adds().contains(neighborIP)
+}
+
+// StartInformer starts the informer.
+// It creates the shared informer factory and uses the client to connect to
+// Kubernetes, and then waits for the informer cache to sync so the lister
+// can be used.
+// Returns an error if the cache doesn't sync.
+func (n *Neighbors) StartInformer() error {
+	// Create the shared informer factory and use the client to connect to
+	// Kubernetes
+	factory := informers.NewSharedInformerFactory(n.clientset, n.resyncPeriod)
+
+	// Get the informer and the lister for the right resource, in this case
+	// a Node
+	nodeInformer := factory.Core().V1().Nodes()
+	informer := nodeInformer.Informer()
+	n.lister = nodeInformer.Lister()
+	if err := informer.SetTransform(stripNode); err != nil {
+		return fmt.Errorf("setting informer transform: %w", err)
+	}
+	if n.watch != nil {
+		if err := informer.SetWatchErrorHandler(n.watch.failed); err != nil {
+			return fmt.Errorf("setting watch error handler: %w", err)
+		}
+	}
+
+	// Kubernetes serves an utility to handle API crashes
+	defer runtime.HandleCrash()
+
+	// This is the part where your custom code gets triggered based on the
+	// event that the shared informer catches
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		// When a new node gets created
+		AddFunc: n.add,
+		// When a node gets updated
+		UpdateFunc: n.update,
+		// When a node gets deleted
+		DeleteFunc: n.delete,
+	}); err != nil {
+		return fmt.Errorf("adding informer event handler: %w", err)
+	}
+	// You need to start the informer, in my case, it runs in the background
+	go informer.Run(n.ctx.Done())
+
+	n.synced = informer.HasSynced
+	if !cache.WaitForCacheSync(n.ctx.Done(), informer.HasSynced) {
+		return fmt.Errorf("timed out waiting for caches to sync")
+	}
+	return nil
+}
+
+// stripNode drops the node fields the controller doesn't use before the
+// informer caches it: the managed fields, the container images and the
+// volumes, which make up most of a node object on big clusters. Other
+// objects, like delete tombstones, are kept as is.
+func stripNode(obj interface{}) (interface{}, error) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return obj, nil
+	}
+	node.ManagedFields = nil
+	node.Status.Images = nil
+	node.Status.VolumesInUse = nil
+	node.Status.VolumesAttached = nil
+	return node, nil
+}
+
+// nodeReady checks if a node is ready.
+// It first checks if the node is ready, and if so,
+// returns true. Else, it returns false. The result is logged by the
+// eligibility chain with the correlation ID of the node event.
+func nodeReady(node *v1.Node) bool {
+	ready := false
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == "Ready" {
+			ready = condition.Status == v1.ConditionTrue
+		}
+	}
+	return ready
+}
+
+// nodeCordoned checks if a node is cordoned.
+// It first checks if the node is cordoned, and if so,
+// returns true. Else, it returns false. The result is logged by the
+// eligibility chain with the correlation ID of the node event.
+func nodeCordoned(node *v1.Node) bool {
+	return node.Spec.Unschedulable
+}
+
+// nodeLabeled checks if a node is labeled.
+// It checks if the node labels match any of the label selectors, and if so,
+// returns true. Else, it returns false. The result is logged by the
+// eligibility chain with the correlation ID of the node event.
+func nodeLabeled(node *v1.Node, selector nodeSelector) bool {
+	return selector.matches(node.Labels)
+}
+
+// address gets the address of a node the devices peer with.
+// It first checks if the node has a valid address of the configured type,
+// and if so, returns the address. Else, it returns the address resolved by
+// the fallback, if valid, or an empty string. The addresses are
+// canonicalized, invalid ones are skipped.
+func (s *nodeState) address(node *v1.Node) string {
+	logger := s.logger.With(
+		"name", node.Name,
+		"type", s.addressType,
+	)
+	logger.Debug("Getting node address")
+	for _, address := range node.Status.Addresses {
+		if address.Type != s.addressType {
+			continue
+		}
+		if dnsAddressType(s.addressType) {
+			ip := s.resolver.resolve(address.Address)
+			if ip == "" {
+				break
+			}
+			ip, err := parseNeighborIP(ip)
+			if err != nil {
+				logger.Warn("Ignoring invalid node address", "host", address.Address, "error", err)
+				continue
+			}
+			logger.Info("Node address", "address", ip, "host", address.Address)
+			return ip
+		}
+		ip, err := parseNeighborIP(address.Address)
+		if err != nil {
+			logger.Warn("Ignoring invalid node address", "error", err)
+			continue
+		}
+		logger.Info("Node address", "address", ip)
+		return ip
+	}
+	if address := s.fallback.address(node); address != "" {
+		ip, err := parseNeighborIP(address)
+		if err != nil {
+			logger.Warn("Ignoring invalid node address from fallback", "fallback", s.fallback, "error", err)
+			return ""
+		}
+		logger.Info("Node address from fallback", "address", ip, "fallback", s.fallback)
+		return ip
+	}
+	logger.Debug("Node address not found")
+	return ""
+}
+
+// getKubernetesConfig loads the Kubernetes client configuration.
+func getKubernetesConfig() (*rest.Config, error) {
+	var config *rest.Config
+	var err error
+
+	// Detect if running inside a Kubernetes cluster or using kubeconfig
+	if kubeconfig := os.Getenv("KUBECONFIG"); kubeconfig != "" {
+		// Load kubeconfig file for out-of-cluster use
+		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("error loading kubeconfig: %w", err)
+		}
+	} else {
+		// Use in-cluster configuration
+		config, err = rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("error creating in-cluster config: %w", err)
+		}
+	}
+	return config, nil
+}
+
+// getKubernetesClient creates the Kubernetes client.
+func getKubernetesClient(config *rest.Config) (*kubernetes.Clientset, error) {
+	// Create a new Kubernetes client using the in-cluster config
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Kubernetes client: %w", err)
+	}
+	return clientset, nil
+}
+
+type KubeNodes struct {
+	logger    *log.Logger
+	lister    corelisters.NodeLister
+	nodeState *nodeState
+	selector  nodeSelector
+	checks    eligibilityChecks
+	peers     peerSource
+	static    staticNeighbors
+	overrides *peerOverrides
+	Nodes     []string
+	// Neighbors maps the eligible node addresses to their neighbors
+	Neighbors map[string]Neighbor
+}
+
+type KubeNodesManager interface {
+	GetNodes() error
+}
+
+// GetNodes gets the nodes from the shared informer cache.
+// It first lists the nodes with the informer lister, and then
+// checks if the labeled nodes are eligible.
+// Returns an error if the operation fails.
+func (n *KubeNodes) GetNodes() error {
+	n.logger.Info("Getting nodes from k8s")
+
+	nodes, err := n.lister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("error listing nodes: %w", err)
+	}
+
+	// Find nodes that are ready, not drained and have an address
+	// They are bgp neighbors
+	n.Neighbors = map[string]Neighbor{}
+	for _, node := range nodes {
+		if !n.nodeState.inScope(node) {
+			continue
+		}
+		if !n.selector.matches(node.Labels) {
+			continue
+		}
+		n.logger.Debug("Checking node", "name", node.Name)
+		eligible, neighbor, _ := desiredNeighbor(n.logger.With("node", node.Name), n.nodeState, node, n.checks, n.peers)
+		if eligible {
+			n.Nodes = append(n.Nodes, neighbor.IP)
+			n.Neighbors[neighbor.IP] = neighbor
+		}
+	}
+
+	// Static neighbors and the peer overrides additions are always desired
+	for _, neighbor := range append(slices.Clone(n.static), n.overrides.adds()...) {
+		if _, ok := n.Neighbors[neighbor.IP]; !ok {
+			n.Nodes = append(n.Nodes, neighbor.IP)
+			n.Neighbors[neighbor.IP] = neighbor
+		}
+	}
+
+	// The peer overrides removals win
+	n.Nodes = slices.DeleteFunc(n.Nodes, func(neighborIP string) bool {
+		if n.overrides.removes(neighborIP) {
+			delete(n.Neighbors, neighborIP)
+			return true
+		}
+		return false
+	})
+	return nil
+}
@@ -0,0 +1,100 @@
+package manager_test
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"testing"
+
+	"github.com/rgeraskin/a10-bgp-neighbor-manager/manager"
+	"github.com/rgeraskin/a10-bgp-neighbor-manager/manager/fake"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testNode creates a ready node with the internal IP and the labels, at the
+// resource version.
+func testNode(resourceVersion, internalIP string, labels map[string]string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1", ResourceVersion: resourceVersion, Labels: labels},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+			Addresses:  []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: internalIP}},
+		},
+	}
+}
+
+func TestNeighborsUpdate(t *testing.T) {
+	poolA := map[string]string{"bgp": "a10", "pool": "a"}
+	poolB := map[string]string{"bgp": "a10", "pool": "b"}
+	remoteASTable := fmt.Sprintf("pool=a:%d;pool=b:%d", manager.TestRemoteAS, manager.TestManagedRemoteAS)
+
+	tests := []struct {
+		name string
+		// neighbors are configured on the device beforehand
+		neighbors     []string
+		oldNode, node *v1.Node
+		want          map[string]int
+		// adds and removes are the operations made on the device
+		adds, removes int
+	}{
+		{
+			name:      "unchanged",
+			neighbors: []string{node1.IP},
+			oldNode:   testNode("1", node1.IP, poolA),
+			node:      testNode("2", node1.IP, poolA),
+			want:      map[string]int{node1.IP: manager.TestRemoteAS},
+		},
+		{
+			name:      "relabeled to another pool",
+			neighbors: []string{node1.IP},
+			oldNode:   testNode("1", node1.IP, poolA),
+			node:      testNode("2", node1.IP, poolB),
+			want:      map[string]int{node1.IP: manager.TestManagedRemoteAS},
+			adds:      1,
+			removes:   1,
+		},
+		{
+			name:      "unlabeled",
+			neighbors: []string{node1.IP},
+			oldNode:   testNode("1", node1.IP, poolA),
+			node:      testNode("2", node1.IP, map[string]string{"pool": "a"}),
+			want:      map[string]int{},
+			removes:   1,
+		},
+		{
+			name:      "address changed",
+			neighbors: []string{node1.IP},
+			oldNode:   testNode("1", node1.IP, poolA),
+			node:      testNode("2", node2.IP, poolA),
+			want:      map[string]int{node2.IP: manager.TestRemoteAS},
+			adds:      1,
+			removes:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakes, devices := devicesWith(t, 1, tt.neighbors...)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			queue := manager.NewTestQueue(ctx, devices, 0)
+			neighbors, err := manager.NewTestNeighbors(devices, queue, v1.NodeInternalIP, "bgp=a10", remoteASTable)
+			if err != nil {
+				t.Fatal(err)
+			}
+			queue.Start()
+			neighbors.Update(tt.oldNode, tt.node)
+			waitApplied(t, queue)
+
+			if got := fakes[0].State(); !maps.Equal(got, tt.want) {
+				t.Errorf("neighbors = %v, want %v", got, tt.want)
+			}
+			if got := countCalls(fakes[0], fake.OpAddNeighbor); got != tt.adds {
+				t.Errorf("adds = %d, want %d", got, tt.adds)
+			}
+			if got := countCalls(fakes[0], fake.OpRemoveNeighbor); got != tt.removes {
+				t.Errorf("removes = %d, want %d", got, tt.removes)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"context"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
+)
+
+// kubeRouterASNAnnotation is the kube-router node ASN annotation.
+const kubeRouterASNAnnotation = "kube-router.io/node.asn"
+
+func init() {
+	registerPeerSource("kube-router", func(_ dynamic.Interface, _ *Config) (peerSource, error) {
+		return kubeRouterPeers{}, nil
+	})
+}
+
+// kubeRouterPeers follows the kube-router peering conventions: the node
+// ASN comes from the kube-router.io/node.asn annotation, and nodes without
+// it speak the cluster ASN, A10_REMOTE_AS. kube-router peers from the node
+// IP, so the preset also defaults the node address type to InternalIP.
+type kubeRouterPeers struct{}
+
+func (kubeRouterPeers) start(context.Context) error {
+	return nil
+}
+
+// changed is never notified, the annotations are followed by the node
+// informer.
+func (kubeRouterPeers) changed() <-chan struct{} {
+	return nil
+}
+
+func (kubeRouterPeers) peer(node *v1.Node) (bool, int, string) {
+	value, ok := node.Annotations[kubeRouterASNAnnotation]
+	if !ok {
+		return true, 0, "kube-router cluster ASN"
+	}
+	asn, err := strconv.Atoi(value)
+	if err != nil || asn <= 0 {
+		return false, 0, "invalid " + kubeRouterASNAnnotation + " annotation " + strconv.Quote(value)
+	}
+	return true, asn, kubeRouterASNAnnotation + " annotation"
+}
@@ -0,0 +1,33 @@
+package manager
+
+const defaultNeighborLimitWarnRatio = 0.8
+
+// checkNeighborLimit updates the neighbor count metrics of the device and
+// warns when its total number of BGP neighbors approaches or reaches the
+// configured platform limit. A zero limit disables the check.
+func (a *A10) checkNeighborLimit() {
+	a.mu.RLock()
+	total := a.totalNeighbors
+	managed := len(a.neighbors)
+	a.mu.RUnlock()
+
+	deviceNeighbors.WithLabelValues(a.address).Set(float64(total))
+	managedNeighbors.WithLabelValues(a.address).Set(float64(managed))
+	if a.maxNeighbors == 0 {
+		return
+	}
+	deviceNeighborLimit.WithLabelValues(a.address).Set(float64(a.maxNeighbors))
+
+	logger := a.logger.With(
+		"device", a.address,
+		"neighbors", total,
+		"managed", managed,
+		"limit", a.maxNeighbors,
+	)
+	switch {
+	case total >= a.maxNeighbors:
+		logger.Error("A10 device reached its BGP neighbor limit")
+	case float64(total) >= float64(a.maxNeighbors)*a.neighborLimitWarnRatio:
+		logger.Warn("A10 device is approaching its BGP neighbor limit")
+	}
+}
@@ -0,0 +1,89 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultAuthFailureLimit is how many consecutive rejected logins lock
+	// the logins out
+	defaultAuthFailureLimit = 3
+	// defaultAuthLockout is how long the logins are locked out
+	defaultAuthLockout = 30 * time.Minute
+)
+
+// errAuthLockedOut is returned when the logins are locked out after repeated
+// rejected logins.
+var errAuthLockedOut = errors.New("logins locked out after repeated authentication failures")
+
+// authLockout stops logging in to a device after repeated rejected logins,
+// so a rotated password doesn't make the controller lock the admin account
+// on the device. The lockout ends after the cool-down or when the
+// credentials change.
+type authLockout struct {
+	// limit is how many consecutive rejected logins lock the logins out,
+	// 0 disables the lockout
+	limit    int
+	cooldown time.Duration
+	failures int
+	until    time.Time
+}
+
+// checkLockout checks if the device logins are locked out.
+// Returns errAuthLockedOut while the lockout lasts.
+func (a *A10) checkLockout() error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if remaining := time.Until(a.lockout.until); remaining > 0 {
+		return fmt.Errorf("%w, retrying in %s", errAuthLockedOut, remaining.Round(time.Second))
+	}
+	return nil
+}
+
+// loginRejected records a rejected login and locks the logins out once
+// the limit is reached.
+func (a *A10) loginRejected() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lockout.failures++
+	authFailures.WithLabelValues(a.address).Inc()
+	if a.lockout.limit == 0 || a.lockout.failures < a.lockout.limit {
+		a.logger.Warn(
+			"A10 login rejected",
+			"device", a.address,
+			"username", a.username,
+			"failures", a.lockout.failures,
+			"limit", a.lockout.limit,
+		)
+		return
+	}
+	a.lockout.until = time.Now().Add(a.lockout.cooldown)
+	authLockedOut.WithLabelValues(a.address).Set(1)
+	a.logger.Error(
+		"A10 LOGINS LOCKED OUT: the device keeps rejecting the credentials, stopped logging in to avoid locking the account; update the credentials",
+		"device", a.address,
+		"username", a.username,
+		"failures", a.lockout.failures,
+		"until", a.lockout.until.Format(time.RFC3339),
+	)
+}
+
+// loginAccepted clears the rejected logins.
+func (a *A10) loginAccepted() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.resetLockout()
+}
+
+// resetLockout clears the rejected logins and lifts the lockout.
+// The caller must hold the lock.
+func (a *A10) resetLockout() {
+	if !a.lockout.until.IsZero() {
+		a.logger.Info("A10 login lockout lifted", "device", a.address)
+	}
+	a.lockout.failures = 0
+	a.lockout.until = time.Time{}
+	authLockedOut.WithLabelValues(a.address).Set(0)
+}
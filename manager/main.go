@@ -0,0 +1,1467 @@
+package manager
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"golang.org/x/crypto/ssh"
+	v1 "k8s.io/api/core/v1"
+)
+
+type Config struct {
+	// logger logs the configuration and is passed to the components
+	// created from it, the default logger if nil
+	logger *log.Logger
+	// Devices are the devices from A10_ADDRESS or the topology file
+	Devices      []deviceConfig
+	Username     string
+	Password     Secret
+	UsernameFile string
+	PasswordFile string
+	// CredentialsSource is where the A10 credentials are resolved from
+	CredentialsSource  string
+	CredentialsRefresh time.Duration
+	// Pre-issued aXAPI token credentials source
+	Token     Secret
+	TokenFile string
+	// Vault credentials source
+	VaultAddress   string
+	VaultPath      string
+	VaultRole      string
+	VaultAuthMount string
+	// Cloud secret manager credentials sources
+	AWSSecretID   string
+	GCPSecretName string
+	AS            int
+	RemoteAS      int
+	LabelSelector string
+	NodeSelector  nodeSelector
+	// EligibilityChecks is the ordered list of node eligibility checks
+	EligibilityChecks []string
+	// Eligibility webhook
+	WebhookURL      string
+	WebhookToken    Secret
+	WebhookFailOpen bool
+	WebhookTimeout  time.Duration
+	// NodeLeaseMaxAge is the age the kubelet Leases expire at for the lease
+	// check
+	NodeLeaseMaxAge time.Duration
+	// StatusAddress is the listen address of the status server
+	StatusAddress string
+	// ResyncToken is the bearer token of the manual resync endpoint of the
+	// status server, which is disabled without it
+	ResyncToken Secret
+	// Workers is the number of workers applying neighbor changes
+	Workers int
+	// CoalesceWindow is the quiet period to coalesce bursts of node events
+	CoalesceWindow time.Duration
+	// InformerResync is how often the node informer replays the cached
+	// nodes to the handlers, 0 disables it
+	InformerResync time.Duration
+	// BatchJitter spreads the changes of the batches of at least
+	// BatchJitterThreshold neighbors over a random delay, 0 disables it
+	BatchJitter          time.Duration
+	BatchJitterThreshold int
+	// ReconcileDeadline bounds how long the changes of a reconcile cycle are
+	// applied before they are carried into the next cycle, 0 disables it
+	ReconcileDeadline time.Duration
+	// ReconcileInterval is how often the devices are reconciled with k8s,
+	// 0 disables the periodic reconcile
+	ReconcileInterval time.Duration
+	// Heartbeat renews a Lease or pings a URL after every reconcile if set
+	Heartbeat *heartbeat
+	// SyncWebhook receives the notifications of device changes from
+	// external systems if set
+	SyncWebhook *syncWebhook
+	// RemovalDelay delays removing the neighbors of ineligible nodes
+	RemovalDelay time.Duration
+	// TombstoneTTL keeps the neighbors of deleted nodes shut down before
+	// removing them, 0 removes them right away
+	TombstoneTTL time.Duration
+	// ShutdownGracePeriod bounds how long the in-flight changes and the due
+	// removals are drained on shutdown, 0 abandons them
+	ShutdownGracePeriod time.Duration
+	// RemovalGuard limits the removals of a single reconcile per device
+	RemovalGuard removalGuard
+	// Pause halts the A10 writes following a ConfigMap
+	Pause *pauseSwitch
+	// Approvals stage the removals above a threshold until approved if set
+	Approvals *approvalGate
+	// ApprovalToken is the bearer token approving the staged removals on
+	// the status server, which doesn't approve them without it
+	ApprovalToken Secret
+	// ChangeWindows defer the changes outside of the change windows
+	ChangeWindows *changeWindows
+	// CanaryTimeout is how long the canary device session of an added
+	// neighbor may take to establish, 0 disables canary apply
+	CanaryTimeout time.Duration
+	// VerifyAddTimeout is how long the session of an added neighbor may
+	// take to establish, 0 disables the verification
+	VerifyAddTimeout time.Duration
+	// Rollback removes and quarantines the neighbors that fail to be added
+	// or verified
+	Rollback           bool
+	QuarantineDuration time.Duration
+	// Flaps quarantine the neighbors added and removed too often if set
+	Flaps *flapDetector
+	// Checkpoints checkpoint the devices before every batch if set
+	Checkpoints *checkpointer
+	// RestoreToken is the bearer token of the checkpoint restore endpoint of
+	// the status server, which is disabled without it
+	RestoreToken Secret
+	// EventSpikeMin is the fewest node events per minute that can be a
+	// spike, 0 disables the warnings
+	EventSpikeMin int
+	// WatchStaleAfter is how long the node watch may fail before the nodes
+	// are relisted once the API server is reachable, 0 disables it
+	WatchStaleAfter time.Duration
+	// NotifyURL is the webhook the notable events are posted to, not
+	// posted if empty
+	NotifyURL string
+	// MaxChangesPerMinute caps the neighbor mutations per device, 0
+	// disables the limit
+	MaxChangesPerMinute int
+	// SessionIdleTimeout is the A10 session idle timeout
+	SessionIdleTimeout time.Duration
+	// AuthFailureLimit is how many consecutive rejected logins lock the
+	// logins to a device out, 0 disables the lockout
+	AuthFailureLimit int
+	// AuthLockout is how long the logins are locked out
+	AuthLockout time.Duration
+	// Transport tunes the persistent connections to the devices
+	Transport transportOptions
+	// Chaos injects random failures into the aXAPI requests if set, to
+	// validate the retries and the alerts in staging
+	Chaos *chaosInjector
+	// Backend is how the changes are applied, with the aXAPI, ACOS CLI
+	// over SSH, the configured REST requests or gNMI
+	Backend string
+	// GNMI locates the BGP neighbors of the gnmi backend
+	GNMI gnmiOptions
+	// backendFactory builds the device backends instead of Backend if set,
+	// see WithBackend
+	backendFactory BackendFactory
+	// RESTBackend defines the requests of the rest backend
+	RESTBackend *restTemplates
+	// SSHPort is the SSH port of the devices
+	SSHPort int
+	// SSHHostKeys verifies the device SSH host keys
+	SSHHostKeys ssh.HostKeyCallback
+	// ResponseSchema is how strictly the aXAPI responses are parsed,
+	// lenient or strict
+	ResponseSchema string
+	// NeighborCacheTTL is how long the cached A10 neighbors are trusted
+	NeighborCacheTTL time.Duration
+	// HealthCheckInterval is how often the device connections are probed,
+	// 0 disables the supervisor
+	HealthCheckInterval time.Duration
+	// SessionSource is where the BGP session state is read from, the oper
+	// API or SNMP
+	SessionSource string
+	// SNMPCommunity is the SNMPv2c community of the devices
+	SNMPCommunity Secret
+	// SNMPPort is the SNMP port of the devices
+	SNMPPort uint16
+	// SessionScrapeInterval is how often the BGP session state is scraped,
+	// 0 disables the exporter
+	SessionScrapeInterval time.Duration
+	// PeerStatusCRD writes the peering status of the nodes to A10Peers
+	PeerStatusCRD bool
+	// NodeCondition is the Node condition type set to the peering status,
+	// empty disables it
+	NodeCondition string
+	// NodeAnnotations writes the peering status of the nodes to their
+	// annotations
+	NodeAnnotations bool
+	// PeerStatusInterval is how often the peering status is written
+	PeerStatusInterval time.Duration
+	// MetricsPush pushes the metrics to a Pushgateway, an OTLP collector or
+	// a statsd agent if set
+	MetricsPush *metricsPusher
+	// PreflightNeighbor is the probe neighbor of the startup permission
+	// check, empty disables the check
+	PreflightNeighbor string
+	// NodeASNAnnotation is the node annotation with the node BGP speaker
+	// ASN to cross-check against the remote AS
+	NodeASNAnnotation string
+	// Integration derives the peered nodes and their ASNs from the BGP
+	// speaker running in the cluster
+	Integration string
+	// ManagedRemoteAS are remote ASNs managed in addition to RemoteAS,
+	// including the ones of RemoteASTable
+	ManagedRemoteAS []int
+	// RemoteASTable maps label selectors to the remote AS of the nodes
+	RemoteASTable *remoteASTable
+	// NodeAddressType is the type of the node address to peer with
+	NodeAddressType v1.NodeAddressType
+	// NodeAddressFallback resolves the address of the nodes without one of
+	// the type: label:<key>, annotation:<key>, ec2 or gce
+	NodeAddressFallback string
+	// DNSCacheTTL is how long the DNS node addresses are cached
+	DNSCacheTTL time.Duration
+	// MaxNeighbors is the device BGP neighbor limit, 0 disables the check
+	MaxNeighbors           int
+	NeighborLimitWarnRatio float64
+	// ProtectedNeighbors are never added, modified or deleted
+	ProtectedNeighbors protectedNeighbors
+	// StaticNeighbors always exist on the devices
+	StaticNeighbors staticNeighbors
+	// SubnetPolicy requires the node addresses to be in the subnets of
+	// their site or zone
+	SubnetPolicy *subnetPolicy
+	// Scope restricts the managed nodes to regions and zones, so per-site
+	// instances leave the nodes of the other sites alone
+	Scope *topologyScope
+	// AllowedNeighbors are the CIDRs the neighbors must be in, empty allows
+	// any address
+	AllowedNeighbors cidrAllowlist
+	// SharedNodeAddresses lets several nodes share a neighbor address, e.g.
+	// behind a NAT, keeping the neighbor until the last of them is
+	// ineligible
+	SharedNodeAddresses bool
+	// PeerOverrides are merged on top of the node neighbors following a
+	// ConfigMap
+	PeerOverrides *peerOverrides
+	// TLSFingerprints pin the device certificates by SHA-256 fingerprint
+	TLSFingerprints [][]byte
+	// NeighborTemplate renders the neighbor create payload
+	NeighborTemplate *neighborTemplate
+	// NeighborExtraAttrs are merged into every neighbor create payload
+	NeighborExtraAttrs map[string]interface{}
+	// LocalAS is presented to the neighbors instead of the device AS if set
+	LocalAS int
+	// LocalASAnnotation is the node annotation overriding the local AS of
+	// its neighbor
+	LocalASAnnotation string
+	// Sharding between active replicas
+	ShardMode  string
+	ShardCount int
+	ShardIndex int
+
+	// source looks the configuration keys up in the config file, the
+	// environment and the flags
+	source *configSource
+}
+
+// Get loads and validates the configuration from the layered source:
+// the defaults, the config file, the environment and the flags.
+// Returns an error if the configuration is invalid.
+func (c *Config) Get() error {
+	if c.logger == nil {
+		c.logger = log.Default()
+	}
+	if c.source == nil {
+		source, err := loadConfigSource(os.Args[1:])
+		if err != nil {
+			return err
+		}
+		c.source = source
+	}
+
+	remoteAS := c.getenv("A10_REMOTE_AS")
+	if remoteAS == "" {
+		return fmt.Errorf("A10_REMOTE_AS environment variable must be set")
+	}
+	remoteASInt, err := strconv.Atoi(remoteAS)
+	if err != nil {
+		return fmt.Errorf("A10_REMOTE_AS must be a number: %w", err)
+	}
+
+	// Get A10 AS, the default of the topology devices
+	var a10AsInt int
+	a10As := c.getenv("A10_AS")
+	if a10As != "" {
+		a10AsInt, err = strconv.Atoi(a10As)
+		if err != nil {
+			return fmt.Errorf("A10_AS must be a number: %w", err)
+		}
+	}
+
+	// Get A10 devices from the topology file, or the addresses,
+	// comma-separated for multiple devices
+	var devices []deviceConfig
+	if topology := c.getenv("A10_TOPOLOGY_FILE"); topology != "" {
+		devices, err = parseTopology(topology, a10AsInt)
+		if err != nil {
+			return fmt.Errorf("A10_TOPOLOGY_FILE: %w", err)
+		}
+	} else {
+		a10Address := c.getenv("A10_ADDRESS")
+		if a10Address == "" {
+			return fmt.Errorf("A10_ADDRESS or A10_TOPOLOGY_FILE environment variable must be set")
+		}
+		if a10As == "" {
+			return fmt.Errorf("A10_AS environment variable must be set")
+		}
+		for _, address := range strings.Split(a10Address, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				devices = append(devices, deviceConfig{address: address, as: a10AsInt})
+			}
+		}
+	}
+
+	// Get A10 credentials source
+	credentialsSource := c.getenv("A10_CREDENTIALS_SOURCE")
+	if credentialsSource == "" {
+		credentialsSource = credentialsSourceEnv
+	}
+	a10Username := c.getenv("A10_USERNAME")
+	a10Password := c.getenv("A10_PASSWORD")
+	// devices with their own credentials don't need the source
+	switch credentialsSource {
+	case credentialsSourceEnv:
+		if !sharedCredentials(devices) {
+			break
+		}
+		// Get A10 username, from a file if A10_USERNAME_FILE is set
+		c.UsernameFile = c.getenv("A10_USERNAME_FILE")
+		if a10Username == "" && c.UsernameFile == "" {
+			return fmt.Errorf("A10_USERNAME or A10_USERNAME_FILE environment variable must be set")
+		}
+
+		// Get A10 password, from a file if A10_PASSWORD_FILE is set
+		c.PasswordFile = c.getenv("A10_PASSWORD_FILE")
+		if a10Password == "" && c.PasswordFile == "" {
+			return fmt.Errorf("A10_PASSWORD or A10_PASSWORD_FILE environment variable must be set")
+		}
+	case credentialsSourceToken:
+		c.Token = Secret(c.getenv("A10_TOKEN"))
+		c.TokenFile = c.getenv("A10_TOKEN_FILE")
+		if c.Token == "" && c.TokenFile == "" && sharedCredentials(devices) {
+			return fmt.Errorf("A10_TOKEN or A10_TOKEN_FILE must be set for token credentials")
+		}
+	case credentialsSourceVault:
+		c.VaultAddress = c.getenv("VAULT_ADDR")
+		c.VaultPath = c.getenv("A10_VAULT_PATH")
+		c.VaultRole = c.getenv("A10_VAULT_ROLE")
+		if c.VaultAddress == "" || c.VaultPath == "" || c.VaultRole == "" {
+			return fmt.Errorf(
+				"VAULT_ADDR, A10_VAULT_PATH and A10_VAULT_ROLE must be set for vault credentials",
+			)
+		}
+		c.VaultAuthMount = c.getenv("A10_VAULT_AUTH_MOUNT")
+		if c.VaultAuthMount == "" {
+			c.VaultAuthMount = defaultVaultAuthMount
+		}
+	case credentialsSourceAWS:
+		c.AWSSecretID = c.getenv("A10_AWS_SECRET_ID")
+		if c.AWSSecretID == "" {
+			return fmt.Errorf("A10_AWS_SECRET_ID must be set for aws-secrets-manager credentials")
+		}
+	case credentialsSourceGCP:
+		c.GCPSecretName = c.getenv("A10_GCP_SECRET_NAME")
+		if c.GCPSecretName == "" {
+			return fmt.Errorf("A10_GCP_SECRET_NAME must be set for gcp-secret-manager credentials")
+		}
+	default:
+		return fmt.Errorf(
+			"A10_CREDENTIALS_SOURCE must be env, token, vault, aws-secrets-manager or gcp-secret-manager, got %q",
+			credentialsSource,
+		)
+	}
+	credentialsRefresh := defaultCredentialsRefresh
+	if interval := c.getenv("A10_CREDENTIALS_REFRESH_INTERVAL"); interval != "" {
+		credentialsRefresh, err = time.ParseDuration(interval)
+		if err != nil || credentialsRefresh <= 0 {
+			return fmt.Errorf("A10_CREDENTIALS_REFRESH_INTERVAL must be a positive duration")
+		}
+	}
+
+	// Label selector for nodes
+	labelSelector := c.getenv("NODES_LABEL_SELECTOR")
+	if labelSelector == "" {
+		return fmt.Errorf(
+			"label selector must be set with NODES_LABEL_SELECTOR environment variable",
+		)
+	}
+	// several selectors are separated by semicolons and OR'd
+	nodeSelector, err := parseNodeSelector(labelSelector)
+	if err != nil {
+		return fmt.Errorf("NODES_LABEL_SELECTOR: %w", err)
+	}
+
+	// Node eligibility checks chain
+	eligibilityChecks := c.getenv("NODE_ELIGIBILITY_CHECKS")
+	if eligibilityChecks == "" {
+		eligibilityChecks = defaultEligibilityChecks
+	}
+
+	// Eligibility webhook failure policy
+	webhookFailOpen := false
+	switch policy := c.getenv("NODE_ELIGIBILITY_WEBHOOK_FAILURE_POLICY"); policy {
+	case "", "deny":
+	case "allow":
+		webhookFailOpen = true
+	default:
+		return fmt.Errorf(
+			"NODE_ELIGIBILITY_WEBHOOK_FAILURE_POLICY must be allow or deny, got %q",
+			policy,
+		)
+	}
+
+	// Eligibility webhook timeout
+	webhookTimeout := defaultWebhookTimeout
+	if timeout := c.getenv("NODE_ELIGIBILITY_WEBHOOK_TIMEOUT"); timeout != "" {
+		webhookTimeout, err = time.ParseDuration(timeout)
+		if err != nil || webhookTimeout <= 0 {
+			return fmt.Errorf("NODE_ELIGIBILITY_WEBHOOK_TIMEOUT must be a positive duration")
+		}
+	}
+
+	// Kubelet Lease max age of the lease check
+	nodeLeaseMaxAge := defaultNodeLeaseMaxAge
+	if maxAge := c.getenv("NODE_LEASE_MAX_AGE"); maxAge != "" {
+		nodeLeaseMaxAge, err = time.ParseDuration(maxAge)
+		if err != nil || nodeLeaseMaxAge <= 0 {
+			return fmt.Errorf("NODE_LEASE_MAX_AGE must be a positive duration, got %q", maxAge)
+		}
+	}
+
+	// Number of workers
+	workers := defaultWorkers
+	if w := c.getenv("WORKERS"); w != "" {
+		workers, err = strconv.Atoi(w)
+		if err != nil {
+			return fmt.Errorf("WORKERS must be a number: %w", err)
+		}
+		if workers < 1 {
+			return fmt.Errorf("WORKERS must be at least 1")
+		}
+	}
+
+	// Event coalescing window
+	coalesceWindow := defaultCoalesceWindow
+	if window := c.getenv("COALESCE_WINDOW"); window != "" {
+		coalesceWindow, err = time.ParseDuration(window)
+		if err != nil {
+			return fmt.Errorf("COALESCE_WINDOW must be a duration: %w", err)
+		}
+	}
+	batchJitter := time.Duration(0)
+	if jitter := c.getenv("BATCH_JITTER"); jitter != "" {
+		batchJitter, err = time.ParseDuration(jitter)
+		if err != nil || batchJitter < 0 {
+			return fmt.Errorf("BATCH_JITTER must be a non-negative duration")
+		}
+	}
+	batchJitterThreshold := defaultJitterThreshold
+	if threshold := c.getenv("BATCH_JITTER_THRESHOLD"); threshold != "" {
+		batchJitterThreshold, err = strconv.Atoi(threshold)
+		if err != nil || batchJitterThreshold < 1 {
+			return fmt.Errorf("BATCH_JITTER_THRESHOLD must be a positive integer")
+		}
+	}
+
+	// Reconcile cycle deadline
+	var reconcileDeadline time.Duration
+	if deadline := c.getenv("RECONCILE_DEADLINE"); deadline != "" {
+		reconcileDeadline, err = time.ParseDuration(deadline)
+		if err != nil || reconcileDeadline < 0 {
+			return fmt.Errorf("RECONCILE_DEADLINE must be a non-negative duration")
+		}
+	}
+	var reconcileInterval time.Duration
+	if interval := c.getenv("RECONCILE_INTERVAL"); interval != "" {
+		reconcileInterval, err = time.ParseDuration(interval)
+		if err != nil || reconcileInterval < 0 {
+			return fmt.Errorf("RECONCILE_INTERVAL must be a non-negative duration")
+		}
+	}
+
+	// Liveness heartbeat, the Lease expires after missing a few periodic
+	// reconciles
+	heartbeat, err := newHeartbeat(
+		c.logger,
+		c.getenv("HEARTBEAT_LEASE"),
+		c.getenv("POD_NAMESPACE"),
+		c.getenv("HEARTBEAT_URL"),
+		3*reconcileInterval,
+	)
+	if err != nil {
+		return fmt.Errorf("HEARTBEAT_LEASE: %w", err)
+	}
+
+	// Node informer resync
+	informerResync := defaultInformerResync
+	if period := c.getenv("INFORMER_RESYNC_PERIOD"); period != "" {
+		informerResync, err = time.ParseDuration(period)
+		if err != nil || informerResync < 0 {
+			return fmt.Errorf("INFORMER_RESYNC_PERIOD must be a non-negative duration")
+		}
+	}
+
+	// A10 session idle timeout
+	sessionIdleTimeout := defaultSessionIdleTimeout
+	if timeout := c.getenv("A10_SESSION_IDLE_TIMEOUT"); timeout != "" {
+		sessionIdleTimeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			return fmt.Errorf("A10_SESSION_IDLE_TIMEOUT must be a duration: %w", err)
+		}
+	}
+
+	// A10 login lockout
+	authFailureLimit := defaultAuthFailureLimit
+	if limit := c.getenv("A10_AUTH_FAILURE_LIMIT"); limit != "" {
+		authFailureLimit, err = strconv.Atoi(limit)
+		if err != nil || authFailureLimit < 0 {
+			return fmt.Errorf("A10_AUTH_FAILURE_LIMIT must be a non-negative integer")
+		}
+	}
+	authLockout := defaultAuthLockout
+	if lockout := c.getenv("A10_AUTH_LOCKOUT"); lockout != "" {
+		authLockout, err = time.ParseDuration(lockout)
+		if err != nil || authLockout <= 0 {
+			return fmt.Errorf("A10_AUTH_LOCKOUT must be a positive duration")
+		}
+	}
+
+	// A10 connection pooling
+	transport := transportOptions{
+		maxIdleConns:    defaultMaxIdleConns,
+		idleConnTimeout: defaultIdleConnTimeout,
+	}
+	if conns := c.getenv("A10_MAX_IDLE_CONNS"); conns != "" {
+		transport.maxIdleConns, err = strconv.Atoi(conns)
+		if err != nil || transport.maxIdleConns <= 0 {
+			return fmt.Errorf("A10_MAX_IDLE_CONNS must be a positive integer")
+		}
+	}
+	if timeout := c.getenv("A10_IDLE_CONN_TIMEOUT"); timeout != "" {
+		transport.idleConnTimeout, err = time.ParseDuration(timeout)
+		if err != nil || transport.idleConnTimeout <= 0 {
+			return fmt.Errorf("A10_IDLE_CONN_TIMEOUT must be a positive duration")
+		}
+	}
+	switch http2 := c.getenv("A10_HTTP2"); http2 {
+	case "", "false":
+	case "true":
+		transport.http2 = true
+	default:
+		return fmt.Errorf("A10_HTTP2 must be true or false, got %q", http2)
+	}
+
+	// Chaos mode
+	chaos, err := parseChaos(
+		c.getenv("CHAOS_FAILURE_RATE"),
+		c.getenv("CHAOS_FAULTS"),
+		c.getenv("CHAOS_LATENCY"),
+	)
+	if err != nil {
+		return fmt.Errorf("CHAOS_FAILURE_RATE: %w", err)
+	}
+
+	// Device backend
+	backend := c.getenv("A10_BACKEND")
+	switch backend {
+	case "":
+		backend = backendAXAPI
+	case backendAXAPI, backendSSH, backendREST, backendGNMI:
+	default:
+		return fmt.Errorf("A10_BACKEND must be axapi, ssh, rest or gnmi, got %q", backend)
+	}
+	var restBackend *restTemplates
+	if backend == backendREST {
+		path := c.getenv("A10_REST_BACKEND_FILE")
+		if path == "" {
+			return fmt.Errorf("A10_REST_BACKEND_FILE must be set for the rest backend")
+		}
+		restBackend, err = parseRESTBackend(path)
+		if err != nil {
+			return fmt.Errorf("A10_REST_BACKEND_FILE: %w", err)
+		}
+	}
+	sshPort := defaultSSHPort
+	if port := c.getenv("A10_SSH_PORT"); port != "" {
+		sshPort, err = strconv.Atoi(port)
+		if err != nil || sshPort <= 0 || sshPort > 65535 {
+			return fmt.Errorf("A10_SSH_PORT must be a port number")
+		}
+	}
+	sshHostKeys, err := parseSSHHostKeys(c.getenv("A10_SSH_KNOWN_HOSTS"))
+	if err != nil {
+		return fmt.Errorf("A10_SSH_KNOWN_HOSTS: %w", err)
+	}
+	if backend == backendSSH && c.getenv("A10_SSH_KNOWN_HOSTS") == "" {
+		c.logger.Warn(
+			"A10_SSH_KNOWN_HOSTS is not set, the SSH host keys of the devices are not verified " +
+				"and the device credentials can be intercepted",
+		)
+	}
+	gnmi := gnmiOptions{
+		port:            defaultGNMIPort,
+		networkInstance: defaultGNMINetworkInstance,
+		protocol:        defaultGNMIProtocol,
+	}
+	if port := c.getenv("A10_GNMI_PORT"); port != "" {
+		gnmi.port, err = strconv.Atoi(port)
+		if err != nil || gnmi.port <= 0 || gnmi.port > 65535 {
+			return fmt.Errorf("A10_GNMI_PORT must be a port number")
+		}
+	}
+	if instance := c.getenv("A10_GNMI_NETWORK_INSTANCE"); instance != "" {
+		gnmi.networkInstance = instance
+	}
+	if protocol := c.getenv("A10_GNMI_PROTOCOL"); protocol != "" {
+		gnmi.protocol = protocol
+	}
+
+	// aXAPI response schema strictness
+	responseSchema := c.getenv("A10_RESPONSE_SCHEMA")
+	switch responseSchema {
+	case "":
+		responseSchema = schemaLenient
+	case schemaLenient, schemaStrict:
+	default:
+		return fmt.Errorf("A10_RESPONSE_SCHEMA must be lenient or strict, got %q", responseSchema)
+	}
+
+	// A10 neighbor cache TTL
+	neighborCacheTTL := defaultNeighborCacheTTL
+	if ttl := c.getenv("A10_NEIGHBOR_CACHE_TTL"); ttl != "" {
+		neighborCacheTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			return fmt.Errorf("A10_NEIGHBOR_CACHE_TTL must be a duration: %w", err)
+		}
+	}
+
+	// Delayed removal of ineligible nodes
+	var removalDelay time.Duration
+	if delay := c.getenv("NODE_REMOVAL_DELAY"); delay != "" {
+		removalDelay, err = time.ParseDuration(delay)
+		if err != nil || removalDelay < 0 {
+			return fmt.Errorf("NODE_REMOVAL_DELAY must be a non-negative duration")
+		}
+	}
+
+	// Two-phase removal of deleted nodes
+	var tombstoneTTL time.Duration
+	if ttl := c.getenv("NODE_TOMBSTONE_TTL"); ttl != "" {
+		tombstoneTTL, err = time.ParseDuration(ttl)
+		if err != nil || tombstoneTTL < 0 {
+			return fmt.Errorf("NODE_TOMBSTONE_TTL must be a non-negative duration")
+		}
+	}
+
+	// Draining the changes on shutdown
+	shutdownGracePeriod := defaultShutdownGracePeriod
+	if period := c.getenv("SHUTDOWN_GRACE_PERIOD"); period != "" {
+		shutdownGracePeriod, err = time.ParseDuration(period)
+		if err != nil || shutdownGracePeriod < 0 {
+			return fmt.Errorf("SHUTDOWN_GRACE_PERIOD must be a non-negative duration")
+		}
+	}
+
+	// Mass-removal safety threshold
+	allowMassRemoval := c.getenv("ALLOW_MASS_REMOVAL") == "true"
+	removalGuard, err := parseRemovalGuard(c.getenv("MAX_REMOVALS"), allowMassRemoval)
+	if err != nil {
+		return fmt.Errorf("MAX_REMOVALS: %w", err)
+	}
+
+	// Removal approvals
+	approvals, err := newApprovalGate(
+		c.logger,
+		c.getenv("APPROVAL_THRESHOLD"),
+		c.getenv("APPROVAL_CONFIGMAP"),
+		c.getenv("POD_NAMESPACE"),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Pause switch
+	pause, err := newPauseSwitch(c.logger, c.getenv("PAUSE_CONFIGMAP"), c.getenv("POD_NAMESPACE"))
+	if err != nil {
+		return fmt.Errorf("PAUSE_CONFIGMAP: %w", err)
+	}
+
+	// Change windows
+	allowAdditions := false
+	switch additions := c.getenv("CHANGE_WINDOW_ADDITIONS"); additions {
+	case "", "defer":
+	case "allow":
+		allowAdditions = true
+	default:
+		return fmt.Errorf("CHANGE_WINDOW_ADDITIONS must be allow or defer, got %q", additions)
+	}
+	changeWindows, err := parseChangeWindows(c.getenv("CHANGE_WINDOWS"), allowAdditions)
+	if err != nil {
+		return fmt.Errorf("CHANGE_WINDOWS: %w", err)
+	}
+
+	// Neighbor change rate limit
+	maxChangesPerMinute := 0
+	if limit := c.getenv("MAX_CHANGES_PER_MINUTE"); limit != "" {
+		maxChangesPerMinute, err = strconv.Atoi(limit)
+		if err != nil || maxChangesPerMinute < 0 {
+			return fmt.Errorf("MAX_CHANGES_PER_MINUTE must be a non-negative integer")
+		}
+	}
+
+	// Desired-peer overrides
+	overrides, err := newPeerOverrides(
+		c.logger,
+		c.getenv("PEER_OVERRIDES_CONFIGMAP"),
+		c.getenv("POD_NAMESPACE"),
+	)
+	if err != nil {
+		return fmt.Errorf("PEER_OVERRIDES_CONFIGMAP: %w", err)
+	}
+
+	// A10 connection supervisor
+	healthCheckInterval := defaultHealthCheckInterval
+	if interval := c.getenv("A10_HEALTH_CHECK_INTERVAL"); interval != "" {
+		healthCheckInterval, err = time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("A10_HEALTH_CHECK_INTERVAL must be a duration: %w", err)
+		}
+	}
+
+	// BGP session exporter
+	var sessionScrapeInterval time.Duration
+	if interval := c.getenv("BGP_SESSION_SCRAPE_INTERVAL"); interval != "" {
+		sessionScrapeInterval, err = time.ParseDuration(interval)
+		if err != nil || sessionScrapeInterval < 0 {
+			return fmt.Errorf("BGP_SESSION_SCRAPE_INTERVAL must be a non-negative duration")
+		}
+	}
+	sessionSource := c.getenv("A10_SESSION_SOURCE")
+	switch sessionSource {
+	case "":
+		sessionSource = sessionSourceAXAPI
+	case sessionSourceAXAPI, sessionSourceSNMP:
+	default:
+		return fmt.Errorf("A10_SESSION_SOURCE must be axapi or snmp, got %q", sessionSource)
+	}
+	snmpCommunity := Secret(c.getenv("A10_SNMP_COMMUNITY"))
+	if sessionSource == sessionSourceSNMP && snmpCommunity == "" {
+		return fmt.Errorf("A10_SNMP_COMMUNITY must be set for the snmp session source")
+	}
+	snmpPort := uint16(defaultSNMPPort)
+	if port := c.getenv("A10_SNMP_PORT"); port != "" {
+		parsed, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || parsed == 0 {
+			return fmt.Errorf("A10_SNMP_PORT must be a port number")
+		}
+		snmpPort = uint16(parsed)
+	}
+	if backend != backendAXAPI && sessionScrapeInterval > 0 && sessionSource == sessionSourceAXAPI {
+		return fmt.Errorf("BGP_SESSION_SCRAPE_INTERVAL needs the aXAPI backend or the snmp session source")
+	}
+
+	// Peering status of the nodes as A10Peers and Node conditions
+	peerStatusCRD := false
+	switch value := c.getenv("PEER_STATUS_CRD"); value {
+	case "", "false":
+	case "true":
+		peerStatusCRD = true
+	default:
+		return fmt.Errorf("PEER_STATUS_CRD must be true or false, got %q", value)
+	}
+	nodeAnnotations := false
+	switch value := c.getenv("NODE_STATUS_ANNOTATIONS"); value {
+	case "", "false":
+	case "true":
+		nodeAnnotations = true
+	default:
+		return fmt.Errorf("NODE_STATUS_ANNOTATIONS must be true or false, got %q", value)
+	}
+	nodeCondition := c.getenv("NODE_PEERED_CONDITION")
+	if nodeCondition != "" && !conditionTypePattern.MatchString(nodeCondition) {
+		return fmt.Errorf("NODE_PEERED_CONDITION must be a condition type like A10BGPPeered, got %q", nodeCondition)
+	}
+	peerStatusInterval := defaultPeerStatusInterval
+	if interval := c.getenv("PEER_STATUS_INTERVAL"); interval != "" {
+		peerStatusInterval, err = time.ParseDuration(interval)
+		if err != nil || peerStatusInterval <= 0 {
+			return fmt.Errorf("PEER_STATUS_INTERVAL must be a positive duration")
+		}
+	}
+
+	// Metrics push
+	metricsPushInterval := defaultMetricsPushInterval
+	if interval := c.getenv("METRICS_PUSH_INTERVAL"); interval != "" {
+		metricsPushInterval, err = time.ParseDuration(interval)
+		if err != nil || metricsPushInterval <= 0 {
+			return fmt.Errorf("METRICS_PUSH_INTERVAL must be a positive duration")
+		}
+	}
+	metricsPushJob := c.getenv("METRICS_PUSH_JOB")
+	if metricsPushJob == "" {
+		metricsPushJob = defaultMetricsPushJob
+	}
+	var metricsSinks []metricsSink
+	if pushgatewayURL := c.getenv("METRICS_PUSHGATEWAY_URL"); pushgatewayURL != "" {
+		metricsSinks = append(metricsSinks, newPushgatewaySink(pushgatewayURL, metricsPushJob, metricsInstance(metricsPushJob)))
+	}
+	if otlpURL := c.getenv("METRICS_OTLP_URL"); otlpURL != "" {
+		metricsSinks = append(metricsSinks, newOTLPSink(otlpURL, metricsPushJob, metricsInstance(metricsPushJob)))
+	}
+	if statsdAddress := c.getenv("METRICS_STATSD_ADDRESS"); statsdAddress != "" {
+		statsdFormat := c.getenv("METRICS_STATSD_FORMAT")
+		switch statsdFormat {
+		case "":
+			statsdFormat = statsdFormatStatsd
+		case statsdFormatStatsd, statsdFormatDatadog:
+		default:
+			return fmt.Errorf("METRICS_STATSD_FORMAT must be statsd or datadog, got %q", statsdFormat)
+		}
+		metricsSinks = append(metricsSinks, newStatsdSink(statsdAddress, statsdFormat, c.getenv("METRICS_STATSD_PREFIX")))
+	}
+	metricsPush := newMetricsPusher(c.logger, metricsPushInterval, metricsSinks...)
+
+	// Canary apply across devices
+	var canaryTimeout time.Duration
+	if timeout := c.getenv("CANARY_VERIFY_TIMEOUT"); timeout != "" {
+		canaryTimeout, err = time.ParseDuration(timeout)
+		if err != nil || canaryTimeout < 0 {
+			return fmt.Errorf("CANARY_VERIFY_TIMEOUT must be a non-negative duration")
+		}
+	}
+	if backend != backendAXAPI && canaryTimeout > 0 && sessionSource == sessionSourceAXAPI {
+		return fmt.Errorf("CANARY_VERIFY_TIMEOUT needs the aXAPI backend or the snmp session source")
+	}
+
+	// Post-add verification and rollback
+	var verifyAddTimeout time.Duration
+	if timeout := c.getenv("VERIFY_ADD_TIMEOUT"); timeout != "" {
+		verifyAddTimeout, err = time.ParseDuration(timeout)
+		if err != nil || verifyAddTimeout < 0 {
+			return fmt.Errorf("VERIFY_ADD_TIMEOUT must be a non-negative duration")
+		}
+	}
+	if backend != backendAXAPI && verifyAddTimeout > 0 && sessionSource == sessionSourceAXAPI {
+		return fmt.Errorf("VERIFY_ADD_TIMEOUT needs the aXAPI backend or the snmp session source")
+	}
+	rollback := false
+	switch value := c.getenv("ROLLBACK_FAILED_ADDS"); value {
+	case "", "false":
+	case "true":
+		rollback = true
+	default:
+		return fmt.Errorf("ROLLBACK_FAILED_ADDS must be true or false, got %q", value)
+	}
+	quarantineDuration := defaultQuarantineDuration
+	if duration := c.getenv("QUARANTINE_DURATION"); duration != "" {
+		quarantineDuration, err = time.ParseDuration(duration)
+		if err != nil || quarantineDuration <= 0 {
+			return fmt.Errorf("QUARANTINE_DURATION must be a positive duration")
+		}
+	}
+
+	// Flap detection
+	var flapThreshold int
+	if threshold := c.getenv("FLAP_THRESHOLD"); threshold != "" {
+		flapThreshold, err = strconv.Atoi(threshold)
+		if err != nil || flapThreshold < 0 {
+			return fmt.Errorf("FLAP_THRESHOLD must be a non-negative integer")
+		}
+	}
+	flapWindow := defaultFlapWindow
+	if window := c.getenv("FLAP_WINDOW"); window != "" {
+		flapWindow, err = time.ParseDuration(window)
+		if err != nil || flapWindow <= 0 {
+			return fmt.Errorf("FLAP_WINDOW must be a positive duration")
+		}
+	}
+	flapCooldown := defaultFlapCooldown
+	if cooldown := c.getenv("FLAP_COOLDOWN"); cooldown != "" {
+		flapCooldown, err = time.ParseDuration(cooldown)
+		if err != nil || flapCooldown <= 0 {
+			return fmt.Errorf("FLAP_COOLDOWN must be a positive duration")
+		}
+	}
+
+	// Batch checkpoints
+	var checkpoints *checkpointer
+	switch value := c.getenv("CHECKPOINT_BATCHES"); value {
+	case "", "false":
+	case "true":
+		checkpoints = &checkpointer{logger: c.logger, profile: c.getenv("CHECKPOINT_PROFILE")}
+	default:
+		return fmt.Errorf("CHECKPOINT_BATCHES must be true or false, got %q", value)
+	}
+	switch value := c.getenv("CHECKPOINT_AUTO_RESTORE"); value {
+	case "", "false":
+	case "true":
+		if checkpoints == nil {
+			return fmt.Errorf("CHECKPOINT_AUTO_RESTORE needs CHECKPOINT_BATCHES=true")
+		}
+		checkpoints.autoRestore = true
+	default:
+		return fmt.Errorf("CHECKPOINT_AUTO_RESTORE must be true or false, got %q", value)
+	}
+	if checkpoints != nil && backend != backendAXAPI {
+		return fmt.Errorf("CHECKPOINT_BATCHES needs the aXAPI backend")
+	}
+	if checkpoints != nil && coalesceWindow == 0 {
+		return fmt.Errorf("CHECKPOINT_BATCHES needs a non-zero COALESCE_WINDOW")
+	}
+
+	// Node event rate spikes
+	eventSpikeMin := defaultEventSpikeMin
+	if value := c.getenv("NODE_EVENT_SPIKE_MIN"); value != "" {
+		eventSpikeMin, err = strconv.Atoi(value)
+		if err != nil || eventSpikeMin < 0 {
+			return fmt.Errorf("NODE_EVENT_SPIKE_MIN must be a non-negative integer")
+		}
+	}
+
+	// Node watch staleness
+	watchStaleAfter := defaultWatchStaleAfter
+	if threshold := c.getenv("WATCH_STALE_THRESHOLD"); threshold != "" {
+		watchStaleAfter, err = time.ParseDuration(threshold)
+		if err != nil || watchStaleAfter < 0 {
+			return fmt.Errorf("WATCH_STALE_THRESHOLD must be a non-negative duration")
+		}
+	}
+
+	if (backend == backendSSH || backend == backendGNMI) && credentialsSource == credentialsSourceToken {
+		return fmt.Errorf("the %s backend needs a username and password, not a token", backend)
+	}
+	for _, device := range devices {
+		if device.partition != "" && backend != backendAXAPI {
+			return fmt.Errorf("device %s: partitions need the aXAPI backend", device.address)
+		}
+		if device.overridesNeighbors() && backend != backendAXAPI {
+			return fmt.Errorf("device %s: neighbor attributes need the aXAPI backend", device.address)
+		}
+	}
+
+	// Startup permission preflight
+	preflightNeighbor := defaultPreflightNeighbor
+	switch preflight := c.getenv("A10_PREFLIGHT"); preflight {
+	case "", "true":
+		if probe := c.getenv("A10_PREFLIGHT_NEIGHBOR"); probe != "" {
+			preflightNeighbor, err = parseNeighborIP(probe)
+			if err != nil {
+				return fmt.Errorf("A10_PREFLIGHT_NEIGHBOR must be an IPv4 address: %w", err)
+			}
+		}
+	case "false":
+		preflightNeighbor = ""
+	default:
+		return fmt.Errorf("A10_PREFLIGHT must be true or false, got %q", preflight)
+	}
+
+	// Additional managed remote ASNs
+	var managedRemoteAS []int
+	for _, asn := range strings.Split(c.getenv("A10_MANAGED_REMOTE_AS"), ",") {
+		asn = strings.TrimSpace(asn)
+		if asn == "" {
+			continue
+		}
+		asnInt, err := strconv.Atoi(asn)
+		if err != nil {
+			return fmt.Errorf("A10_MANAGED_REMOTE_AS must be a list of numbers: %w", err)
+		}
+		managedRemoteAS = append(managedRemoteAS, asnInt)
+	}
+	// Remote AS per label selector, its ASNs are managed too
+	remoteASTable, err := parseRemoteASTable(c.getenv("A10_REMOTE_AS_SELECTORS"))
+	if err != nil {
+		return fmt.Errorf("A10_REMOTE_AS_SELECTORS: %w", err)
+	}
+	for _, asn := range remoteASTable.asns() {
+		if !slices.Contains(managedRemoteAS, asn) {
+			managedRemoteAS = append(managedRemoteAS, asn)
+		}
+	}
+
+	// Node address to peer with, kube-router peers from the node IP
+	integration := c.getenv("BGP_INTEGRATION")
+	addressType := v1.NodeAddressType(c.getenv("NODE_ADDRESS_TYPE"))
+	switch addressType {
+	case "":
+		addressType = v1.NodeExternalIP
+		if integration == "kube-router" {
+			addressType = v1.NodeInternalIP
+		}
+	case v1.NodeExternalIP, v1.NodeInternalIP, v1.NodeInternalDNS, v1.NodeExternalDNS:
+	default:
+		return fmt.Errorf("NODE_ADDRESS_TYPE must be ExternalIP, InternalIP, InternalDNS or ExternalDNS, got %q", addressType)
+	}
+	dnsCacheTTL := defaultDNSCacheTTL
+	if ttl := c.getenv("DNS_CACHE_TTL"); ttl != "" {
+		dnsCacheTTL, err = time.ParseDuration(ttl)
+		if err != nil || dnsCacheTTL <= 0 {
+			return fmt.Errorf("DNS_CACHE_TTL must be a positive duration")
+		}
+	}
+
+	// Sharding between active replicas
+	shardMode := c.getenv("SHARD_MODE")
+	shardCount, shardIndex := 1, 0
+	switch shardMode {
+	case shardModeNone:
+	case shardModeNode, shardModeDevice:
+		shardCount, err = strconv.Atoi(c.getenv("SHARD_COUNT"))
+		if err != nil || shardCount < 1 {
+			return fmt.Errorf("SHARD_COUNT must be a positive number when SHARD_MODE is set")
+		}
+		if index := c.getenv("SHARD_INDEX"); index != "" {
+			shardIndex, err = strconv.Atoi(index)
+			if err != nil {
+				return fmt.Errorf("SHARD_INDEX must be a number: %w", err)
+			}
+		} else {
+			shardIndex, err = shardIndexFromPodName(c.getenv("POD_NAME"))
+			if err != nil {
+				return fmt.Errorf("SHARD_INDEX or POD_NAME with an ordinal must be set: %w", err)
+			}
+		}
+		if shardIndex < 0 || shardIndex >= shardCount {
+			return fmt.Errorf("shard index must be between 0 and %d", shardCount-1)
+		}
+	default:
+		return fmt.Errorf("SHARD_MODE must be node or device, got %q", shardMode)
+	}
+
+	// Device BGP neighbor limit
+	maxNeighbors := 0
+	if limit := c.getenv("A10_MAX_NEIGHBORS"); limit != "" {
+		maxNeighbors, err = strconv.Atoi(limit)
+		if err != nil || maxNeighbors < 0 {
+			return fmt.Errorf("A10_MAX_NEIGHBORS must be a non-negative number")
+		}
+	}
+	neighborLimitWarnRatio := defaultNeighborLimitWarnRatio
+	if ratio := c.getenv("A10_NEIGHBOR_LIMIT_WARN_RATIO"); ratio != "" {
+		neighborLimitWarnRatio, err = strconv.ParseFloat(ratio, 64)
+		if err != nil || neighborLimitWarnRatio <= 0 || neighborLimitWarnRatio > 1 {
+			return fmt.Errorf("A10_NEIGHBOR_LIMIT_WARN_RATIO must be a number in (0, 1]")
+		}
+	}
+
+	// Protected neighbors
+	protected, err := parseProtectedNeighbors(c.getenv("A10_PROTECTED_NEIGHBORS"))
+	if err != nil {
+		return fmt.Errorf("A10_PROTECTED_NEIGHBORS: %w", err)
+	}
+
+	// Static neighbors
+	static, err := parseStaticNeighbors(c.getenv("A10_STATIC_NEIGHBORS"))
+	if err != nil {
+		return fmt.Errorf("A10_STATIC_NEIGHBORS: %w", err)
+	}
+
+	// Neighbor CIDR allowlist
+	allowedNeighbors, err := parseCIDRAllowlist(c.getenv("ALLOWED_NEIGHBOR_CIDRS"))
+	if err != nil {
+		return fmt.Errorf("ALLOWED_NEIGHBOR_CIDRS: %w", err)
+	}
+	// Node subnet policy
+	subnetPolicy, err := parseSubnetPolicy(
+		c.logger,
+		c.getenv("SUBNET_POLICY"),
+		cmp.Or(c.getenv("SUBNET_POLICY_LABEL"), defaultSubnetPolicyLabel),
+		c.getenv("SUBNET_POLICY_MODE"),
+	)
+	if err != nil {
+		return fmt.Errorf("SUBNET_POLICY: %w", err)
+	}
+	// Managed regions and zones
+	scope := parseTopologyScope(c.getenv("MANAGED_REGIONS"), c.getenv("MANAGED_ZONES"))
+	for _, neighbor := range static {
+		if !allowedNeighbors.allows(neighbor.IP) {
+			return fmt.Errorf("static neighbor %s is outside ALLOWED_NEIGHBOR_CIDRS", neighbor.IP)
+		}
+	}
+	// Shared node addresses
+	sharedNodeAddresses := c.getenv("SHARED_NODE_ADDRESSES") == "true"
+
+	// Device certificate pinning
+	tlsFingerprints, err := parseFingerprints(c.getenv("A10_TLS_FINGERPRINTS"))
+	if err != nil {
+		return fmt.Errorf("A10_TLS_FINGERPRINTS: %w", err)
+	}
+
+	// Neighbor payload template
+	neighborTemplate, err := newNeighborTemplate(
+		c.getenv("A10_NEIGHBOR_TEMPLATE"),
+		c.getenv("A10_NEIGHBOR_TEMPLATE_FILE"),
+	)
+	if err != nil {
+		return fmt.Errorf("A10_NEIGHBOR_TEMPLATE: %w", err)
+	}
+	neighborExtraAttrs, err := parseNeighborAttrs(c.getenv("A10_NEIGHBOR_EXTRA_ATTRS"))
+	if err != nil {
+		return fmt.Errorf("A10_NEIGHBOR_EXTRA_ATTRS: %w", err)
+	}
+
+	// Neighbor local AS override
+	localAS := 0
+	if value := c.getenv("A10_NEIGHBOR_LOCAL_AS"); value != "" {
+		localAS, err = strconv.Atoi(value)
+		if err != nil || localAS <= 0 {
+			return fmt.Errorf("A10_NEIGHBOR_LOCAL_AS must be a positive integer")
+		}
+	}
+	localASAnnotation := c.getenv("NODE_LOCAL_AS_ANNOTATION")
+	if (localAS != 0 || localASAnnotation != "") && backend != backendAXAPI {
+		return fmt.Errorf("A10_NEIGHBOR_LOCAL_AS and NODE_LOCAL_AS_ANNOTATION need the aXAPI backend")
+	}
+
+	c.RemoteAS = remoteASInt
+	c.Devices = devices
+	c.Username = a10Username
+	c.Password = Secret(a10Password)
+	c.CredentialsSource = credentialsSource
+	c.CredentialsRefresh = credentialsRefresh
+	c.AS = a10AsInt
+	c.LabelSelector = labelSelector
+	c.NodeSelector = nodeSelector
+	c.EligibilityChecks = strings.Split(eligibilityChecks, ",")
+	c.WebhookURL = c.getenv("NODE_ELIGIBILITY_WEBHOOK_URL")
+	c.WebhookToken = Secret(c.getenv("NODE_ELIGIBILITY_WEBHOOK_TOKEN"))
+	c.WebhookFailOpen = webhookFailOpen
+	c.WebhookTimeout = webhookTimeout
+	c.NodeLeaseMaxAge = nodeLeaseMaxAge
+	c.Workers = workers
+	c.CoalesceWindow = coalesceWindow
+	c.BatchJitter = batchJitter
+	c.InformerResync = informerResync
+	c.BatchJitterThreshold = batchJitterThreshold
+	c.ReconcileDeadline = reconcileDeadline
+	c.ReconcileInterval = reconcileInterval
+	c.Heartbeat = heartbeat
+	c.SyncWebhook = newSyncWebhook(c.logger, Secret(c.getenv("SYNC_WEBHOOK_SECRET")), devices)
+	c.RemovalDelay = removalDelay
+	c.TombstoneTTL = tombstoneTTL
+	c.ShutdownGracePeriod = shutdownGracePeriod
+	c.RemovalGuard = removalGuard
+	c.Pause = pause
+	c.Approvals = approvals
+	c.ApprovalToken = Secret(c.getenv("APPROVAL_TOKEN"))
+	c.RestoreToken = Secret(c.getenv("CHECKPOINT_RESTORE_TOKEN"))
+	c.ChangeWindows = changeWindows
+	c.MaxChangesPerMinute = maxChangesPerMinute
+	c.CanaryTimeout = canaryTimeout
+	c.VerifyAddTimeout = verifyAddTimeout
+	c.Rollback = rollback
+	c.QuarantineDuration = quarantineDuration
+	c.Flaps = newFlapDetector(flapThreshold, flapWindow, flapCooldown)
+	c.Checkpoints = checkpoints
+	c.NotifyURL = c.getenv("NOTIFY_WEBHOOK_URL")
+	c.EventSpikeMin = eventSpikeMin
+	c.WatchStaleAfter = watchStaleAfter
+	c.PeerOverrides = overrides
+	c.SessionIdleTimeout = sessionIdleTimeout
+	c.AuthFailureLimit = authFailureLimit
+	c.AuthLockout = authLockout
+	c.Transport = transport
+	c.Chaos = chaos
+	c.Backend = backend
+	c.RESTBackend = restBackend
+	c.GNMI = gnmi
+	c.SSHPort = sshPort
+	c.SSHHostKeys = sshHostKeys
+	c.ResponseSchema = responseSchema
+	c.NeighborCacheTTL = neighborCacheTTL
+	c.HealthCheckInterval = healthCheckInterval
+	c.SessionScrapeInterval = sessionScrapeInterval
+	c.PeerStatusCRD = peerStatusCRD
+	c.NodeCondition = nodeCondition
+	c.NodeAnnotations = nodeAnnotations
+	c.PeerStatusInterval = peerStatusInterval
+	c.MetricsPush = metricsPush
+	c.SessionSource = sessionSource
+	c.SNMPCommunity = snmpCommunity
+	c.SNMPPort = snmpPort
+	c.PreflightNeighbor = preflightNeighbor
+	c.NodeASNAnnotation = c.getenv("NODE_ASN_ANNOTATION")
+	c.Integration = integration
+	c.NodeAddressType = addressType
+	c.NodeAddressFallback = c.getenv("NODE_ADDRESS_FALLBACK")
+	c.DNSCacheTTL = dnsCacheTTL
+	c.ManagedRemoteAS = managedRemoteAS
+	c.RemoteASTable = remoteASTable
+	c.MaxNeighbors = maxNeighbors
+	c.NeighborLimitWarnRatio = neighborLimitWarnRatio
+	c.ProtectedNeighbors = protected
+	c.StaticNeighbors = static
+	c.AllowedNeighbors = allowedNeighbors
+	c.SubnetPolicy = subnetPolicy
+	c.Scope = scope
+	c.SharedNodeAddresses = sharedNodeAddresses
+	c.TLSFingerprints = tlsFingerprints
+	c.NeighborTemplate = neighborTemplate
+	c.NeighborExtraAttrs = neighborExtraAttrs
+	c.LocalAS = localAS
+	c.LocalASAnnotation = localASAnnotation
+	c.ShardMode = shardMode
+	c.ShardCount = shardCount
+	c.ShardIndex = shardIndex
+	c.StatusAddress = c.getenv("STATUS_ADDRESS")
+	if c.StatusAddress == "" {
+		c.StatusAddress = defaultStatusAddress
+	}
+	c.ResyncToken = Secret(c.getenv("RESYNC_TOKEN"))
+
+	if unused := c.source.unused(); len(unused) > 0 {
+		c.logger.Warn("Ignoring unused configuration keys", "keys", unused)
+	}
+	return nil
+}
+
+// getenv returns the value of the configuration key, empty for the default.
+func (c *Config) getenv(key string) string {
+	return c.source.get(key)
+}
+
+// Log logs the effective configuration.
+func (c *Config) Log() {
+	c.logger.Info("Inputs", c.fields()...)
+}
+
+// fields returns the effective configuration as key/value pairs, with the
+// secrets masked.
+func (c *Config) fields() []interface{} {
+	return []interface{}{
+		"a10Addresses",
+		c.addresses(),
+		"topologyFile",
+		c.getenv("A10_TOPOLOGY_FILE"),
+		"a10Username",
+		c.Username,
+		"a10Password",
+		c.Password,
+		"a10UsernameFile",
+		c.UsernameFile,
+		"a10PasswordFile",
+		c.PasswordFile,
+		"credentialsSource",
+		c.CredentialsSource,
+		"a10AS",
+		c.AS,
+		"remoteAS",
+		c.RemoteAS,
+		"labelSelector",
+		c.LabelSelector,
+		"eligibilityChecks",
+		c.EligibilityChecks,
+		"webhookURL",
+		c.WebhookURL,
+		"webhookFailOpen",
+		c.WebhookFailOpen,
+		"nodeLeaseMaxAge",
+		c.NodeLeaseMaxAge,
+		"workers",
+		c.Workers,
+		"coalesceWindow",
+		c.CoalesceWindow,
+		"batchJitter",
+		c.BatchJitter,
+		"informerResync",
+		c.InformerResync,
+		"batchJitterThreshold",
+		c.BatchJitterThreshold,
+		"reconcileDeadline",
+		c.ReconcileDeadline,
+		"reconcileInterval",
+		c.ReconcileInterval,
+		"heartbeatLease",
+		c.getenv("HEARTBEAT_LEASE"),
+		"heartbeatURL",
+		c.getenv("HEARTBEAT_URL"),
+		"removalDelay",
+		c.RemovalDelay,
+		"tombstoneTTL",
+		c.TombstoneTTL,
+		"shutdownGracePeriod",
+		c.ShutdownGracePeriod,
+		"maxRemovals",
+		c.RemovalGuard,
+		"pauseConfigMap",
+		c.getenv("PAUSE_CONFIGMAP"),
+		"approvalThreshold",
+		c.getenv("APPROVAL_THRESHOLD"),
+		"approvalConfigMap",
+		c.getenv("APPROVAL_CONFIGMAP"),
+		"changeWindows",
+		c.ChangeWindows,
+		"changeWindowAdditions",
+		c.getenv("CHANGE_WINDOW_ADDITIONS"),
+		"maxChangesPerMinute",
+		c.MaxChangesPerMinute,
+		"canaryTimeout",
+		c.CanaryTimeout,
+		"verifyAddTimeout",
+		c.VerifyAddTimeout,
+		"rollback",
+		c.Rollback,
+		"quarantineDuration",
+		c.QuarantineDuration,
+		"flapThreshold",
+		c.getenv("FLAP_THRESHOLD"),
+		"flapWindow",
+		c.getenv("FLAP_WINDOW"),
+		"flapCooldown",
+		c.getenv("FLAP_COOLDOWN"),
+		"checkpointBatches",
+		c.Checkpoints != nil,
+		"checkpointProfile",
+		c.getenv("CHECKPOINT_PROFILE"),
+		"checkpointAutoRestore",
+		c.getenv("CHECKPOINT_AUTO_RESTORE"),
+		"notifyURL",
+		c.NotifyURL,
+		"eventSpikeMin",
+		c.EventSpikeMin,
+		"watchStaleAfter",
+		c.WatchStaleAfter,
+		"peerOverridesConfigMap",
+		c.getenv("PEER_OVERRIDES_CONFIGMAP"),
+		"sessionIdleTimeout",
+		c.SessionIdleTimeout,
+		"authFailureLimit",
+		c.AuthFailureLimit,
+		"authLockout",
+		c.AuthLockout,
+		"maxIdleConns",
+		c.Transport.maxIdleConns,
+		"idleConnTimeout",
+		c.Transport.idleConnTimeout,
+		"http2",
+		c.Transport.http2,
+		"chaos",
+		c.Chaos,
+		"backend",
+		c.Backend,
+		"sshPort",
+		c.SSHPort,
+		"sshKnownHosts",
+		c.getenv("A10_SSH_KNOWN_HOSTS"),
+		"restBackendFile",
+		c.getenv("A10_REST_BACKEND_FILE"),
+		"gnmiPort",
+		c.GNMI.port,
+		"gnmiNetworkInstance",
+		c.GNMI.networkInstance,
+		"gnmiProtocol",
+		c.GNMI.protocol,
+		"responseSchema",
+		c.ResponseSchema,
+		"neighborCacheTTL",
+		c.NeighborCacheTTL,
+		"healthCheckInterval",
+		c.HealthCheckInterval,
+		"sessionScrapeInterval",
+		c.SessionScrapeInterval,
+		"sessionSource",
+		c.SessionSource,
+		"snmpCommunity",
+		c.SNMPCommunity,
+		"snmpPort",
+		c.SNMPPort,
+		"peerStatusCRD",
+		c.PeerStatusCRD,
+		"nodePeeredCondition",
+		c.NodeCondition,
+		"nodeStatusAnnotations",
+		c.NodeAnnotations,
+		"peerStatusInterval",
+		c.PeerStatusInterval,
+		"metricsPushgatewayURL",
+		c.getenv("METRICS_PUSHGATEWAY_URL"),
+		"metricsOTLPURL",
+		c.getenv("METRICS_OTLP_URL"),
+		"metricsStatsdAddress",
+		c.getenv("METRICS_STATSD_ADDRESS"),
+		"metricsStatsdFormat",
+		c.getenv("METRICS_STATSD_FORMAT"),
+		"metricsStatsdPrefix",
+		c.getenv("METRICS_STATSD_PREFIX"),
+		"metricsPushJob",
+		c.getenv("METRICS_PUSH_JOB"),
+		"metricsPushInterval",
+		c.getenv("METRICS_PUSH_INTERVAL"),
+		"preflightNeighbor",
+		c.PreflightNeighbor,
+		"nodeASNAnnotation",
+		c.NodeASNAnnotation,
+		"integration",
+		c.Integration,
+		"managedRemoteAS",
+		c.ManagedRemoteAS,
+		"remoteASSelectors",
+		c.RemoteASTable.String(),
+		"nodeAddressType",
+		c.NodeAddressType,
+		"nodeAddressFallback",
+		c.NodeAddressFallback,
+		"dnsCacheTTL",
+		c.DNSCacheTTL,
+		"maxNeighbors",
+		c.MaxNeighbors,
+		"protectedNeighbors",
+		c.ProtectedNeighbors,
+		"staticNeighbors",
+		c.getenv("A10_STATIC_NEIGHBORS"),
+		"allowedNeighborCIDRs",
+		c.AllowedNeighbors,
+		"subnetPolicy",
+		c.getenv("SUBNET_POLICY"),
+		"subnetPolicyMode",
+		c.getenv("SUBNET_POLICY_MODE"),
+		"scope",
+		c.Scope.String(),
+		"sharedNodeAddresses",
+		c.SharedNodeAddresses,
+		"tlsPinned",
+		len(c.TLSFingerprints) > 0,
+		"apiApprovals",
+		c.ApprovalToken != "",
+		"checkpointRestore",
+		c.RestoreToken != "",
+		"manualResync",
+		c.ResyncToken != "",
+		"syncWebhook",
+		c.SyncWebhook != nil,
+		"neighborTemplate",
+		c.NeighborTemplate != nil,
+		"neighborExtraAttrs",
+		c.NeighborExtraAttrs,
+		"localAS",
+		c.LocalAS,
+		"localASAnnotation",
+		c.LocalASAnnotation,
+		"shardMode",
+		c.ShardMode,
+		"shardCount",
+		c.ShardCount,
+		"shardIndex",
+		c.ShardIndex,
+	}
+}
+
+// addresses returns the addresses of the devices.
+func (c *Config) addresses() []string {
+	addresses := make([]string, 0, len(c.Devices))
+	for _, device := range c.Devices {
+		addresses = append(addresses, device.address)
+	}
+	return addresses
+}
+
+func gracefulShutdown(logger *log.Logger, cancel context.CancelFunc) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		<-sigCh
+		logger.Info("shutting down...")
+		cancel()
+		<-sigCh
+		logger.Fatal("Forced shutdown")
+	}()
+}
@@ -0,0 +1,625 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"k8s.io/client-go/dynamic"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// Manager is the controller keeping the BGP neighbors of the A10 devices in
+// sync with the Kubernetes nodes. It is what the a10-bgp-neighbor-manager
+// binary runs and can be embedded in other Go programs. The logger, the
+// node addresses and the policies of a run are owned by the manager, so
+// several managers can run in one process; only the metrics are
+// process-wide.
+type Manager struct {
+	logger     *log.Logger
+	args       []string
+	configFile string
+	settings   map[string]string
+	kubeConfig *rest.Config
+	backend    BackendFactory
+	config     Config
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithArgs sets the command line flags the configuration is loaded from,
+// e.g. os.Args[1:]. The flags override the config file and the environment.
+func WithArgs(args []string) Option {
+	return func(m *Manager) {
+		m.args = args
+	}
+}
+
+// WithConfigFile sets the YAML config file the configuration is loaded from,
+// overriding CONFIG_FILE and the config file flag of WithArgs, whatever the
+// order of the options.
+func WithConfigFile(path string) Option {
+	return func(m *Manager) {
+		m.configFile = path
+	}
+}
+
+// WithSettings sets configuration keys, e.g. A10_ADDRESS, overriding the
+// flags, the config file and the environment.
+func WithSettings(settings map[string]string) Option {
+	return func(m *Manager) {
+		if m.settings == nil {
+			m.settings = map[string]string{}
+		}
+		for key, value := range settings {
+			m.settings[configKey(key)] = value
+		}
+	}
+}
+
+// WithLogger sets the logger of the manager.
+func WithLogger(l *log.Logger) Option {
+	return func(m *Manager) {
+		m.logger = l
+	}
+}
+
+// WithKubeConfig sets the Kubernetes client configuration instead of the
+// KUBECONFIG or in-cluster one.
+func WithKubeConfig(config *rest.Config) Option {
+	return func(m *Manager) {
+		m.kubeConfig = config
+	}
+}
+
+// WithBackend sets the backend of the devices, e.g. the in-memory fake of
+// the fake package, instead of A10_BACKEND.
+func WithBackend(factory BackendFactory) Option {
+	return func(m *Manager) {
+		m.backend = factory
+	}
+}
+
+// New returns a manager with the options applied and its configuration
+// loaded and validated.
+// Returns an error if the configuration is invalid.
+func New(opts ...Option) (*Manager, error) {
+	m := &Manager{}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.logger == nil {
+		m.logger = log.NewWithOptions(os.Stderr, log.Options{ReportTimestamp: true})
+	}
+	m.config.logger = m.logger
+	args := m.args
+	if m.configFile != "" {
+		args = append(slices.Clip(args), "--"+configFileFlag, m.configFile)
+	}
+	source, err := loadConfigSource(args)
+	if err != nil {
+		return nil, fmt.Errorf("loading configuration: %w", err)
+	}
+	maps.Copy(source.flags, m.settings)
+	m.config.source = source
+	if err := m.config.Get(); err != nil {
+		return nil, fmt.Errorf("getting configuration: %w", err)
+	}
+	m.config.backendFactory = m.backend
+	m.config.Log()
+	return m, nil
+}
+
+// Run runs the controller until the context is canceled, then drains the
+// in-flight changes and the due removals for up to SHUTDOWN_GRACE_PERIOD.
+// Returns an error if the controller fails to start.
+func (m *Manager) Run(ctx context.Context) error {
+	config := &m.config
+
+	// The A10 operations outlive the context to drain them on shutdown
+	opsCtx, cancelOps := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelOps()
+
+	nodes, err := newNodeState(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	// Build node eligibility checks chain
+	checks, err := newEligibilityChecks(config.EligibilityChecks, config, nodes)
+	if err != nil {
+		return fmt.Errorf("configuring eligibility checks: %w", err)
+	}
+
+	if config.Chaos != nil {
+		m.logger.Warn("Chaos mode is on, injecting failures into the A10 requests", "chaos", config.Chaos)
+	}
+
+	// Get Kubernetes client
+	kubeConfig := m.kubeConfig
+	if kubeConfig == nil {
+		m.logger.Info("Getting Kubernetes client")
+		kubeConfig, err = getKubernetesConfig()
+		if err != nil {
+			return fmt.Errorf("getting Kubernetes client: %w", err)
+		}
+	}
+	clientset, err := getKubernetesClient(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("getting Kubernetes client: %w", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(kubeConfig)
+	if err != nil {
+		return fmt.Errorf("getting Kubernetes dynamic client: %w", err)
+	}
+
+	// Load the BGP speaker configuration of the integration
+	peers, err := newPeerSource(config.Integration, dynamicClient, config)
+	if err != nil {
+		return fmt.Errorf("configuring BGP integration: %w", err)
+	}
+	if peers != nil {
+		if err := peers.start(ctx); err != nil {
+			return fmt.Errorf("starting BGP integration: %w", err)
+		}
+	}
+
+	// Start status server
+	status := newStatusTracker()
+	quarantine := newQuarantine(m.logger, config.QuarantineDuration)
+	status.quarantine = quarantine
+	tombstones := newTombstones(config.TombstoneTTL)
+	status.tombstones = tombstones
+	status.approvals = config.Approvals
+	status.nodeState = nodes
+	health := &healthState{}
+	statusServer := StatusServer{
+		logger:        m.logger,
+		ctx:           ctx,
+		address:       config.StatusAddress,
+		status:        status,
+		health:        health,
+		checkpoints:   config.Checkpoints,
+		restoreToken:  config.RestoreToken,
+		approvals:     config.Approvals,
+		approvalToken: config.ApprovalToken,
+		resyncToken:   config.ResyncToken,
+		resyncs:       make(chan struct{}, 1),
+		syncWebhook:   config.SyncWebhook,
+	}
+	statusServer.Start()
+
+	// Resolve A10 credentials
+	credentialsProvider, err := newCredentialsProvider(ctx, config)
+	if err != nil {
+		return fmt.Errorf("configuring A10 credentials: %w", err)
+	}
+	var sharedCreds Credentials
+	if sharedCredentials(config.Devices) {
+		sharedCreds, err = credentialsProvider.Credentials(ctx)
+		if err != nil {
+			return fmt.Errorf("getting A10 credentials: %w", err)
+		}
+	}
+
+	// Get A10 devices current neighbors
+	sharder := &Sharder{
+		mode:  config.ShardMode,
+		count: config.ShardCount,
+		index: config.ShardIndex,
+	}
+	devices := Devices{
+		logger:        m.logger,
+		status:        status,
+		nodeState:     nodes,
+		removalGuard:  config.RemovalGuard,
+		approvals:     config.Approvals,
+		pause:         config.Pause,
+		canaryTimeout: config.CanaryTimeout,
+		verifyTimeout: config.VerifyAddTimeout,
+		rollback:      config.Rollback,
+		quarantine:    quarantine,
+		flaps:         config.Flaps,
+		tombstones:    tombstones,
+		checkpoints:   config.Checkpoints,
+		notifier:      newNotifier(m.logger, config.NotifyURL),
+		recorder:      newNodeEventRecorder(ctx, clientset),
+	}
+	for i, device := range config.Devices {
+		if !sharder.ownsDevice(i) {
+			m.logger.Info("Device is managed by another shard", "device", device.address)
+			continue
+		}
+		creds := sharedCreds
+		if device.credentials != nil {
+			creds, err = device.credentials.Credentials(ctx)
+			if err != nil {
+				return fmt.Errorf("getting A10 credentials of device %s: %w", device.address, err)
+			}
+		}
+		devices.devices = append(devices.devices, newA10(opsCtx, device, creds, config))
+	}
+	health.setDevices(&devices)
+	config.Heartbeat.start(ctx, clientset)
+	if err := config.Pause.start(ctx, clientset); err != nil {
+		return fmt.Errorf("watching pause ConfigMap: %w", err)
+	}
+	if config.Approvals != nil {
+		config.Approvals.notifier = devices.notifier
+	}
+	if err := config.Approvals.start(ctx, clientset); err != nil {
+		return fmt.Errorf("watching approval ConfigMap: %w", err)
+	}
+	if err := config.PeerOverrides.start(ctx, clientset); err != nil {
+		return fmt.Errorf("watching peer overrides ConfigMap: %w", err)
+	}
+	if checks.uses("lease") {
+		nodes.leases = newNodeLeaseTracker(m.logger, config.NodeLeaseMaxAge)
+		if err := nodes.leases.start(ctx, clientset); err != nil {
+			return fmt.Errorf("watching node Leases: %w", err)
+		}
+	}
+	if config.PreflightNeighbor != "" && !config.Pause.isPaused() {
+		if err := devices.preflight(config.PreflightNeighbor); err != nil {
+			return fmt.Errorf("A10 permission preflight failed: %w", err)
+		}
+	}
+	if err := devices.GetNeighbors(); err != nil {
+		return fmt.Errorf("getting neighbors from A10: %w", err)
+	}
+
+	// Pick up rotated credentials
+	go watchCredentials(ctx, credentialsProvider, &devices, config.CredentialsRefresh)
+
+	// Start workers to apply neighbor changes
+	queue := newWorkQueue(
+		opsCtx,
+		m.logger,
+		&devices,
+		config.Workers,
+		config.CoalesceWindow,
+		config.RemovalDelay,
+	)
+	queue.maintenance = config.Pause
+	queue.windows = config.ChangeWindows
+	queue.jitter = config.BatchJitter
+	queue.jitterThreshold = config.BatchJitterThreshold
+	queue.reconcileDeadline = config.ReconcileDeadline
+	queue.Start()
+	health.setQueue(queue)
+
+	// Start informer to watch for changes in k8s
+	neighbors := Neighbors{
+		logger:       m.logger,
+		ctx:          ctx,
+		clientset:    clientset,
+		selector:     config.NodeSelector,
+		checks:       checks,
+		queue:        queue,
+		status:       status,
+		sharder:      sharder,
+		nodeState:    nodes,
+		asn:          newASNChecker(m.logger, config.NodeASNAnnotation, config.RemoteAS, nodes.remoteAS),
+		peers:        peers,
+		static:       config.StaticNeighbors,
+		overrides:    config.PeerOverrides,
+		events:       &eventRate{logger: m.logger, spikeMin: config.EventSpikeMin},
+		resyncPeriod: config.InformerResync,
+	}
+	if config.WatchStaleAfter > 0 {
+		neighbors.watch = &watchHealth{logger: m.logger, clientset: clientset, staleAfter: config.WatchStaleAfter}
+	}
+	if err := neighbors.StartInformer(); err != nil {
+		return fmt.Errorf("starting informer: %w", err)
+	}
+	health.setInformer(neighbors.synced)
+
+	// Get Kubernetes nodes from the informer cache
+	kubeNodes := KubeNodes{
+		logger:    m.logger,
+		lister:    neighbors.lister,
+		nodeState: nodes,
+		selector:  config.NodeSelector,
+		checks:    checks,
+		peers:     peers,
+		static:    config.StaticNeighbors,
+		overrides: config.PeerOverrides,
+	}
+	if err := kubeNodes.GetNodes(); err != nil {
+		return fmt.Errorf("getting nodes from k8s: %w", err)
+	}
+
+	// Add missing and remove extra neighbors in parallel
+	reconciled := reconcileNeighbors(&devices, &kubeNodes, queue, sharder)
+	health.reconciled()
+	config.Heartbeat.beat()
+	go queue.trackProgress("initial reconciliation", reconciled)
+
+	// resyncFrom reconciles the devices with the nodes of the lister
+	resyncFrom := func(name string, lister corelisters.NodeLister) {
+		kubeNodes := KubeNodes{
+			logger:    m.logger,
+			lister:    lister,
+			nodeState: nodes,
+			selector:  config.NodeSelector,
+			checks:    checks,
+			peers:     peers,
+			static:    config.StaticNeighbors,
+			overrides: config.PeerOverrides,
+		}
+		if err := kubeNodes.GetNodes(); err != nil {
+			m.logger.Error("Error getting nodes from k8s", "error", err)
+			return
+		}
+		reconciled := reconcileNeighbors(&devices, &kubeNodes, queue, sharder)
+		health.reconciled()
+		config.Heartbeat.beat()
+		if len(reconciled) > 0 {
+			go queue.trackProgress(name, reconciled)
+		}
+	}
+	// resync reconciles the devices with the current state of k8s
+	resync := func(name string) {
+		resyncFrom(name, neighbors.lister)
+	}
+
+	// Relist the nodes after the watch failed for too long
+	if neighbors.watch != nil {
+		go neighbors.watch.run(ctx, func(lister corelisters.NodeLister) {
+			resyncFrom("watch recovery reconciliation", lister)
+		})
+	}
+
+	// Recover from lost device connections
+	supervisor := Supervisor{
+		logger:   m.logger,
+		ctx:      ctx,
+		devices:  &devices,
+		interval: config.HealthCheckInterval,
+		resync:   func() { resync("recovery reconciliation") },
+	}
+	supervisor.Start()
+
+	// Apply the drift when the writes are resumed and the held changes when
+	// maintenance is lifted
+	if config.Pause != nil {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-config.Pause.resumed:
+					resync("resumed reconciliation")
+				case <-config.Pause.lifted:
+					queue.release()
+				}
+			}
+		}()
+	}
+
+	// Reconcile periodically, the unchanged cycles are skipped
+	if config.ReconcileInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(config.ReconcileInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					resync("periodic reconciliation")
+				}
+			}
+		}()
+	}
+
+	// Apply the approved removals
+	if config.Approvals != nil {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-config.Approvals.approvedRemovals():
+					resync("approved reconciliation")
+				}
+			}
+		}()
+	}
+
+	// Re-fetch and reconcile the devices whose cached state was found stale
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-queue.resyncs:
+				if err := devices.GetNeighbors(); err != nil {
+					m.logger.Error("Error getting neighbors from A10", "error", err)
+				}
+				resync("stale state reconciliation")
+			}
+		}
+	}()
+
+	// Re-fetch and reconcile the devices on request, even if nothing seems
+	// to have changed
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-statusServer.resyncs:
+				devices.converged.forget()
+				if err := devices.GetNeighbors(); err != nil {
+					m.logger.Error("Error getting neighbors from A10", "error", err)
+				}
+				resync("manual reconciliation")
+			}
+		}
+	}()
+
+	// Re-fetch and reconcile the devices changed by external systems
+	if config.SyncWebhook != nil {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-config.SyncWebhook.synced:
+					addresses, ok := config.SyncWebhook.take()
+					if !ok {
+						continue
+					}
+					devices.converged.forget()
+					if err := devices.getNeighborsOf(addresses); err != nil {
+						m.logger.Error("Error getting neighbors from A10", "error", err)
+					}
+					resync("webhook reconciliation")
+				}
+			}
+		}()
+	}
+
+	// Follow the BGP speaker configuration changes
+	if peers != nil {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-peers.changed():
+					m.logger.Info("BGP integration configuration changed")
+					resync("integration reconciliation")
+				}
+			}
+		}()
+	}
+
+	// Follow the node address changes in DNS
+	if nodes.resolver != nil {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-nodes.resolver.changed:
+					resync("address reconciliation")
+				}
+			}
+		}()
+	}
+
+	// Withdraw the nodes whose kubelet Lease expired and restore the renewed
+	// ones
+	if nodes.leases != nil {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-nodes.leases.changed:
+					resync("lease reconciliation")
+				}
+			}
+		}()
+	}
+
+	// Export the BGP session state
+	sessionExporter := SessionExporter{
+		logger:   m.logger,
+		ctx:      ctx,
+		devices:  &devices,
+		status:   status,
+		interval: config.SessionScrapeInterval,
+	}
+	sessionExporter.Start()
+
+	// Mirror the peering status to the A10Peers, the Node conditions and
+	// annotations
+	if config.PeerStatusCRD || config.NodeCondition != "" || config.NodeAnnotations {
+		writer := &peerStatusWriter{
+			logger:      m.logger,
+			client:      dynamicClient,
+			clientset:   clientset,
+			nodes:       neighbors.lister,
+			status:      status,
+			interval:    config.PeerStatusInterval,
+			crd:         config.PeerStatusCRD,
+			condition:   config.NodeCondition,
+			annotations: config.NodeAnnotations,
+		}
+		writer.start(ctx)
+	}
+
+	// Push the metrics to the monitoring systems that can't scrape them
+	config.MetricsPush.start(ctx)
+
+	// Follow the peer overrides changes
+	if config.PeerOverrides != nil {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-config.PeerOverrides.changed:
+					resync("peer overrides reconciliation")
+				}
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	queue.drain(config.ShutdownGracePeriod)
+	return nil
+}
+
+// Main runs the a10-bgp-neighbor-manager binary: the preflight check, the
+// configuration validation or the controller configured by the environment
+// and the command line flags.
+func Main() {
+	// Initialize logger
+	level := log.InfoLevel
+	if os.Getenv("DEBUG") != "" {
+		level = log.DebugLevel
+	}
+	logger := log.NewWithOptions(os.Stderr, log.Options{
+		// ReportCaller:    true,
+		ReportTimestamp: true,
+		Level:           level,
+		// Formatter:       log.LogfmtFormatter,
+	})
+
+	// Setup context and graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gracefulShutdown(logger, cancel)
+
+	// Run the preflight check instead of the controller
+	if isCheckCommand() {
+		code := runCheck(ctx, logger)
+		cancel()
+		os.Exit(code)
+	}
+
+	// Validate the configuration instead of running the controller
+	if isValidateConfig() {
+		code := runValidateConfig(ctx, logger)
+		cancel()
+		os.Exit(code)
+	}
+
+	m, err := New(WithArgs(os.Args[1:]), WithLogger(logger))
+	if err != nil {
+		logger.Fatal("Error starting the manager:", err)
+	}
+	if err := m.Run(ctx); err != nil {
+		logger.Fatal("Error running the manager:", err)
+	}
+}
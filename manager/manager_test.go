@@ -0,0 +1,43 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewConfigFileWithArgs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := `
+a10-address: https://a10.example.com
+a10-username: admin
+a10-password: secret
+a10-as: 65000
+a10-remote-as: 65001
+nodes-label-selector: bgp=a10
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"config file first", []Option{WithConfigFile(path), WithArgs([]string{"--a10-remote-as", "65002"})}},
+		{"args first", []Option{WithArgs([]string{"--a10-remote-as", "65002"}), WithConfigFile(path)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(append(tt.opts, WithLogger(testLogger()))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.config.RemoteAS != 65002 {
+				t.Errorf("remote AS = %d, want the flag 65002", m.config.RemoteAS)
+			}
+			if !m.config.NodeSelector.matches(map[string]string{"bgp": "a10"}) {
+				t.Errorf("node selector %v, want the config file bgp=a10", m.config.NodeSelector)
+			}
+		})
+	}
+}
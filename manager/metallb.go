@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var metallbBGPPeers = schema.GroupVersionResource{
+	Group:    "metallb.io",
+	Version:  "v1beta2",
+	Resource: "bgppeers",
+}
+
+// metallbBGPPeer holds the fields of a MetalLB BGPPeer the integration uses.
+type metallbBGPPeer struct {
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              struct {
+		MyASN         int                    `json:"myASN"`
+		PeerASN       int                    `json:"peerASN"`
+		NodeSelectors []metav1.LabelSelector `json:"nodeSelectors"`
+	} `json:"spec"`
+}
+
+func init() {
+	registerPeerSource("metallb", func(client dynamic.Interface, config *Config) (peerSource, error) {
+		return &metallbPeers{
+			logger:  config.logger,
+			watcher: newCRDWatcher(config.logger, client, metallbBGPPeers),
+			as:      config.AS,
+		}, nil
+	})
+}
+
+// metallbPeers peers the nodes the MetalLB speakers peer with the devices
+// from, i.e. the nodes selected by a BGPPeer whose peer ASN is the device AS.
+// The neighbor remote AS is the BGPPeer's own ASN.
+type metallbPeers struct {
+	logger  *log.Logger
+	watcher *crdWatcher
+	as      int
+}
+
+func (m *metallbPeers) start(ctx context.Context) error {
+	return m.watcher.start(ctx)
+}
+
+func (m *metallbPeers) changed() <-chan struct{} {
+	return m.watcher.changed()
+}
+
+func (m *metallbPeers) peer(node *v1.Node) (bool, int, string) {
+	peers, err := list[metallbBGPPeer](m.watcher, metallbBGPPeers)
+	if err != nil {
+		m.logger.Error("Error listing MetalLB BGP peers", "error", err)
+		return false, 0, err.Error()
+	}
+	for _, peer := range peers {
+		if peer.Spec.PeerASN != m.as {
+			continue
+		}
+		selected, err := selectedBy(node, peer.Spec.NodeSelectors)
+		if err != nil {
+			m.logger.Warn("Invalid MetalLB BGPPeer node selector", "peer", peer.Name, "error", err)
+			continue
+		}
+		if selected {
+			return true, peer.Spec.MyASN, fmt.Sprintf("selected by MetalLB BGPPeer %s", peer.Name)
+		}
+	}
+	return false, 0, fmt.Sprintf("no MetalLB BGPPeer with peer ASN %d selects the node", m.as)
+}
+
+// selectedBy checks if the node matches any of the label selectors.
+// No selectors select every node.
+// Returns an error if a selector is invalid.
+func selectedBy(node *v1.Node, selectors []metav1.LabelSelector) (bool, error) {
+	if len(selectors) == 0 {
+		return true, nil
+	}
+	for _, selector := range selectors {
+		parsed, err := metav1.LabelSelectorAsSelector(&selector)
+		if err != nil {
+			return false, err
+		}
+		if parsed.Matches(labels.Set(node.Labels)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
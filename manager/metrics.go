@@ -0,0 +1,293 @@
+package manager
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "a10_bgp_neighbor_manager"
+
+var (
+	neighborSynced = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "neighbor_synced",
+		Help:      "Whether the neighbor is in sync on the device (1) or not (0).",
+	}, []string{"device", "neighbor"})
+
+	neighborLastSync = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "neighbor_last_sync_timestamp_seconds",
+		Help:      "Unix time of the last successful sync changing the neighbor on the device.",
+	}, []string{"device", "neighbor"})
+
+	neighborRetries = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "neighbor_sync_retries",
+		Help:      "Number of failed sync attempts of the neighbor on the device since the last successful sync.",
+	}, []string{"device", "neighbor"})
+
+	neighborSyncErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "neighbor_sync_errors_total",
+		Help:      "Total number of failed neighbor sync operations per device.",
+	}, []string{"device"})
+
+	deviceNeighbors = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "device_neighbors",
+		Help:      "Total number of BGP neighbors configured on the device.",
+	}, []string{"device"})
+
+	managedNeighbors = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "managed_neighbors",
+		Help:      "Number of BGP neighbors managed by the controller on the device.",
+	}, []string{"device"})
+
+	deviceNeighborLimit = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "device_neighbor_limit",
+		Help:      "Configured maximum number of BGP neighbors of the device.",
+	}, []string{"device"})
+
+	deviceUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "device_up",
+		Help:      "Whether the device is reachable (1) or not (0).",
+	}, []string{"device"})
+
+	massRemovalsBlocked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "mass_removals_blocked_total",
+		Help:      "Total number of reconciles whose removals were refused by the removal limit.",
+	}, []string{"device"})
+
+	writesPaused = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "writes_paused",
+		Help:      "Whether the A10 writes are paused (1) or not (0).",
+	})
+
+	maintenanceMode = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "maintenance_mode",
+		Help:      "Whether maintenance mode holds the neighbor changes (1) or not (0).",
+	})
+
+	heldChanges = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "held_changes",
+		Help:      "Number of neighbor changes held until maintenance is lifted.",
+	})
+
+	deferredChanges = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "deferred_changes",
+		Help:      "Number of neighbor changes deferred until the next change window.",
+	})
+
+	changesRateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "changes_rate_limited_total",
+		Help:      "Total number of neighbor changes delayed by the change rate limit per device.",
+	}, []string{"device"})
+
+	canaryFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "canary_failures_total",
+		Help:      "Total number of neighbor additions aborted by a failed canary verification per canary device.",
+	}, []string{"device"})
+
+	rollbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "neighbor_rollbacks_total",
+		Help:      "Total number of neighbors rolled back after a failed add or verification per device.",
+	}, []string{"device"})
+
+	quarantinedNeighbors = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "quarantined_neighbors",
+		Help:      "Number of neighbors quarantined after a rollback or for flapping.",
+	})
+
+	checkpointFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "checkpoint_failures_total",
+		Help:      "Total number of failures to checkpoint the neighbors before a batch per device.",
+	}, []string{"device"})
+
+	checkpointRestores = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "checkpoint_restores_total",
+		Help:      "Total number of checkpoints restored after a failed batch per device.",
+	}, []string{"device"})
+
+	nodeEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "node_events_total",
+		Help:      "Total number of node informer events per event type.",
+	}, []string{"event"})
+
+	nodeEventSpikes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "node_event_spikes_total",
+		Help:      "Total number of node event rate spikes.",
+	})
+
+	nodeWatchFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "node_watch_failures_total",
+		Help:      "Total number of node watch and relist failures.",
+	})
+
+	nodeWatchStale = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "node_watch_stale",
+		Help:      "Whether the node watch has been failing beyond the stale threshold (1) or not (0).",
+	})
+
+	syncErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "sync_errors_total",
+		Help:      "Total number of failed neighbor changes by error class: retryable, reauth, resync or fatal.",
+	}, []string{"class"})
+
+	reconcilesSkipped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconciles_skipped_total",
+		Help:      "Total number of reconcile cycles skipped as the nodes and the device neighbors were unchanged.",
+	})
+
+	disallowedNeighbors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "disallowed_neighbors_total",
+		Help:      "Total number of neighbors refused as their address is outside ALLOWED_NEIGHBOR_CIDRS.",
+	})
+
+	subnetPolicyViolation = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "subnet_policy_violation",
+		Help:      "Set to 1 when the node address is outside the subnets of its site or zone.",
+	}, []string{"node"})
+
+	duplicateNodeAddress = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "duplicate_node_address",
+		Help:      "Set to 1 when the node reports an address already peered by another node.",
+	}, []string{"node"})
+
+	sharedNodeAddresses = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "shared_node_addresses",
+		Help:      "Number of neighbor addresses shared by several eligible nodes.",
+	})
+
+	chaosFaultsInjected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "chaos_faults_injected_total",
+		Help:      "Total number of failures injected into the aXAPI requests in chaos mode per device and fault.",
+	}, []string{"device", "fault"})
+
+	removalsPendingApproval = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "removals_pending_approval",
+		Help:      "Number of neighbor removals staged until approved.",
+	})
+
+	tombstonedNeighbors = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "tombstoned_neighbors",
+		Help:      "Number of neighbors of deleted nodes shut down until their tombstone expires.",
+	})
+
+	flappingNeighbors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "flapping_neighbors_total",
+		Help:      "Total number of neighbors quarantined for flapping.",
+	})
+
+	metricsPushErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "metrics_push_errors_total",
+		Help:      "Total number of failed metrics pushes per sink.",
+	}, []string{"sink"})
+
+	lastHeartbeat = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "last_heartbeat_timestamp_seconds",
+		Help:      "Unix time of the last successful heartbeat per target: lease or url.",
+	}, []string{"target"})
+
+	heartbeatFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "heartbeat_failures_total",
+		Help:      "Total number of failed heartbeats per target: lease or url.",
+	}, []string{"target"})
+
+	syncNotifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "sync_notifications_total",
+		Help:      "Total number of device sync notifications received by the webhook per result: accepted, unauthorized or invalid.",
+	}, []string{"result"})
+
+	carriedChanges = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "carried_changes_total",
+		Help:      "Total number of neighbor changes carried into the next reconcile cycle after missing its deadline.",
+	})
+
+	authFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "auth_failures_total",
+		Help:      "Total number of A10 logins rejected by the device.",
+	}, []string{"device"})
+
+	authLockedOut = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "auth_locked_out",
+		Help:      "Whether the logins to the device are locked out after repeated rejections (1) or not (0).",
+	}, []string{"device"})
+
+	unexpectedResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "unexpected_responses_total",
+		Help:      "Total number of aXAPI responses of an unexpected schema.",
+	}, []string{"device", "response"})
+
+	apiRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "axapi_request_duration_seconds",
+		Help:      "Latency of the aXAPI requests by endpoint, including reading the response.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"device", "method", "endpoint"})
+
+	apiRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "axapi_requests_total",
+		Help:      "Total number of aXAPI requests by endpoint and status code, error if no response was received.",
+	}, []string{"device", "method", "endpoint", "code"})
+
+	bgpSessionEstablished = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "bgp_session_established",
+		Help:      "Whether the BGP session of the neighbor is established (1) or not (0).",
+	}, []string{"device", "neighbor", "node"})
+
+	bgpSessionUptime = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "bgp_session_uptime_seconds",
+		Help:      "Uptime of the BGP session of the neighbor.",
+	}, []string{"device", "neighbor", "node"})
+
+	bgpSessionPrefixes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "bgp_session_prefixes_received",
+		Help:      "Number of prefixes received from the neighbor.",
+	}, []string{"device", "neighbor", "node"})
+
+	nodeASNMismatch = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "node_asn_mismatch",
+		Help:      "Set to 1 when the node BGP speaker ASN doesn't match the remote AS.",
+	}, []string{"node"})
+)
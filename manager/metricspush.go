@@ -0,0 +1,405 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	defaultMetricsPushInterval = 30 * time.Second
+	defaultMetricsPushJob      = "a10-bgp-neighbor-manager"
+	// metricsPushTimeout bounds a single push, including the final one on
+	// shutdown
+	metricsPushTimeout = 10 * time.Second
+)
+
+// metricsSink pushes the metrics to a monitoring system that can't scrape
+// the controller.
+type metricsSink interface {
+	// push pushes the current metrics.
+	// Returns an error if the operation fails.
+	push(ctx context.Context) error
+	// String names the sink in the logs and metrics.
+	String() string
+}
+
+// metricsPusher pushes the metrics to the sinks every interval and once
+// more on shutdown, so the last changes aren't lost.
+type metricsPusher struct {
+	logger   *log.Logger
+	interval time.Duration
+	sinks    []metricsSink
+}
+
+// newMetricsPusher creates a metrics pusher for the sinks.
+// Returns nil without sinks.
+func newMetricsPusher(logger *log.Logger, interval time.Duration, sinks ...metricsSink) *metricsPusher {
+	if len(sinks) == 0 {
+		return nil
+	}
+	return &metricsPusher{logger: logger, interval: interval, sinks: sinks}
+}
+
+// metricsInstance returns the instance of the pushed metrics, the pod
+// hostname, or the job if it's unknown.
+func metricsInstance(job string) string {
+	instance, err := os.Hostname()
+	if err != nil {
+		return job
+	}
+	return instance
+}
+
+// start pushes the metrics in the background until the context is done.
+func (p *metricsPusher) start(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				p.push(context.Background())
+				return
+			case <-ticker.C:
+				p.push(ctx)
+			}
+		}
+	}()
+}
+
+// push pushes the metrics to every sink. Failures are logged and counted,
+// the next push sends the current values again.
+func (p *metricsPusher) push(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, metricsPushTimeout)
+	defer cancel()
+	for _, sink := range p.sinks {
+		if err := sink.push(ctx); err != nil {
+			metricsPushErrors.WithLabelValues(sink.String()).Inc()
+			p.logger.Error("Error pushing metrics", "sink", sink.String(), "error", err)
+		}
+	}
+}
+
+// pushgatewaySink pushes the metrics to a Prometheus Pushgateway, grouped
+// by job and instance so the replicas don't overwrite each other.
+type pushgatewaySink struct {
+	pusher *push.Pusher
+}
+
+// newPushgatewaySink creates a Pushgateway sink.
+func newPushgatewaySink(url, job, instance string) *pushgatewaySink {
+	pusher := push.New(url, job).
+		Gatherer(prometheus.DefaultGatherer).
+		Grouping("instance", instance).
+		Client(&http.Client{Timeout: metricsPushTimeout})
+	return &pushgatewaySink{pusher: pusher}
+}
+
+// push replaces the metrics of the group on the Pushgateway.
+func (s *pushgatewaySink) push(ctx context.Context) error {
+	return s.pusher.PushContext(ctx)
+}
+
+func (s *pushgatewaySink) String() string {
+	return "pushgateway"
+}
+
+// otlpAggregationCumulative is the OTLP cumulative aggregation temporality
+// of the Prometheus counters and histograms.
+const otlpAggregationCumulative = 2
+
+// otlpExport is the OTLP/HTTP JSON metrics export request.
+type otlpExport struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpScopeMetrics struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	Summary     *otlpSummary   `json:"summary,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type otlpSummary struct {
+	DataPoints []otlpSummaryPoint `json:"dataPoints"`
+}
+
+// The 64-bit integers are strings in the OTLP JSON encoding.
+
+type otlpNumberPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          float64         `json:"asDouble"`
+}
+
+type otlpHistogramPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               float64         `json:"sum"`
+	BucketCounts      []string        `json:"bucketCounts"`
+	ExplicitBounds    []float64       `json:"explicitBounds"`
+}
+
+type otlpSummaryPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               float64         `json:"sum"`
+	QuantileValues    []otlpQuantile  `json:"quantileValues"`
+}
+
+type otlpQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+// otlpSink posts the metrics to an OTLP/HTTP collector endpoint with the
+// JSON encoding, converted from the Prometheus registry: counters become
+// cumulative monotonic sums, gauges gauges, histograms and summaries their
+// OTLP counterparts.
+type otlpSink struct {
+	url      string
+	client   *http.Client
+	gatherer prometheus.Gatherer
+	resource otlpResource
+	// start is the start time of the cumulative points
+	start time.Time
+}
+
+// newOTLPSink creates an OTLP sink posting to the URL, e.g.
+// http://collector:4318/v1/metrics.
+func newOTLPSink(url, service, instance string) *otlpSink {
+	return &otlpSink{
+		url:      url,
+		client:   &http.Client{Timeout: metricsPushTimeout},
+		gatherer: prometheus.DefaultGatherer,
+		resource: otlpResource{Attributes: []otlpAttribute{
+			newOTLPAttribute("service.name", service),
+			newOTLPAttribute("service.instance.id", instance),
+		}},
+		start: time.Now(),
+	}
+}
+
+func (s *otlpSink) String() string {
+	return "otlp"
+}
+
+// push gathers the metrics and posts them to the collector.
+func (s *otlpSink) push(ctx context.Context) error {
+	families, err := s.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("gathering metrics: %w", err)
+	}
+	scope := otlpScopeMetrics{Metrics: s.convert(families, time.Now())}
+	scope.Scope.Name = metricsNamespace
+	body, err := json.Marshal(otlpExport{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     s.resource,
+		ScopeMetrics: []otlpScopeMetrics{scope},
+	}}})
+	if err != nil {
+		return fmt.Errorf("marshaling OTLP metrics: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating OTLP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting OTLP metrics: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("OTLP collector returned %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// convert converts the Prometheus metric families to OTLP metrics.
+// Non-finite values can't be encoded in JSON and are dropped.
+func (s *otlpSink) convert(families []*dto.MetricFamily, now time.Time) []otlpMetric {
+	start := otlpTime(s.start)
+	timestamp := otlpTime(now)
+	metrics := make([]otlpMetric, 0, len(families))
+	for _, family := range families {
+		metric := otlpMetric{Name: family.GetName(), Description: family.GetHelp()}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			sum := &otlpSum{AggregationTemporality: otlpAggregationCumulative, IsMonotonic: true}
+			for _, m := range family.GetMetric() {
+				if value := m.GetCounter().GetValue(); finite(value) {
+					sum.DataPoints = append(sum.DataPoints, otlpNumberPoint{
+						Attributes:        otlpAttributes(m),
+						StartTimeUnixNano: start,
+						TimeUnixNano:      timestamp,
+						AsDouble:          value,
+					})
+				}
+			}
+			metric.Sum = sum
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			gauge := &otlpGauge{}
+			for _, m := range family.GetMetric() {
+				value := m.GetGauge().GetValue()
+				if family.GetType() == dto.MetricType_UNTYPED {
+					value = m.GetUntyped().GetValue()
+				}
+				if finite(value) {
+					gauge.DataPoints = append(gauge.DataPoints, otlpNumberPoint{
+						Attributes:   otlpAttributes(m),
+						TimeUnixNano: timestamp,
+						AsDouble:     value,
+					})
+				}
+			}
+			metric.Gauge = gauge
+		case dto.MetricType_HISTOGRAM:
+			histogram := &otlpHistogram{AggregationTemporality: otlpAggregationCumulative}
+			for _, m := range family.GetMetric() {
+				h := m.GetHistogram()
+				if !finite(h.GetSampleSum()) {
+					continue
+				}
+				point := otlpHistogramPoint{
+					Attributes:        otlpAttributes(m),
+					StartTimeUnixNano: start,
+					TimeUnixNano:      timestamp,
+					Count:             strconv.FormatUint(h.GetSampleCount(), 10),
+					Sum:               h.GetSampleSum(),
+				}
+				// Prometheus buckets are cumulative, OTLP ones aren't and
+				// end with the overflow bucket
+				var previous uint64
+				for _, bucket := range h.GetBucket() {
+					if math.IsInf(bucket.GetUpperBound(), 1) {
+						continue
+					}
+					point.ExplicitBounds = append(point.ExplicitBounds, bucket.GetUpperBound())
+					point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(bucket.GetCumulativeCount()-previous, 10))
+					previous = bucket.GetCumulativeCount()
+				}
+				point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(h.GetSampleCount()-previous, 10))
+				histogram.DataPoints = append(histogram.DataPoints, point)
+			}
+			metric.Histogram = histogram
+		case dto.MetricType_SUMMARY:
+			summary := &otlpSummary{}
+			for _, m := range family.GetMetric() {
+				sm := m.GetSummary()
+				if !finite(sm.GetSampleSum()) {
+					continue
+				}
+				point := otlpSummaryPoint{
+					Attributes:        otlpAttributes(m),
+					StartTimeUnixNano: start,
+					TimeUnixNano:      timestamp,
+					Count:             strconv.FormatUint(sm.GetSampleCount(), 10),
+					Sum:               sm.GetSampleSum(),
+					QuantileValues:    []otlpQuantile{},
+				}
+				for _, quantile := range sm.GetQuantile() {
+					if finite(quantile.GetValue()) {
+						point.QuantileValues = append(point.QuantileValues, otlpQuantile{
+							Quantile: quantile.GetQuantile(),
+							Value:    quantile.GetValue(),
+						})
+					}
+				}
+				summary.DataPoints = append(summary.DataPoints, point)
+			}
+			metric.Summary = summary
+		default:
+			continue
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+// newOTLPAttribute creates a string OTLP attribute.
+func newOTLPAttribute(key, value string) otlpAttribute {
+	attribute := otlpAttribute{Key: key}
+	attribute.Value.StringValue = value
+	return attribute
+}
+
+// otlpAttributes converts the labels of the metric to OTLP attributes.
+func otlpAttributes(m *dto.Metric) []otlpAttribute {
+	attributes := make([]otlpAttribute, 0, len(m.GetLabel()))
+	for _, label := range m.GetLabel() {
+		attributes = append(attributes, newOTLPAttribute(label.GetName(), label.GetValue()))
+	}
+	return attributes
+}
+
+// otlpTime formats the time as OTLP JSON nanoseconds.
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// finite checks if the value can be encoded in JSON.
+func finite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
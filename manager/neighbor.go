@@ -0,0 +1,172 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"text/template"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Neighbor is a desired BGP neighbor derived from a node.
+type Neighbor struct {
+	IP       string
+	NodeName string
+	// RemoteAS overrides the device remote AS, 0 keeps it
+	RemoteAS    int
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// newNeighbor creates the neighbor of a node peering from the address.
+func newNeighbor(node *v1.Node, address string) Neighbor {
+	return Neighbor{
+		IP:          address,
+		NodeName:    node.Name,
+		Labels:      node.Labels,
+		Annotations: node.Annotations,
+	}
+}
+
+// parseNeighborAttrs parses a JSON object of extra neighbor attributes.
+// Returns nil if the attributes are empty.
+// Returns an error if they aren't a JSON object.
+func parseNeighborAttrs(raw string) (map[string]interface{}, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var attrs map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &attrs); err != nil {
+		return nil, fmt.Errorf("must be a JSON object: %w", err)
+	}
+	return attrs, nil
+}
+
+// neighborTemplateData is the data the neighbor payload template is
+// rendered with.
+type neighborTemplateData struct {
+	IP          string
+	RemoteAS    int
+	AS          int
+	LocalAS     int
+	NodeName    string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// neighborTemplate renders the ipv4-neighbor object of the neighbor create
+// payload, so arbitrary aXAPI neighbor attributes can be set per node.
+type neighborTemplate struct {
+	tmpl *template.Template
+}
+
+// newNeighborTemplate parses the template from the inline text or, if it's
+// empty, from the file. Returns nil if neither is set.
+// Returns an error if the template can't be read or parsed.
+func newNeighborTemplate(text, file string) (*neighborTemplate, error) {
+	if text == "" && file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading neighbor template: %w", err)
+		}
+		text = string(data)
+	}
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("neighbor").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing neighbor template: %w", err)
+	}
+	return &neighborTemplate{tmpl: tmpl}, nil
+}
+
+// render renders the template for the neighbor.
+// Returns an error if the result isn't a JSON object.
+func (t *neighborTemplate) render(data neighborTemplateData) (map[string]interface{}, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("rendering neighbor template: %w", err)
+	}
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &attrs); err != nil {
+		return nil, fmt.Errorf("neighbor template must render a JSON object: %w", err)
+	}
+	return attrs, nil
+}
+
+// neighborPayload builds the ipv4-neighbor object to create the neighbor.
+// The extra attributes are applied first and the template attributes
+// override them. The neighbor IP and remote AS are always set, and the
+// local AS if overridden.
+// Returns an error if the template fails.
+func (a *A10) neighborPayload(neighbor Neighbor) (map[string]interface{}, error) {
+	remoteAS := a.neighborRemoteAS(neighbor)
+	localAS := a.neighborLocalAS(neighbor)
+	attrs := map[string]interface{}{}
+	for key, value := range a.neighborExtraAttrs {
+		attrs[key] = value
+	}
+	if a.neighborTemplate != nil {
+		rendered, err := a.neighborTemplate.render(neighborTemplateData{
+			IP:          neighbor.IP,
+			RemoteAS:    remoteAS,
+			AS:          a.as,
+			LocalAS:     localAS,
+			NodeName:    neighbor.NodeName,
+			Labels:      neighbor.Labels,
+			Annotations: neighbor.Annotations,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range rendered {
+			attrs[key] = value
+		}
+	}
+	attrs["neighbor-ipv4"] = neighbor.IP
+	if _, ok := attrs["nbr-remote-as"]; !ok {
+		attrs["nbr-remote-as"] = remoteAS
+	}
+	if _, ok := attrs["local-as-list"]; !ok && localAS != 0 {
+		attrs["local-as-list"] = []map[string]interface{}{{"local-as": localAS}}
+	}
+	return attrs, nil
+}
+
+// neighborRemoteAS returns the remote AS of the neighbor on the device.
+func (a *A10) neighborRemoteAS(neighbor Neighbor) int {
+	if neighbor.RemoteAS != 0 {
+		return neighbor.RemoteAS
+	}
+	return a.remoteAS
+}
+
+// neighborLocalAS returns the local AS the device presents to the
+// neighbor: the node annotation if set, the device local AS override
+// otherwise, 0 for the device AS.
+func (a *A10) neighborLocalAS(neighbor Neighbor) int {
+	if value, ok := neighbor.Annotations[a.localASAnnotation]; ok && a.localASAnnotation != "" {
+		localAS, err := strconv.Atoi(value)
+		if err == nil && localAS > 0 {
+			return localAS
+		}
+		a.logger.Warn(
+			"Invalid local AS annotation, ignoring it",
+			"node", neighbor.NodeName,
+			"annotation", a.localASAnnotation,
+			"value", value,
+		)
+	}
+	return a.localAS
+}
+
+// managesAS checks if neighbors with the remote AS are managed by the
+// controller: A10_REMOTE_AS and the additional managed remote ASNs.
+func (a *A10) managesAS(remoteAS int) bool {
+	return remoteAS == a.remoteAS || slices.Contains(a.managedAS, remoteAS)
+}
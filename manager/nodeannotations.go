@@ -0,0 +1,134 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// The Node annotations with the peering status of the node.
+const (
+	peerAnnotationPrefix   = "a10bgp.rgeraskin.github.io/"
+	peerIPAnnotation       = peerAnnotationPrefix + "peer-ip"
+	peerDevicesAnnotation  = peerAnnotationPrefix + "devices"
+	peerLastSyncAnnotation = peerAnnotationPrefix + "last-sync"
+	peerSessionAnnotation  = peerAnnotationPrefix + "session-state"
+	peerPhaseAnnotation    = peerAnnotationPrefix + "phase"
+)
+
+// peerAnnotationKeys are the keys of the peering annotations.
+var peerAnnotationKeys = []string{
+	peerIPAnnotation,
+	peerDevicesAnnotation,
+	peerLastSyncAnnotation,
+	peerSessionAnnotation,
+	peerPhaseAnnotation,
+}
+
+// peerAnnotations derives the peering annotations of the node from the
+// status report: the peer IP, the devices peered with it, the latest sync,
+// the session state per device and the A10Peer phase. A nil value removes
+// the annotation, so the nodes no longer peered are cleaned up.
+func peerAnnotations(report statusReport, node nodeStatus) map[string]*string {
+	annotations := make(map[string]*string, len(peerAnnotationKeys))
+	for _, key := range peerAnnotationKeys {
+		annotations[key] = nil
+	}
+	if !node.Eligible || node.Address == "" {
+		return annotations
+	}
+	status := peerStatus(report, node)
+	var devices, sessions []string
+	lastSync := ""
+	for _, device := range status.Devices {
+		devices = append(devices, device.Device)
+		if device.Session != "" {
+			sessions = append(sessions, device.Device+"="+device.Session)
+		}
+		// RFC 3339 UTC times sort as strings
+		if device.LastSync > lastSync {
+			lastSync = device.LastSync
+		}
+	}
+	set := func(key, value string) {
+		if value != "" {
+			annotations[key] = &value
+		}
+	}
+	set(peerIPAnnotation, node.Address)
+	set(peerDevicesAnnotation, strings.Join(devices, ","))
+	set(peerLastSyncAnnotation, lastSync)
+	set(peerSessionAnnotation, strings.Join(sessions, ","))
+	set(peerPhaseAnnotation, status.Phase)
+	return annotations
+}
+
+// peerAnnotationsUpdate checks if the update of the node only changed its
+// peering annotations, written by the controller itself, so patching them
+// doesn't evaluate the node again.
+func peerAnnotationsUpdate(oldNode, node *v1.Node) bool {
+	if oldNode.ResourceVersion == node.ResourceVersion {
+		return false
+	}
+	changed := false
+	for _, key := range peerAnnotationKeys {
+		if oldNode.Annotations[key] != node.Annotations[key] {
+			changed = true
+		}
+	}
+	if !changed {
+		return false
+	}
+	withoutPeerAnnotations := func(node *v1.Node) *v1.Node {
+		node = node.DeepCopy()
+		node.ResourceVersion = ""
+		for _, key := range peerAnnotationKeys {
+			delete(node.Annotations, key)
+		}
+		if len(node.Annotations) == 0 {
+			node.Annotations = nil
+		}
+		return node
+	}
+	return equality.Semantic.DeepEqual(withoutPeerAnnotations(oldNode), withoutPeerAnnotations(node))
+}
+
+// writeAnnotations patches the peering annotations of every evaluated node
+// whose annotations changed. Failures are logged, the next run retries.
+func (w *peerStatusWriter) writeAnnotations(ctx context.Context, report statusReport) {
+	for nodeName, status := range report.Nodes {
+		node, err := w.nodes.Get(nodeName)
+		if err != nil {
+			continue
+		}
+		desired := peerAnnotations(report, status)
+		changed := false
+		for key, value := range desired {
+			current, ok := node.Annotations[key]
+			if value == nil && ok || value != nil && current != *value {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{"annotations": desired},
+		})
+		if err != nil {
+			w.logger.Error("Error marshaling node annotations", "node", nodeName, "error", err)
+			continue
+		}
+		_, err = w.clientset.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			w.logger.Error("Error patching node annotations", "node", nodeName, "error", err)
+			continue
+		}
+		w.logger.Debug("Patched node peering annotations", "node", nodeName)
+	}
+}
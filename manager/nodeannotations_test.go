@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPeerAnnotationsUpdate(t *testing.T) {
+	node := func(resourceVersion string, ready bool, annotations map[string]string) *v1.Node {
+		node := testNode("node-1", "10.0.0.1", map[string]string{"bgp": "a10"}, !ready)
+		node.ObjectMeta = metav1.ObjectMeta{
+			Name:            node.Name,
+			Labels:          node.Labels,
+			ResourceVersion: resourceVersion,
+			Annotations:     annotations,
+		}
+		return node
+	}
+	synced := map[string]string{peerIPAnnotation: "10.0.0.1", peerLastSyncAnnotation: "2026-01-01T00:00:00Z"}
+	resynced := map[string]string{peerIPAnnotation: "10.0.0.1", peerLastSyncAnnotation: "2026-01-01T00:01:00Z"}
+
+	tests := []struct {
+		name          string
+		oldNode, node *v1.Node
+		want          bool
+	}{
+		{
+			name:    "peering annotations added",
+			oldNode: node("1", true, nil),
+			node:    node("2", true, synced),
+			want:    true,
+		},
+		{
+			name:    "peering annotations changed",
+			oldNode: node("1", true, synced),
+			node:    node("2", true, resynced),
+			want:    true,
+		},
+		{
+			name:    "peering annotations removed",
+			oldNode: node("1", true, synced),
+			node:    node("2", true, map[string]string{}),
+			want:    true,
+		},
+		{
+			name:    "resync",
+			oldNode: node("1", true, synced),
+			node:    node("1", true, synced),
+		},
+		{
+			name:    "other annotation changed too",
+			oldNode: node("1", true, synced),
+			node:    node("2", true, map[string]string{peerIPAnnotation: "10.0.0.1", "example.com/bgp": "enabled"}),
+		},
+		{
+			name:    "status changed too",
+			oldNode: node("1", true, synced),
+			node:    node("2", false, resynced),
+		},
+		{
+			name:    "status changed only",
+			oldNode: node("1", true, synced),
+			node:    node("2", false, synced),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := peerAnnotationsUpdate(tt.oldNode, tt.node); got != tt.want {
+				t.Errorf("peerAnnotationsUpdate() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetSyncedLastSync(t *testing.T) {
+	status := newStatusTracker()
+	status.setPending("https://a10", "10.0.0.1", "node-1", true)
+	status.setSynced("https://a10", "10.0.0.1", false)
+	first := status.report().Devices["https://a10"]["10.0.0.1"].LastSync
+	if first.IsZero() {
+		t.Fatal("neighbor found on the device has no last sync")
+	}
+
+	status.setPending("https://a10", "10.0.0.1", "node-1", true)
+	status.setSynced("https://a10", "10.0.0.1", false)
+	if got := status.report().Devices["https://a10"]["10.0.0.1"].LastSync; !got.Equal(first) {
+		t.Errorf("unchanged neighbor last sync moved from %s to %s", first, got)
+	}
+
+	status.setPending("https://a10", "10.0.0.1", "node-1", true)
+	status.setSynced("https://a10", "10.0.0.1", true)
+	if got := status.report().Devices["https://a10"]["10.0.0.1"].LastSync; !got.After(first) {
+		t.Errorf("changed neighbor last sync = %s, want after %s", got, first)
+	}
+}
@@ -0,0 +1,120 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// conditionTypePattern matches the condition types, optionally prefixed
+// with a domain.
+var conditionTypePattern = regexp.MustCompile(`^([a-z0-9]([-a-z0-9.]*[a-z0-9])?/)?[A-Za-z][A-Za-z0-9]*$`)
+
+// The reasons of the peering Node condition.
+const (
+	conditionReasonEstablished    = "Established"
+	conditionReasonNotEstablished = "SessionNotEstablished"
+	conditionReasonSessionUnknown = "SessionStateUnknown"
+	conditionReasonPending        = "Pending"
+	conditionReasonSyncError      = "SyncError"
+	conditionReasonNotEligible    = "NotEligible"
+)
+
+// peerCondition derives the peering Node condition of the node from the
+// status report: True once its neighbor is configured and established on
+// every device, Unknown if it's configured but the sessions aren't scraped
+// and False otherwise.
+func peerCondition(conditionType string, report statusReport, node nodeStatus) v1.NodeCondition {
+	condition := v1.NodeCondition{Type: v1.NodeConditionType(conditionType), Status: v1.ConditionFalse}
+	if !node.Eligible || node.Address == "" {
+		condition.Reason = conditionReasonNotEligible
+		condition.Message = node.Reason
+		return condition
+	}
+	status := peerStatus(report, node)
+	var devices, failed, down []string
+	for _, device := range status.Devices {
+		devices = append(devices, device.Device)
+		if device.State == string(syncError) {
+			failed = append(failed, fmt.Sprintf("%s: %s", device.Device, device.LastError))
+		}
+		if device.Session != "" && device.Session != bgpEstablished {
+			down = append(down, fmt.Sprintf("%s: %s", device.Device, device.Session))
+		}
+	}
+	switch status.Phase {
+	case peerPhaseEstablished:
+		condition.Status = v1.ConditionTrue
+		condition.Reason = conditionReasonEstablished
+		condition.Message = fmt.Sprintf("neighbor %s established on %s", node.Address, strings.Join(devices, ", "))
+	case peerPhaseConfigured:
+		if len(down) == 0 {
+			condition.Status = v1.ConditionUnknown
+			condition.Reason = conditionReasonSessionUnknown
+			condition.Message = fmt.Sprintf("neighbor %s configured on %s", node.Address, strings.Join(devices, ", "))
+			break
+		}
+		condition.Reason = conditionReasonNotEstablished
+		condition.Message = fmt.Sprintf("neighbor %s not established on %s", node.Address, strings.Join(down, ", "))
+	case peerPhaseError:
+		condition.Reason = conditionReasonSyncError
+		condition.Message = fmt.Sprintf("neighbor %s failed to sync on %s", node.Address, strings.Join(failed, ", "))
+	default:
+		condition.Reason = conditionReasonPending
+		condition.Message = fmt.Sprintf("neighbor %s not configured yet", node.Address)
+		if status.Reason != node.Reason {
+			condition.Message += ", " + status.Reason
+		}
+	}
+	return condition
+}
+
+// writeConditions patches the peering condition of every evaluated node
+// whose condition changed. The transition time is kept while the condition
+// status doesn't change. Failures are logged, the next run retries.
+func (w *peerStatusWriter) writeConditions(ctx context.Context, report statusReport) {
+	for nodeName, status := range report.Nodes {
+		node, err := w.nodes.Get(nodeName)
+		if err != nil {
+			continue
+		}
+		desired := peerCondition(w.condition, report, status)
+		now := metav1.Now()
+		desired.LastHeartbeatTime = now
+		desired.LastTransitionTime = now
+		unchanged := false
+		for _, current := range node.Status.Conditions {
+			if current.Type != desired.Type || current.Status != desired.Status {
+				continue
+			}
+			unchanged = current.Reason == desired.Reason && current.Message == desired.Message
+			desired.LastTransitionTime = current.LastTransitionTime
+		}
+		if unchanged {
+			continue
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"status": map[string]interface{}{"conditions": []v1.NodeCondition{desired}},
+		})
+		if err != nil {
+			w.logger.Error("Error marshaling node condition", "node", nodeName, "error", err)
+			continue
+		}
+		if _, err := w.clientset.CoreV1().Nodes().PatchStatus(ctx, nodeName, patch); err != nil {
+			w.logger.Error("Error patching node condition", "node", nodeName, "condition", w.condition, "error", err)
+			continue
+		}
+		w.logger.Debug(
+			"Patched node condition",
+			"node", nodeName,
+			"condition", w.condition,
+			"status", desired.Status,
+			"reason", desired.Reason,
+		)
+	}
+}
@@ -0,0 +1,156 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	coordinationlisters "k8s.io/client-go/listers/coordination/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// defaultNodeLeaseMaxAge matches the default node monitor grace period of
+// the node lifecycle controller.
+const defaultNodeLeaseMaxAge = 40 * time.Second
+
+// nodeLeaseTracker follows the kubelet Leases in the node lease namespace
+// for the lease eligibility check. The kubelets renew them every 10 seconds
+// by default, so a dead node is noticed long before its Ready condition
+// changes. The Leases are swept periodically to reconcile as soon as one
+// expires or is renewed again, since no node event tells about it.
+type nodeLeaseTracker struct {
+	logger  *log.Logger
+	maxAge  time.Duration
+	lister  coordinationlisters.LeaseNamespaceLister
+	changed chan struct{}
+
+	mu sync.Mutex
+	// fresh is the freshness of the Leases at the latest sweep
+	fresh map[string]bool
+}
+
+func init() {
+	registerEligibilityCheck("lease", func(_ string, _ *Config, nodes *nodeState) (func(*v1.Node) (bool, string), error) {
+		return func(node *v1.Node) (bool, string) {
+			return nodes.leases.check(node)
+		}, nil
+	})
+}
+
+// newNodeLeaseTracker creates a tracker expiring the Leases not renewed for
+// maxAge.
+func newNodeLeaseTracker(logger *log.Logger, maxAge time.Duration) *nodeLeaseTracker {
+	return &nodeLeaseTracker{
+		logger:  logger,
+		maxAge:  maxAge,
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// start starts the Lease informer, waits for its cache to sync and sweeps
+// the Leases in the background until the context is done.
+// Returns an error if the cache doesn't sync.
+func (t *nodeLeaseTracker) start(ctx context.Context, clientset kubernetes.Interface) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		clientset,
+		0,
+		informers.WithNamespace(v1.NamespaceNodeLease),
+	)
+	leases := factory.Coordination().V1().Leases()
+	informer := leases.Informer()
+	t.lister = leases.Lister().Leases(v1.NamespaceNodeLease)
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return fmt.Errorf("timed out waiting for the node Lease cache to sync")
+	}
+	t.sweep()
+	go func() {
+		ticker := time.NewTicker(max(t.maxAge/4, time.Second))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.sweep()
+			}
+		}
+	}()
+	return nil
+}
+
+// check checks if the kubelet Lease of the node was renewed within the max
+// age. Nodes without a Lease fail the check.
+func (t *nodeLeaseTracker) check(node *v1.Node) (bool, string) {
+	if t == nil || t.lister == nil {
+		return true, "node Lease is not tracked"
+	}
+	lease, err := t.lister.Get(node.Name)
+	if err != nil {
+		return false, "node has no Lease"
+	}
+	if !t.isFresh(lease, time.Now()) {
+		return false, fmt.Sprintf("node Lease not renewed for %s", t.maxAge)
+	}
+	return true, "node Lease is fresh"
+}
+
+// isFresh checks if the Lease was renewed within the max age.
+func (t *nodeLeaseTracker) isFresh(lease *coordinationv1.Lease, now time.Time) bool {
+	renewed := lease.Spec.RenewTime
+	return renewed != nil && now.Sub(renewed.Time) <= t.maxAge
+}
+
+// sweep evaluates the freshness of every Lease and notifies when a Lease
+// expired, was renewed again, appeared or disappeared since the previous
+// sweep.
+func (t *nodeLeaseTracker) sweep() {
+	leases, err := t.lister.List(labels.Everything())
+	if err != nil {
+		t.logger.Error("Error listing node Leases", "error", err)
+		return
+	}
+	now := time.Now()
+	fresh := make(map[string]bool, len(leases))
+	for _, lease := range leases {
+		fresh[lease.Name] = t.isFresh(lease, now)
+	}
+
+	t.mu.Lock()
+	previous := t.fresh
+	t.fresh = fresh
+	t.mu.Unlock()
+	if previous == nil {
+		return
+	}
+	var expired, renewed []string
+	for name, isFresh := range fresh {
+		if was, ok := previous[name]; !ok || was != isFresh {
+			if isFresh {
+				renewed = append(renewed, name)
+			} else {
+				expired = append(expired, name)
+			}
+		}
+	}
+	for name := range previous {
+		if _, ok := fresh[name]; !ok {
+			expired = append(expired, name)
+		}
+	}
+	if len(expired) == 0 && len(renewed) == 0 {
+		return
+	}
+	t.logger.Info("Node Leases changed", "expired", expired, "renewed", renewed)
+	select {
+	case t.changed <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+// nodeState is how a manager reads the node addresses and which of them it
+// peers: the address settings, the address policies and the state they
+// track. It is owned by the Manager and shared with its informer, devices
+// and work queue, so several managers can run in the same process.
+type nodeState struct {
+	logger *log.Logger
+	// addressType is the type of the node address the devices peer with
+	addressType v1.NodeAddressType
+	// resolver resolves the DNS node addresses to IPs, nil unless the
+	// address type is a DNS one
+	resolver *dnsResolver
+	// fallback resolves the address of the nodes without an address of the
+	// configured type
+	fallback  *addressFallback
+	allowlist cidrAllowlist
+	subnets   *subnetPolicy
+	scope     *topologyScope
+	// claims are the addresses claimed by the eligible nodes
+	claims   *addressClaims
+	remoteAS *remoteASTable
+	// leases is the tracker of the kubelet Leases, set at startup if the
+	// eligibility chain has the lease check
+	leases *nodeLeaseTracker
+}
+
+// newNodeState creates the node state from the configuration and starts
+// resolving the DNS node addresses until the context is done.
+// Returns an error if the address fallback is invalid.
+func newNodeState(ctx context.Context, config *Config) (*nodeState, error) {
+	fallback, err := parseAddressFallback(ctx, config.logger, config.NodeAddressFallback)
+	if err != nil {
+		return nil, fmt.Errorf("configuring node address fallback: %w", err)
+	}
+	nodes := &nodeState{
+		logger:      config.logger,
+		addressType: config.NodeAddressType,
+		fallback:    fallback,
+		allowlist:   config.AllowedNeighbors,
+		subnets:     config.SubnetPolicy,
+		scope:       config.Scope,
+		claims:      newAddressClaims(config.logger, config.SharedNodeAddresses),
+		remoteAS:    config.RemoteASTable,
+	}
+	if dnsAddressType(nodes.addressType) {
+		nodes.resolver = newDNSResolver(config.logger, config.DNSCacheTTL)
+		go nodes.resolver.run(ctx)
+	}
+	return nodes, nil
+}
+
+// inScope checks if the node is in the topology scope and records the
+// address of the nodes out of it, so their neighbors are kept.
+func (s *nodeState) inScope(node *v1.Node) bool {
+	if s.scope.contains(node) {
+		s.scope.forget(node.Name)
+		return true
+	}
+	s.scope.exclude(node.Name, s.address(node))
+	return false
+}
@@ -0,0 +1,72 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// notification is the payload posted to the notifier webhook.
+type notification struct {
+	Event string      `json:"event"`
+	Time  time.Time   `json:"time"`
+	Data  interface{} `json:"data"`
+}
+
+// notifier posts the notable controller events to an operator webhook,
+// e.g. a chat or an incident tool integration.
+type notifier struct {
+	logger *log.Logger
+	url    string
+	client *http.Client
+}
+
+// newNotifier creates a notifier posting to the URL.
+// Returns nil if the URL is empty.
+func newNotifier(logger *log.Logger, url string) *notifier {
+	if url == "" {
+		return nil
+	}
+	return &notifier{logger: logger, url: url, client: &http.Client{Timeout: defaultWebhookTimeout}}
+}
+
+// notify posts the event with its data. Failures are logged, the
+// notifications are best effort.
+func (n *notifier) notify(ctx context.Context, event string, data interface{}) {
+	if n == nil {
+		return
+	}
+	if err := n.post(ctx, notification{Event: event, Time: time.Now(), Data: data}); err != nil {
+		n.logger.Error("Error sending notification", "event", event, "url", n.url, "error", err)
+	}
+}
+
+// post posts the notification to the webhook.
+// Returns an error if the operation fails.
+func (n *notifier) post(ctx context.Context, payload notification) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshaling notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating notification request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting notification: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("notifier answered %s", resp.Status)
+	}
+	return nil
+}
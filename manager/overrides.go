@@ -0,0 +1,139 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// overridesAddKey lists the peers to add on top of the node neighbors.
+	overridesAddKey = "add"
+	// overridesRemoveKey lists the peers to remove from the node neighbors.
+	overridesRemoveKey = "remove"
+)
+
+// peerOverrides are explicit peer entries operators maintain in a ConfigMap
+// and that are merged on top of the node-derived desired neighbors, an
+// escape hatch when the automation's view must be corrected temporarily.
+// Removals win over additions.
+type peerOverrides struct {
+	logger    *log.Logger
+	configMap configMapRef
+
+	mu     sync.RWMutex
+	add    staticNeighbors
+	remove map[string]struct{}
+	// changed is notified when the overrides change
+	changed chan struct{}
+}
+
+// newPeerOverrides creates overrides following the ConfigMap.
+// Returns nil if the reference is empty.
+// Returns an error if the reference is invalid.
+func newPeerOverrides(logger *log.Logger, ref, defaultNamespace string) (*peerOverrides, error) {
+	if ref == "" {
+		return nil, nil
+	}
+	configMap, err := parseConfigMapRef(ref, defaultNamespace)
+	if err != nil {
+		return nil, err
+	}
+	return &peerOverrides{
+		logger:    logger,
+		configMap: configMap,
+		remove:    map[string]struct{}{},
+		changed:   make(chan struct{}, 1),
+	}, nil
+}
+
+// start watches the ConfigMap and waits for its initial state.
+// Returns an error if the cache doesn't sync.
+func (o *peerOverrides) start(ctx context.Context, clientset kubernetes.Interface) error {
+	if o == nil {
+		return nil
+	}
+	if err := o.configMap.watch(ctx, clientset, o.follow); err != nil {
+		return err
+	}
+	// The initial reconciliation applies the initial overrides
+	select {
+	case <-o.changed:
+	default:
+	}
+	return nil
+}
+
+// follow applies the overrides of the ConfigMap data. Invalid overrides
+// are logged and the previous ones are kept.
+func (o *peerOverrides) follow(data map[string]string) {
+	logger := o.logger.With("configMap", o.configMap)
+	add, err := parseOverrideIPs(data[overridesAddKey])
+	if err != nil {
+		logger.Error("Invalid peer overrides, keeping the previous ones", "key", overridesAddKey, "error", err)
+		return
+	}
+	remove, err := parseOverrideIPs(data[overridesRemoveKey])
+	if err != nil {
+		logger.Error("Invalid peer overrides, keeping the previous ones", "key", overridesRemoveKey, "error", err)
+		return
+	}
+
+	o.mu.Lock()
+	o.add = nil
+	for _, neighborIP := range add {
+		o.add = append(o.add, Neighbor{IP: neighborIP})
+	}
+	o.remove = map[string]struct{}{}
+	for _, neighborIP := range remove {
+		o.remove[neighborIP] = struct{}{}
+	}
+	o.mu.Unlock()
+	logger.Info("Peer overrides changed", "add", add, "remove", remove)
+
+	select {
+	case o.changed <- struct{}{}:
+	default:
+	}
+}
+
+// parseOverrideIPs parses a list of IPs separated by commas or whitespace.
+// Returns an error if an entry is not an IP address.
+func parseOverrideIPs(list string) ([]string, error) {
+	var ips []string
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\t'
+	}) {
+		ip, err := parseNeighborIP(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer: %w", err)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+// adds returns the peers to add.
+func (o *peerOverrides) adds() staticNeighbors {
+	if o == nil {
+		return nil
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.add
+}
+
+// removes checks if the peer must be removed.
+func (o *peerOverrides) removes(neighborIP string) bool {
+	if o == nil {
+		return false
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	_, ok := o.remove[neighborIP]
+	return ok
+}
@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/charmbracelet/log"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// pauseKey is the ConfigMap key that pauses the A10 writes when "true".
+	pauseKey = "paused"
+	// maintenanceKey is the ConfigMap key that holds the neighbor changes
+	// until maintenance is lifted when "true".
+	maintenanceKey = "maintenance"
+)
+
+// errPaused is returned by the neighbor changes while the A10 writes are
+// paused. The changes are dropped, the drift is applied on resume.
+var errPaused = errors.New("A10 writes paused")
+
+// pauseSwitch is a cluster-visible switch halting all A10 writes, e.g.
+// during device maintenance, while the controller keeps watching the nodes
+// and reporting the drift. It follows the paused key of a ConfigMap.
+// The same ConfigMap switches maintenance mode with the maintenance key:
+// unlike a pause, which drops the changes and reconciles on resume,
+// maintenance holds the changes in the queue and applies them when lifted.
+type pauseSwitch struct {
+	logger      *log.Logger
+	configMap   configMapRef
+	paused      atomic.Bool
+	maintenance atomic.Bool
+	// resumed is notified when the writes are resumed, to apply the drift
+	resumed chan struct{}
+	// lifted is notified when maintenance is lifted, to apply the held
+	// changes
+	lifted chan struct{}
+}
+
+// newPauseSwitch creates a switch following the ConfigMap.
+// Returns nil if the reference is empty.
+// Returns an error if the reference is invalid.
+func newPauseSwitch(logger *log.Logger, ref, defaultNamespace string) (*pauseSwitch, error) {
+	if ref == "" {
+		return nil, nil
+	}
+	configMap, err := parseConfigMapRef(ref, defaultNamespace)
+	if err != nil {
+		return nil, err
+	}
+	return &pauseSwitch{
+		logger:    logger,
+		configMap: configMap,
+		resumed:   make(chan struct{}, 1),
+		lifted:    make(chan struct{}, 1),
+	}, nil
+}
+
+// isPaused checks if the A10 writes are paused.
+func (p *pauseSwitch) isPaused() bool {
+	return p != nil && p.paused.Load()
+}
+
+// inMaintenance checks if the neighbor changes are held for maintenance.
+func (p *pauseSwitch) inMaintenance() bool {
+	return p != nil && p.maintenance.Load()
+}
+
+// start watches the ConfigMap and waits for its initial state.
+// Returns an error if the cache doesn't sync.
+func (p *pauseSwitch) start(ctx context.Context, clientset kubernetes.Interface) error {
+	if p == nil {
+		return nil
+	}
+	return p.configMap.watch(ctx, clientset, p.follow)
+}
+
+// follow applies the switches of the ConfigMap data.
+func (p *pauseSwitch) follow(data map[string]string) {
+	p.set(data[pauseKey] == "true")
+	p.setMaintenance(data[maintenanceKey] == "true")
+}
+
+// setMaintenance switches maintenance mode and applies the held changes
+// when it's lifted.
+func (p *pauseSwitch) setMaintenance(maintenance bool) {
+	if p.maintenance.Swap(maintenance) == maintenance {
+		return
+	}
+	logger := p.logger.With("configMap", p.configMap)
+	if maintenance {
+		maintenanceMode.Set(1)
+		logger.Warn("Maintenance mode on, neighbor changes are held until it's lifted")
+		return
+	}
+	maintenanceMode.Set(0)
+	logger.Info("Maintenance mode lifted, applying the held neighbor changes")
+	select {
+	case p.lifted <- struct{}{}:
+	default:
+	}
+}
+
+// set switches the pause state and resumes the writes when it's lifted.
+func (p *pauseSwitch) set(paused bool) {
+	if p.paused.Swap(paused) == paused {
+		return
+	}
+	logger := p.logger.With("configMap", p.configMap)
+	if paused {
+		writesPaused.Set(1)
+		logger.Warn("A10 writes paused, neighbor changes are reported but not applied")
+		return
+	}
+	writesPaused.Set(0)
+	logger.Info("A10 writes resumed, applying the drift")
+	select {
+	case p.resumed <- struct{}{}:
+	default:
+	}
+}
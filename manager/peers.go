@@ -0,0 +1,209 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// peerSource derives which nodes peer with the devices, and with which ASN,
+// from the configuration of the BGP speaker running in the cluster, so the
+// device side mirrors the cluster side instead of a flat env configuration.
+type peerSource interface {
+	// start starts watching the speaker configuration and waits for it to
+	// be loaded.
+	start(ctx context.Context) error
+	// changed is notified when the speaker configuration changes.
+	changed() <-chan struct{}
+	// peer returns whether the node should peer with the devices, the
+	// remote AS of its neighbor, 0 for A10_REMOTE_AS, and the reason.
+	peer(node *v1.Node) (bool, int, string)
+}
+
+// peerSourceFactory builds the peer source of an integration.
+type peerSourceFactory func(client dynamic.Interface, config *Config) (peerSource, error)
+
+// peerSourceRegistry holds the known integrations by name.
+var peerSourceRegistry = map[string]peerSourceFactory{}
+
+// registerPeerSource adds an integration to the registry.
+func registerPeerSource(name string, factory peerSourceFactory) {
+	peerSourceRegistry[name] = factory
+}
+
+// newPeerSource builds the peer source of the integration.
+// Returns nil if no integration is configured.
+// Returns an error if the integration is unknown.
+func newPeerSource(name string, client dynamic.Interface, config *Config) (peerSource, error) {
+	if name == "" {
+		return nil, nil
+	}
+	factory, ok := peerSourceRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown BGP integration %q", name)
+	}
+	return factory(client, config)
+}
+
+// desiredNeighbor evaluates the eligibility of the node and, with an
+// integration, whether the speaker peers it with the devices. The remote AS
+// of the integration wins over the remote AS table. An eligible node claims
+// its address, unless another node already did. The decision is logged with
+// the logger of the node event.
+// Returns whether the node should be a neighbor, the neighbor and the reason
+// of the decision.
+func desiredNeighbor(
+	logger *log.Logger,
+	nodes *nodeState,
+	node *v1.Node,
+	checks eligibilityChecks,
+	peers peerSource,
+) (bool, Neighbor, string) {
+	eligible, address, reason := nodeEligible(logger, nodes, node, checks)
+	neighbor := newNeighbor(node, address)
+	neighbor.RemoteAS = nodes.remoteAS.lookup(node.Labels)
+	if eligible && peers != nil {
+		peered, remoteAS, peerReason := peers.peer(node)
+		if peered {
+			if remoteAS != 0 {
+				neighbor.RemoteAS = remoteAS
+			}
+			reason = peerReason
+		} else {
+			eligible, reason = false, "integration: "+peerReason
+		}
+	}
+	eligible, reason = nodes.claims.settle(node.Name, address, eligible, reason)
+	return eligible, neighbor, reason
+}
+
+// crdWatcher caches custom resources of a speaker with dynamic informers
+// and notifies about their changes after the initial load.
+type crdWatcher struct {
+	logger    *log.Logger
+	client    dynamic.Interface
+	factory   dynamicinformer.DynamicSharedInformerFactory
+	resources []schema.GroupVersionResource
+	// optional resources are skipped if the cluster doesn't serve them
+	optional map[schema.GroupVersionResource]bool
+	listers  map[schema.GroupVersionResource]cache.GenericLister
+	changes  chan struct{}
+	// generation counts the changes of the resources, so the integrations
+	// can cache what they derive from them until the next change
+	generation atomic.Uint64
+}
+
+// newCRDWatcher creates a watcher of the resources.
+func newCRDWatcher(
+	logger *log.Logger,
+	client dynamic.Interface,
+	resources ...schema.GroupVersionResource,
+) *crdWatcher {
+	return &crdWatcher{
+		logger:    logger,
+		client:    client,
+		factory:   dynamicinformer.NewDynamicSharedInformerFactory(client, 10*time.Minute),
+		resources: resources,
+		optional:  map[schema.GroupVersionResource]bool{},
+		listers:   map[schema.GroupVersionResource]cache.GenericLister{},
+		changes:   make(chan struct{}, 1),
+	}
+}
+
+// withOptional adds resources that may not be served by the cluster, e.g.
+// alternative API versions of the speaker configuration.
+func (w *crdWatcher) withOptional(resources ...schema.GroupVersionResource) *crdWatcher {
+	for _, resource := range resources {
+		w.resources = append(w.resources, resource)
+		w.optional[resource] = true
+	}
+	return w
+}
+
+// start starts the informers and waits for the caches to sync.
+// Returns an error if the caches don't sync.
+func (w *crdWatcher) start(ctx context.Context) error {
+	var synced []cache.InformerSynced
+	for _, resource := range w.resources {
+		if w.optional[resource] {
+			_, err := w.client.Resource(resource).List(ctx, metav1.ListOptions{Limit: 1})
+			if apierrors.IsNotFound(err) {
+				w.logger.Info("Resource is not served, skipping", "resource", resource.String())
+				continue
+			}
+		}
+		informer := w.factory.ForResource(resource)
+		w.listers[resource] = informer.Lister()
+		synced = append(synced, informer.Informer().HasSynced)
+		// The returned registration is only needed to remove the handler
+		_, _ = informer.Informer().AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+			AddFunc: func(_ interface{}, isInInitialList bool) {
+				if !isInInitialList {
+					w.notify()
+				}
+			},
+			UpdateFunc: func(_, _ interface{}) { w.notify() },
+			DeleteFunc: func(_ interface{}) { w.notify() },
+		})
+	}
+	w.factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return fmt.Errorf("timed out waiting for BGP integration caches to sync")
+	}
+	w.generation.Add(1)
+	return nil
+}
+
+// changed is notified when a watched resource changes.
+func (w *crdWatcher) changed() <-chan struct{} {
+	return w.changes
+}
+
+// notify signals a change without blocking; pending signals are merged.
+func (w *crdWatcher) notify() {
+	w.generation.Add(1)
+	select {
+	case w.changes <- struct{}{}:
+	default:
+	}
+}
+
+// list converts the cached resources into the typed objects. Resources
+// that aren't served have no objects.
+// Returns an error if a resource can't be converted.
+func list[T any](w *crdWatcher, resource schema.GroupVersionResource) ([]T, error) {
+	lister, ok := w.listers[resource]
+	if !ok {
+		return nil, nil
+	}
+	objects, err := lister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", resource.Resource, err)
+	}
+	items := make([]T, 0, len(objects))
+	for _, object := range objects {
+		u, ok := object.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		var item T
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &item); err != nil {
+			return nil, fmt.Errorf("converting %s %s: %w", resource.Resource, u.GetName(), err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,227 @@
+package manager
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	planAdd    = "add"
+	planRemove = "remove"
+)
+
+// planChange is a neighbor change of a reconcile plan.
+type planChange struct {
+	Action   string   `json:"action"`
+	IP       string   `json:"ip"`
+	Node     string   `json:"node,omitempty"`
+	Reason   string   `json:"reason"`
+	RemoteAS int      `json:"remoteAS,omitempty"`
+	LocalAS  int      `json:"localAS,omitempty"`
+	Devices  []string `json:"devices"`
+
+	neighbor Neighbor
+}
+
+// reconcilePlan is the machine-readable plan of a reconcile cycle, so
+// external change-management tooling can consume and approve it.
+type reconcilePlan struct {
+	CorrelationID string       `json:"correlationID"`
+	Time          time.Time    `json:"time"`
+	Adds          []planChange `json:"adds"`
+	Removes       []planChange `json:"removes"`
+	// Refused are the removals refused by the mass-removal guard
+	Refused []planChange `json:"refused,omitempty"`
+	// Staged are the removals waiting for approval with ApprovalID
+	Staged     []planChange `json:"staged,omitempty"`
+	ApprovalID string       `json:"approvalID,omitempty"`
+
+	adds, removes map[string]*planChange
+	// deviceAS is the local AS of every planned device
+	deviceAS map[string]int
+}
+
+// newReconcilePlan creates an empty plan of the reconcile cycle.
+func newReconcilePlan(correlationID string) *reconcilePlan {
+	return &reconcilePlan{
+		CorrelationID: correlationID,
+		Time:          time.Now(),
+		adds:          map[string]*planChange{},
+		removes:       map[string]*planChange{},
+		deviceAS:      map[string]int{},
+	}
+}
+
+// add plans adding the neighbor missing on the device.
+func (p *reconcilePlan) add(a10 *A10, neighbor Neighbor) {
+	reason := "eligible node is missing on the device"
+	if neighbor.NodeName == "" {
+		reason = "static or override neighbor is missing on the device"
+	}
+	p.addChange(a10, neighbor, reason)
+}
+
+// replace plans re-creating the neighbor configured with another remote AS
+// on the device.
+func (p *reconcilePlan) replace(a10 *A10, neighbor Neighbor, remoteAS int) {
+	p.addChange(a10, neighbor, fmt.Sprintf("neighbor has remote AS %d on the device", remoteAS))
+}
+
+// addChange plans adding the neighbor to the device for the reason.
+func (p *reconcilePlan) addChange(a10 *A10, neighbor Neighbor, reason string) {
+	device := a10.address
+	p.deviceAS[device] = a10.as
+	change, ok := p.adds[neighbor.IP]
+	if !ok {
+		change = &planChange{
+			Action:   planAdd,
+			IP:       neighbor.IP,
+			Node:     neighbor.NodeName,
+			Reason:   reason,
+			RemoteAS: a10.neighborRemoteAS(neighbor),
+			LocalAS:  a10.neighborLocalAS(neighbor),
+			neighbor: neighbor,
+		}
+		p.adds[neighbor.IP] = change
+	}
+	change.Devices = append(change.Devices, device)
+}
+
+// remove plans removing the device neighbor that is not in k8s.
+func (p *reconcilePlan) remove(a10 *A10, neighborIP, nodeName string) {
+	device := a10.address
+	p.deviceAS[device] = a10.as
+	change, ok := p.removes[neighborIP]
+	if !ok {
+		change = &planChange{
+			Action: planRemove,
+			IP:     neighborIP,
+			Node:   nodeName,
+			Reason: "not an eligible node, static or override neighbor",
+		}
+		p.removes[neighborIP] = change
+	}
+	change.Devices = append(change.Devices, device)
+}
+
+// deselect plans removing the node neighbor from the device whose node
+// selector doesn't match the node anymore.
+func (p *reconcilePlan) deselect(a10 *A10, neighbor Neighbor) {
+	device := a10.address
+	p.deviceAS[device] = a10.as
+	change, ok := p.removes[neighbor.IP]
+	if !ok {
+		change = &planChange{
+			Action:   planRemove,
+			IP:       neighbor.IP,
+			Node:     neighbor.NodeName,
+			Reason:   "node not selected by the device",
+			neighbor: neighbor,
+		}
+		p.removes[neighbor.IP] = change
+	}
+	change.Devices = append(change.Devices, device)
+}
+
+// refuseRemovals moves the planned removals to the refused ones.
+func (p *reconcilePlan) refuseRemovals() {
+	for _, change := range p.removes {
+		change.Reason = "refused by the mass-removal guard: " + change.Reason
+		p.Refused = append(p.Refused, *change)
+	}
+	p.removes = map[string]*planChange{}
+}
+
+// stageRemovals moves the planned removals to the staged ones, waiting
+// for approval.
+func (p *reconcilePlan) stageRemovals() {
+	for _, change := range p.removes {
+		change.Reason = "waiting for approval: " + change.Reason
+		p.Staged = append(p.Staged, *change)
+	}
+	p.ApprovalID = p.CorrelationID
+	p.removes = map[string]*planChange{}
+}
+
+// finish sorts the planned changes by IP.
+func (p *reconcilePlan) finish() {
+	p.Adds = sortedChanges(p.adds)
+	p.Removes = sortedChanges(p.removes)
+	slices.SortFunc(p.Refused, compareChanges)
+	slices.SortFunc(p.Staged, compareChanges)
+}
+
+// compareChanges orders the changes by IP.
+func compareChanges(a, b planChange) int {
+	return cmp.Compare(a.IP, b.IP)
+}
+
+// sortedChanges returns the changes sorted by IP.
+func sortedChanges(changes map[string]*planChange) []planChange {
+	sorted := make([]planChange, 0, len(changes))
+	for change := range maps.Values(changes) {
+		sorted = append(sorted, *change)
+	}
+	slices.SortFunc(sorted, compareChanges)
+	return sorted
+}
+
+// addIPs returns the IPs of the planned adds.
+func (p *reconcilePlan) addIPs() []string {
+	return changeIPs(p.Adds)
+}
+
+// removeIPs returns the IPs of the planned removals.
+func (p *reconcilePlan) removeIPs() []string {
+	return changeIPs(p.Removes)
+}
+
+// changeIPs returns the IPs of the changes.
+func changeIPs(changes []planChange) []string {
+	ips := make([]string, 0, len(changes))
+	for _, change := range changes {
+		ips = append(ips, change.IP)
+	}
+	return ips
+}
+
+// cli renders the planned changes as ACOS CLI commands per device, so
+// network engineers can review them in their native format. Refused
+// and staged removals are rendered as comments.
+func (p *reconcilePlan) cli() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "! reconcile %s at %s\n", p.CorrelationID, p.Time.Format(time.RFC3339))
+	for _, device := range slices.Sorted(maps.Keys(p.deviceAS)) {
+		fmt.Fprintf(&b, "! device %s\n", device)
+		fmt.Fprintf(&b, "router bgp %d\n", p.deviceAS[device])
+		for _, change := range p.Adds {
+			if slices.Contains(change.Devices, device) {
+				fmt.Fprintf(&b, " neighbor %s remote-as %d\n", change.IP, change.RemoteAS)
+				if change.LocalAS != 0 {
+					fmt.Fprintf(&b, " neighbor %s local-as %d\n", change.IP, change.LocalAS)
+				}
+			}
+		}
+		for _, change := range p.Removes {
+			if slices.Contains(change.Devices, device) {
+				fmt.Fprintf(&b, " no neighbor %s\n", change.IP)
+			}
+		}
+		for _, change := range p.Refused {
+			if slices.Contains(change.Devices, device) {
+				fmt.Fprintf(&b, " ! refused: no neighbor %s\n", change.IP)
+			}
+		}
+		for _, change := range p.Staged {
+			if slices.Contains(change.Devices, device) {
+				fmt.Fprintf(&b, " ! waiting for approval %s: no neighbor %s\n", p.ApprovalID, change.IP)
+			}
+		}
+		b.WriteString("exit\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// defaultPreflightNeighbor is the probe neighbor of the permission
+// preflight, an address from the TEST-NET-1 documentation range that is
+// never a real peer.
+const defaultPreflightNeighbor = "192.0.2.254"
+
+// preflight verifies the account can write the BGP configuration by
+// creating and deleting a probe neighbor, so a read-only account fails at
+// startup instead of on the first node event.
+// Returns an error if the account can't write the BGP configuration.
+func (a *A10) preflight(probeIP string) error {
+	logger := a.logger.With("device", a.address, "neighbor", probeIP)
+	logger.Info("Checking A10 BGP write permission")
+	if a.protected.contains(probeIP) {
+		return fmt.Errorf("probe neighbor %s is protected", probeIP)
+	}
+
+	if err := a.createProbeNeighbor(probeIP); err != nil {
+		return preflightError("creating probe neighbor", err)
+	}
+	if err := a.deleteNeighbor(a.ctx, probeIP); err != nil {
+		return preflightError(
+			fmt.Sprintf("deleting probe neighbor (remove %s from the device manually)", probeIP),
+			err,
+		)
+	}
+	logger.Debug("A10 BGP write permission confirmed")
+	return nil
+}
+
+// createProbeNeighbor creates the probe neighbor with the cluster remote AS
+// and no template attributes.
+// Returns an error if the operation fails.
+func (a *A10) createProbeNeighbor(probeIP string) error {
+	if a.backend != nil {
+		return a.backend.addNeighbor(a.ctx, probeIP, a.remoteAS)
+	}
+	url := fmt.Sprintf("%s%s", a.address, fmt.Sprintf(bgpEndpoint, a.as))
+	data, err := json.Marshal(map[string]interface{}{
+		"ipv4-neighbor": map[string]interface{}{
+			"neighbor-ipv4": probeIP,
+			"nbr-remote-as": a.remoteAS,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling request data: %w", err)
+	}
+	_, err = a.sessionRequest(a.ctx, "POST", url, data, a.retryCreate(a.ctx, probeIP))
+	return err
+}
+
+// preflightError explains a failed preflight step.
+func preflightError(step string, err error) error {
+	if errors.Is(err, ErrForbidden) {
+		return fmt.Errorf(
+			"%s: the A10 account is read-only or lacks BGP write privilege: %w",
+			step, err,
+		)
+	}
+	return fmt.Errorf("%s: %w", step, err)
+}
+
+// preflight verifies every device accepts BGP configuration changes.
+// Returns the joined errors of the devices that failed.
+func (d *Devices) preflight(probeIP string) error {
+	var errs []error
+	for _, a10 := range d.devices {
+		if err := a10.preflight(probeIP); err != nil {
+			errs = append(errs, fmt.Errorf("device %s: %w", a10.address, err))
+		}
+	}
+	return errors.Join(errs...)
+}
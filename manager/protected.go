@@ -0,0 +1,52 @@
+package manager
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// protectedNeighbors is a list of neighbor IPs and CIDRs the controller must
+// never add, modify or delete, e.g. upstream transit peers that happen to
+// share the remote AS.
+type protectedNeighbors []netip.Prefix
+
+// parseProtectedNeighbors parses a comma-separated list of IPs and CIDRs.
+// Returns an error if an entry is invalid.
+func parseProtectedNeighbors(list string) (protectedNeighbors, error) {
+	var protected protectedNeighbors
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid protected neighbor %q: %w", entry, err)
+			}
+			protected = append(protected, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid protected neighbor %q: %w", entry, err)
+		}
+		protected = append(protected, prefix.Masked())
+	}
+	return protected, nil
+}
+
+// contains checks if the neighbor is protected.
+func (p protectedNeighbors) contains(neighborIP string) bool {
+	addr, err := netip.ParseAddr(neighborIP)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range p {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const defaultQuarantineDuration = time.Hour
+
+// quarantineEntry is a quarantined neighbor.
+type quarantineEntry struct {
+	Node   string    `json:"node,omitempty"`
+	Reason string    `json:"reason"`
+	Until  time.Time `json:"until"`
+}
+
+// quarantine keeps the neighbors whose changes were rolled back or that
+// flapped from being added again until the quarantine expires, so a broken
+// node doesn't churn the devices. It is safe for concurrent use.
+type quarantine struct {
+	logger   *log.Logger
+	duration time.Duration
+
+	mu        sync.Mutex
+	neighbors map[string]quarantineEntry
+}
+
+// newQuarantine creates an empty quarantine of the duration.
+func newQuarantine(logger *log.Logger, duration time.Duration) *quarantine {
+	return &quarantine{logger: logger, duration: duration, neighbors: map[string]quarantineEntry{}}
+}
+
+// add quarantines the neighbor for the quarantine duration.
+func (q *quarantine) add(neighbor Neighbor, reason string) {
+	q.addFor(neighbor, reason, q.duration)
+}
+
+// addFor quarantines the neighbor for the duration.
+// Returns when the quarantine expires.
+func (q *quarantine) addFor(neighbor Neighbor, reason string, duration time.Duration) time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	until := time.Now().Add(duration)
+	q.neighbors[neighbor.IP] = quarantineEntry{Node: neighbor.NodeName, Reason: reason, Until: until}
+	quarantinedNeighbors.Set(float64(len(q.neighbors)))
+	q.logger.Warn(
+		"Neighbor quarantined",
+		"neighbor", neighbor.IP,
+		"node", neighbor.NodeName,
+		"reason", reason,
+		"until", until,
+	)
+	return until
+}
+
+// contains checks if the neighbor is quarantined, releasing it if the
+// quarantine expired.
+func (q *quarantine) contains(neighborIP string) bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	entry, ok := q.neighbors[neighborIP]
+	if ok && time.Now().After(entry.Until) {
+		delete(q.neighbors, neighborIP)
+		quarantinedNeighbors.Set(float64(len(q.neighbors)))
+		q.logger.Info("Neighbor quarantine expired", "neighbor", neighborIP, "node", entry.Node)
+		return false
+	}
+	return ok
+}
+
+// report returns a copy of the quarantined neighbors.
+func (q *quarantine) report() map[string]quarantineEntry {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	report := make(map[string]quarantineEntry, len(q.neighbors))
+	for neighborIP, entry := range q.neighbors {
+		if time.Now().Before(entry.Until) {
+			report[neighborIP] = entry
+		}
+	}
+	return report
+}
@@ -0,0 +1,772 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	defaultWorkers        = 4
+	maxQueueRetries       = 5
+	defaultCoalesceWindow = 2 * time.Second
+	progressInterval      = 5 * time.Second
+	// maxCoalesceWindows bounds how long a batch can be delayed by a
+	// continuous stream of events, in coalesce windows
+	maxCoalesceWindows = 10
+	// defaultJitterThreshold is the smallest batch whose changes are
+	// jittered
+	defaultJitterThreshold = 20
+	// defaultShutdownGracePeriod fits the default termination grace period
+	// of the pods
+	defaultShutdownGracePeriod = 20 * time.Second
+	// drainPollInterval is how often draining checks the changes left
+	drainPollInterval = 100 * time.Millisecond
+)
+
+// neighborOperation is the desired state of a neighbor.
+// seq identifies the operation, so a worker can tell if the desired state
+// was changed while it was applying it.
+// notBefore delays a scheduled removal.
+// correlationID traces the operation to the node event or reconcile cycle
+// that requested it.
+// deadline is the deadline of the reconcile cycle of the operation, if any.
+// tombstone marks the removal of the neighbor of a deleted node, shut down
+// until notBefore, and shutdown that it isn't shut down yet. revive marks
+// the addition of the neighbor of a node that returned meanwhile.
+type neighborOperation struct {
+	present       bool
+	neighbor      Neighbor
+	seq           uint64
+	notBefore     time.Time
+	correlationID string
+	deadline      time.Time
+	tombstone     bool
+	shutdown      bool
+	revive        bool
+}
+
+// WorkQueue processes neighbor operations with a bounded pool of workers.
+// Operations are keyed by neighbor IP: the queue never hands the same key to
+// two workers at once, and only the latest desired state of a neighbor is
+// applied, so an add can't race its own remove.
+type WorkQueue struct {
+	logger  *log.Logger
+	ctx     context.Context
+	devices *Devices
+	workers int
+	// coalesceWindow is the quiet period after which a burst of events is
+	// flushed to the workers as one batch, 0 disables coalescing
+	coalesceWindow time.Duration
+	// removalDelay delays removing the neighbors of nodes that became
+	// ineligible, 0 removes them right away
+	removalDelay time.Duration
+	// maintenance holds the changes until maintenance is lifted
+	maintenance *pauseSwitch
+	// windows defer the changes until a change window opens
+	windows *changeWindows
+	// jitter spreads the changes of the batches of at least jitterThreshold
+	// neighbors over a random delay up to it, 0 disables it
+	jitter          time.Duration
+	jitterThreshold int
+	// reconcileDeadline bounds how long the changes of a reconcile cycle
+	// are applied, 0 disables it
+	reconcileDeadline time.Duration
+
+	queue   workqueue.TypedRateLimitingInterface[string]
+	mu      sync.Mutex
+	desired map[string]neighborOperation
+	seq     uint64
+	// held holds the neighbors whose changes wait for maintenance to end
+	held map[string]struct{}
+	// deferred holds the neighbors whose changes wait for a change window
+	deferred map[string]struct{}
+	// batch holds the neighbors waiting for the quiet period to end
+	batch      map[string]struct{}
+	batchStart time.Time
+	batchTimer *time.Timer
+	// active holds the neighbors being applied by the workers
+	active map[string]struct{}
+	// trackers stop the progress tracker of a cycle when a newer cycle of
+	// the same name starts, so bursts of cycles don't pile up trackers
+	trackers map[string]chan struct{}
+	// resyncs requests re-fetching and reconciling the devices after an
+	// operation found their cached state stale
+	resyncs chan struct{}
+	// draining stops accepting changes and applying all but the due
+	// removals on shutdown
+	draining bool
+}
+
+// newWorkQueue creates a work queue applying operations to the devices.
+func newWorkQueue(
+	ctx context.Context,
+	logger *log.Logger,
+	devices *Devices,
+	workers int,
+	coalesceWindow time.Duration,
+	removalDelay time.Duration,
+) *WorkQueue {
+	return &WorkQueue{
+		logger:         logger,
+		ctx:            ctx,
+		devices:        devices,
+		workers:        workers,
+		coalesceWindow: coalesceWindow,
+		removalDelay:   removalDelay,
+		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+			workqueue.TypedRateLimitingQueueConfig[string]{Name: "neighbors"},
+		),
+		desired:  map[string]neighborOperation{},
+		held:     map[string]struct{}{},
+		deferred: map[string]struct{}{},
+		batch:    map[string]struct{}{},
+		active:   map[string]struct{}{},
+		trackers: map[string]chan struct{}{},
+		resyncs:  make(chan struct{}, 1),
+	}
+}
+
+// AddNeighbor queues adding the neighbor to the devices.
+func (q *WorkQueue) AddNeighbor(neighbor Neighbor, correlationID string) {
+	q.enqueue(neighbor.IP, neighborOperation{
+		present:       true,
+		neighbor:      neighbor,
+		correlationID: correlationID,
+	})
+}
+
+// RemoveNeighbor queues removing the neighbor from the devices.
+func (q *WorkQueue) RemoveNeighbor(neighborIP string, nodeName string, correlationID string) {
+	q.enqueue(neighborIP, neighborOperation{
+		present:       false,
+		neighbor:      Neighbor{IP: neighborIP, NodeName: nodeName},
+		correlationID: correlationID,
+	})
+}
+
+// cycleDeadline returns the deadline of a reconcile cycle starting now, or
+// the zero time if it's disabled.
+func (q *WorkQueue) cycleDeadline() time.Time {
+	if q.reconcileDeadline == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(q.reconcileDeadline)
+}
+
+// ReconcileNeighbor queues the desired state of the neighbor for the
+// reconcile cycle with the deadline.
+func (q *WorkQueue) ReconcileNeighbor(
+	neighbor Neighbor,
+	present bool,
+	correlationID string,
+	deadline time.Time,
+) {
+	q.enqueue(neighbor.IP, neighborOperation{
+		present:       present,
+		neighbor:      neighbor,
+		correlationID: correlationID,
+		deadline:      deadline,
+	})
+}
+
+// ScheduleRemoveNeighbor queues removing the neighbor from the devices
+// after the removal delay. Adding the neighbor before the delay elapses
+// cancels the removal, so brief maintenance blips don't churn the sessions.
+func (q *WorkQueue) ScheduleRemoveNeighbor(neighborIP string, nodeName string, correlationID string) {
+	if q.removalDelay == 0 {
+		q.RemoveNeighbor(neighborIP, nodeName, correlationID)
+		return
+	}
+	if neighborIP == "" {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.draining {
+		q.logger.Debug("Ignoring neighbor removal while draining", "neighbor", neighborIP)
+		return
+	}
+	// Keep the schedule of a pending removal, node updates must not
+	// postpone it
+	if current, ok := q.desired[neighborIP]; ok && !current.present && !current.notBefore.IsZero() {
+		return
+	}
+	q.seq++
+	q.desired[neighborIP] = neighborOperation{
+		present:       false,
+		neighbor:      Neighbor{IP: neighborIP, NodeName: nodeName},
+		seq:           q.seq,
+		notBefore:     time.Now().Add(q.removalDelay),
+		correlationID: correlationID,
+	}
+	q.queue.AddAfter(neighborIP, q.removalDelay)
+	q.logger.Info(
+		"Scheduled neighbor removal",
+		"neighbor", neighborIP,
+		"node", nodeName,
+		"delay", q.removalDelay,
+		"correlationID", correlationID,
+	)
+}
+
+// TombstoneNeighbor queues shutting the neighbor of the deleted node down
+// and removing it when its tombstone expires. Adding the neighbor before
+// then brings it back up. Without tombstones, the neighbor is removed right
+// away.
+func (q *WorkQueue) TombstoneNeighbor(neighborIP string, nodeName string, correlationID string) {
+	tombstones := q.devices.tombstones
+	if tombstones == nil {
+		q.RemoveNeighbor(neighborIP, nodeName, correlationID)
+		return
+	}
+	if neighborIP == "" {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.draining {
+		q.logger.Debug("Ignoring neighbor removal while draining", "neighbor", neighborIP)
+		return
+	}
+	expires := tombstones.add(neighborIP, nodeName)
+	q.seq++
+	q.desired[neighborIP] = neighborOperation{
+		present:       false,
+		neighbor:      Neighbor{IP: neighborIP, NodeName: nodeName},
+		seq:           q.seq,
+		notBefore:     expires,
+		correlationID: correlationID,
+		tombstone:     true,
+		shutdown:      true,
+	}
+	q.queue.Add(neighborIP)
+	q.logger.Info(
+		"Tombstoned neighbor of deleted node, shutting it down until it expires",
+		"neighbor", neighborIP,
+		"node", nodeName,
+		"expires", expires,
+		"correlationID", correlationID,
+	)
+}
+
+// enqueue records the desired state of the neighbor and queues it.
+func (q *WorkQueue) enqueue(neighborIP string, op neighborOperation) {
+	if neighborIP == "" {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.draining {
+		q.logger.Debug("Ignoring neighbor change while draining", "neighbor", neighborIP, "present", op.present)
+		return
+	}
+	if current, ok := q.desired[neighborIP]; ok && op.present && !current.tombstone &&
+		!current.present && time.Now().Before(current.notBefore) {
+		q.logger.Info(
+			"Cancelled scheduled neighbor removal",
+			"neighbor", neighborIP,
+			"node", op.neighbor.NodeName,
+			"correlationID", op.correlationID,
+		)
+	}
+	if current, ok := q.desired[neighborIP]; ok && current.tombstone {
+		q.devices.tombstones.remove(neighborIP)
+		if op.present {
+			op.revive = true
+			q.logger.Info(
+				"Node returned before the tombstone expired, bringing the neighbor back up",
+				"neighbor", neighborIP,
+				"node", op.neighbor.NodeName,
+				"correlationID", op.correlationID,
+			)
+		}
+	}
+	q.seq++
+	op.seq = q.seq
+	q.desired[neighborIP] = op
+	if q.coalesceWindow == 0 {
+		q.queue.Add(neighborIP)
+		return
+	}
+
+	// Coalesce the event into the current batch and restart the quiet
+	// period, unless the batch has already been delayed for too long
+	if len(q.batch) == 0 {
+		q.batchStart = time.Now()
+	}
+	q.batch[neighborIP] = struct{}{}
+	delay := q.coalesceWindow
+	if deadline := q.batchStart.Add(maxCoalesceWindows * q.coalesceWindow); time.Until(deadline) < delay {
+		delay = max(time.Until(deadline), 0)
+	}
+	if q.batchTimer == nil {
+		q.batchTimer = time.AfterFunc(delay, q.flush)
+	} else {
+		q.batchTimer.Reset(delay)
+	}
+}
+
+// flush hands the coalesced batch to the workers.
+// It makes sure every device has a session so the batch operations share it.
+func (q *WorkQueue) flush() {
+	q.mu.Lock()
+	batch := q.batch
+	q.batch = map[string]struct{}{}
+	q.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	q.logger.Info("Processing coalesced batch of neighbor changes", "neighbors", len(batch))
+	q.devices.beginBatch(batch)
+	q.addBatch(batch)
+}
+
+// addBatch hands the batch to the workers. The changes of a large batch,
+// e.g. on cluster start or partition recovery, are jittered to smooth the
+// load on the device management plane.
+func (q *WorkQueue) addBatch(batch map[string]struct{}) {
+	if q.jitter == 0 || len(batch) < q.jitterThreshold {
+		for neighborIP := range batch {
+			q.queue.Add(neighborIP)
+		}
+		return
+	}
+	q.logger.Info("Jittering large batch of neighbor changes", "neighbors", len(batch), "jitter", q.jitter)
+	for neighborIP := range batch {
+		q.queue.AddAfter(neighborIP, rand.N(q.jitter))
+	}
+}
+
+// pending counts the neighbors that still have changes to apply.
+func (q *WorkQueue) pending(neighbors []string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	count := 0
+	for _, neighborIP := range neighbors {
+		if _, ok := q.desired[neighborIP]; ok {
+			count++
+		}
+	}
+	return count
+}
+
+// depth counts the neighbors that have changes to apply, including the
+// scheduled removals and the changes held for maintenance.
+func (q *WorkQueue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.desired)
+}
+
+// trackProgress logs the progress of applying the changes of the neighbors
+// until all of them are processed, a newer cycle of the same name starts or
+// the context is done.
+func (q *WorkQueue) trackProgress(name string, neighbors []string) {
+	total := len(neighbors)
+	start := time.Now()
+	q.logger.Info("Started "+name, "neighbors", total)
+
+	stop := make(chan struct{})
+	q.mu.Lock()
+	if superseded, ok := q.trackers[name]; ok {
+		close(superseded)
+	}
+	q.trackers[name] = stop
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		if q.trackers[name] == stop {
+			delete(q.trackers, name)
+		}
+		q.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		pending := q.pending(neighbors)
+		if pending == 0 {
+			q.logger.Info("Finished "+name, "neighbors", total, "duration", time.Since(start))
+			return
+		}
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-stop:
+			q.logger.Debug("Superseded "+name, "done", total-pending, "total", total)
+			return
+		case <-ticker.C:
+			q.logger.Info(
+				"Progress of "+name,
+				"done", total-pending,
+				"total", total,
+				"elapsed", time.Since(start),
+			)
+		}
+	}
+}
+
+// hold keeps the change of the neighbor until maintenance is lifted.
+// The devices report it as pending meanwhile.
+func (q *WorkQueue) hold(neighborIP string, op neighborOperation) {
+	q.mu.Lock()
+	q.held[neighborIP] = struct{}{}
+	held := len(q.held)
+	q.mu.Unlock()
+	heldChanges.Set(float64(held))
+	for _, a10 := range q.devices.devices {
+		q.devices.status.setPending(a10.address, neighborIP, op.neighbor.NodeName, op.present)
+	}
+	q.logger.Info(
+		"Holding neighbor change until maintenance is lifted",
+		"neighbor", neighborIP,
+		"node", op.neighbor.NodeName,
+		"present", op.present,
+		"correlationID", op.correlationID,
+	)
+	q.queue.Forget(neighborIP)
+	// maintenance may have been lifted while holding
+	if !q.maintenance.inMaintenance() {
+		q.release()
+	}
+}
+
+// release queues the changes held during maintenance.
+func (q *WorkQueue) release() {
+	q.mu.Lock()
+	held := q.held
+	q.held = map[string]struct{}{}
+	q.mu.Unlock()
+	heldChanges.Set(0)
+	if len(held) == 0 {
+		return
+	}
+	q.logger.Info("Applying neighbor changes held during maintenance", "neighbors", len(held))
+	q.devices.beginBatch(held)
+	q.addBatch(held)
+}
+
+// deferChange requeues the change of the neighbor when the next change
+// window opens. The devices report it as pending meanwhile.
+func (q *WorkQueue) deferChange(neighborIP string, op neighborOperation) {
+	wait := q.windows.untilOpen()
+	q.mu.Lock()
+	_, deferred := q.deferred[neighborIP]
+	q.deferred[neighborIP] = struct{}{}
+	count := len(q.deferred)
+	q.mu.Unlock()
+	deferredChanges.Set(float64(count))
+	if !deferred {
+		for _, a10 := range q.devices.devices {
+			q.devices.status.setPending(a10.address, neighborIP, op.neighbor.NodeName, op.present)
+		}
+		q.logger.Info(
+			"Deferring neighbor change until the next change window",
+			"neighbor", neighborIP,
+			"node", op.neighbor.NodeName,
+			"present", op.present,
+			"opensIn", wait.Round(time.Second),
+			"correlationID", op.correlationID,
+		)
+	}
+	q.queue.Forget(neighborIP)
+	q.queue.AddAfter(neighborIP, wait)
+}
+
+// undefer forgets the deferral of the neighbor change being applied.
+func (q *WorkQueue) undefer(neighborIP string) {
+	q.mu.Lock()
+	delete(q.deferred, neighborIP)
+	count := len(q.deferred)
+	q.mu.Unlock()
+	deferredChanges.Set(float64(count))
+}
+
+// carry requeues the change that missed the deadline of its reconcile
+// cycle with the deadline of a new cycle. Its retries are kept, so carrying
+// neither resets nor counts against the backoff.
+func (q *WorkQueue) carry(neighborIP string, op neighborOperation) {
+	q.mu.Lock()
+	if current, ok := q.desired[neighborIP]; ok && current.seq == op.seq {
+		current.deadline = q.cycleDeadline()
+		q.desired[neighborIP] = current
+	}
+	q.mu.Unlock()
+	carriedChanges.Inc()
+	q.logger.Warn(
+		"Carrying neighbor change into the next reconcile cycle",
+		"neighbor", neighborIP,
+		"node", op.neighbor.NodeName,
+		"present", op.present,
+		"correlationID", op.correlationID,
+	)
+	q.queue.Add(neighborIP)
+}
+
+// requestResync requests reconciling the devices. Requests made while one
+// is pending are merged.
+func (q *WorkQueue) requestResync() {
+	select {
+	case q.resyncs <- struct{}{}:
+	default:
+	}
+}
+
+// due tells if the operation must be applied before shutting down: a
+// removal that isn't scheduled for later. Leaving the neighbor of a deleted
+// node on the devices keeps a dead session, while the skipped adds and
+// scheduled removals are reconciled on the next start.
+func (op neighborOperation) due(now time.Time) bool {
+	return !op.present && !now.Before(op.notBefore)
+}
+
+// drain stops accepting changes and waits up to the grace period for the
+// in-flight changes and the due removals, including the coalesced ones, to
+// be applied. The changes held for maintenance or deferred to a change
+// window are left.
+func (q *WorkQueue) drain(grace time.Duration) {
+	q.mu.Lock()
+	q.draining = true
+	if q.batchTimer != nil {
+		q.batchTimer.Stop()
+	}
+	q.mu.Unlock()
+	if grace == 0 {
+		return
+	}
+	q.flush()
+
+	left := q.drainPending()
+	if left == 0 {
+		return
+	}
+	q.logger.Info("Draining neighbor changes", "changes", left, "gracePeriod", grace)
+	deadline := time.Now().Add(grace)
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		left = q.drainPending()
+		if left == 0 {
+			q.logger.Info("Drained neighbor changes")
+			return
+		}
+		if time.Now().After(deadline) {
+			q.logger.Warn("Abandoning neighbor changes after the grace period", "changes", left)
+			return
+		}
+	}
+}
+
+// drainPending counts the neighbors being applied or with a due removal
+// that isn't held or deferred.
+func (q *WorkQueue) drainPending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := time.Now()
+	count := len(q.active)
+	for neighborIP, op := range q.desired {
+		_, active := q.active[neighborIP]
+		_, held := q.held[neighborIP]
+		_, deferred := q.deferred[neighborIP]
+		if op.due(now) && !active && !held && !deferred {
+			count++
+		}
+	}
+	return count
+}
+
+// Start starts the workers in the background.
+// The queue is shut down when the context is done.
+func (q *WorkQueue) Start() {
+	q.logger.Info("Starting workers", "workers", q.workers)
+	for i := 0; i < q.workers; i++ {
+		go func() {
+			for q.processNext() {
+			}
+		}()
+	}
+	go func() {
+		<-q.ctx.Done()
+		q.queue.ShutDown()
+	}()
+}
+
+// processNext applies the desired state of the next queued neighbor.
+// Failed operations are handled by the class of the error: retryable and
+// reauth ones are retried with backoff up to maxQueueRetries times, resync
+// ones request reconciling the devices and fatal ones are given up.
+// Operations that miss the deadline of their reconcile cycle are carried
+// into the next one.
+// Unless retried, the change is done for the batch checkpoint.
+// Returns false when the queue is shut down.
+func (q *WorkQueue) processNext() bool {
+	neighborIP, shutdown := q.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer q.queue.Done(neighborIP)
+	retrying := false
+	var failed error
+	defer func() {
+		if !retrying {
+			q.devices.checkpoints.finish(q.ctx, neighborIP, failed)
+		}
+	}()
+
+	q.mu.Lock()
+	op, ok := q.desired[neighborIP]
+	draining := q.draining
+	q.active[neighborIP] = struct{}{}
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.active, neighborIP)
+		q.mu.Unlock()
+	}()
+	if draining && !(ok && op.due(time.Now())) {
+		q.queue.Forget(neighborIP)
+		return true
+	}
+	if !ok {
+		q.undefer(neighborIP)
+		q.queue.Forget(neighborIP)
+		return true
+	}
+	if wait := time.Until(op.notBefore); !op.present && wait > 0 {
+		if op.shutdown {
+			q.shutdownTombstoned(neighborIP, op)
+		}
+		q.queue.AddAfter(neighborIP, wait)
+		return true
+	}
+	if q.maintenance.inMaintenance() {
+		q.hold(neighborIP, op)
+		return true
+	}
+	if !q.windows.allows(op.present) {
+		q.deferChange(neighborIP, op)
+		return true
+	}
+	q.undefer(neighborIP)
+
+	logger := q.logger.With(
+		"neighbor", neighborIP,
+		"node", op.neighbor.NodeName,
+		"correlationID", op.correlationID,
+	)
+
+	ctx := withCorrelationID(q.ctx, op.correlationID)
+	if !op.deadline.IsZero() {
+		if !time.Now().Before(op.deadline) {
+			retrying = true
+			q.carry(neighborIP, op)
+			return true
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, op.deadline)
+		defer cancel()
+	}
+	q.devices.checkFlapping(ctx, op.neighbor, op.present)
+	var err error
+	if op.present && op.revive {
+		err = q.devices.ReviveNeighbor(ctx, op.neighbor)
+	} else if op.present {
+		err = q.devices.AddNeighbor(ctx, op.neighbor)
+	} else {
+		err = q.devices.RemoveNeighbor(ctx, neighborIP, op.neighbor.NodeName)
+	}
+	paused := errors.Is(err, errPaused)
+	if err != nil && !paused && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logger.Error("Error syncing neighbor before the reconcile deadline", "error", err)
+		retrying = true
+		q.carry(neighborIP, op)
+		return true
+	}
+	if paused {
+		// the drift is applied when the writes resume
+		logger.Info("A10 writes paused, dropping neighbor change", "present", op.present)
+	} else if err != nil {
+		class := classifyError(err)
+		syncErrors.WithLabelValues(class.String()).Inc()
+		switch {
+		case class == errorResync:
+			logger.Error("Error syncing neighbor, reconciling the devices", "error", err, "class", class)
+			failed = err
+			q.requestResync()
+		case class == errorFatal:
+			logger.Error("Error syncing neighbor, not retrying", "error", err, "class", class)
+			failed = err
+		case q.queue.NumRequeues(neighborIP) < maxQueueRetries:
+			if class == errorReauth {
+				q.devices.invalidateSessions()
+			}
+			logger.Error("Error syncing neighbor, retrying", "error", err, "class", class)
+			retrying = true
+			q.queue.AddRateLimited(neighborIP)
+			return true
+		default:
+			logger.Error("Error syncing neighbor, giving up", "error", err, "class", class)
+			failed = err
+		}
+		q.devices.recorder.record(
+			ctx, op.neighbor.NodeName, v1.EventTypeWarning, eventReasonSyncFailed,
+			"Failed to sync BGP neighbor %s: %v", neighborIP, err,
+		)
+	} else {
+		logger.Info("Neighbor change applied", "present", op.present)
+		if op.present {
+			q.devices.recorder.record(
+				ctx, op.neighbor.NodeName, v1.EventTypeNormal, eventReasonNeighborAdded,
+				"BGP neighbor %s configured on the A10 devices", neighborIP,
+			)
+		} else {
+			q.devices.recorder.record(
+				ctx, op.neighbor.NodeName, v1.EventTypeNormal, eventReasonNeighborRemoved,
+				"BGP neighbor %s removed from the A10 devices", neighborIP,
+			)
+		}
+	}
+
+	// Forget the desired state unless it was changed while we were working
+	q.mu.Lock()
+	if q.desired[neighborIP].seq == op.seq {
+		delete(q.desired, neighborIP)
+		if op.tombstone {
+			q.devices.tombstones.remove(neighborIP)
+		}
+	}
+	q.mu.Unlock()
+	q.queue.Forget(neighborIP)
+	return true
+}
+
+// shutdownTombstoned shuts the tombstoned neighbor down until its removal.
+// The shutdown is attempted once: if it fails, the neighbor stays up and is
+// still removed when the tombstone expires.
+func (q *WorkQueue) shutdownTombstoned(neighborIP string, op neighborOperation) {
+	ctx := withCorrelationID(q.ctx, op.correlationID)
+	if err := q.devices.ShutdownNeighbor(ctx, neighborIP, true); err != nil {
+		q.logger.Error(
+			"Error shutting tombstoned neighbor down",
+			"neighbor", neighborIP,
+			"node", op.neighbor.NodeName,
+			"error", err,
+			"correlationID", op.correlationID,
+		)
+	}
+	q.mu.Lock()
+	if current, ok := q.desired[neighborIP]; ok && current.seq == op.seq {
+		current.shutdown = false
+		q.desired[neighborIP] = current
+	}
+	q.mu.Unlock()
+}
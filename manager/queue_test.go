@@ -0,0 +1,264 @@
+package manager_test
+
+import (
+	"context"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/rgeraskin/a10-bgp-neighbor-manager/manager"
+	"github.com/rgeraskin/a10-bgp-neighbor-manager/manager/fake"
+)
+
+const (
+	testRemovalDelay = 50 * time.Millisecond
+	// testTimeout bounds how long the queue may take to apply the changes
+	testTimeout = 5 * time.Second
+)
+
+var (
+	node1 = manager.Neighbor{IP: "10.0.0.1", NodeName: "node-1"}
+	node2 = manager.Neighbor{IP: "10.0.0.2", NodeName: "node-2"}
+)
+
+// devicesWith creates the fake devices and the manager devices backed by
+// them, with the neighbors configured on every device.
+func devicesWith(t *testing.T, count int, neighbors ...string) ([]*fake.Device, *manager.Devices) {
+	t.Helper()
+	var backendNeighbors []manager.BackendNeighbor
+	for _, ip := range neighbors {
+		backendNeighbors = append(backendNeighbors, manager.BackendNeighbor{IP: ip, RemoteAS: manager.TestRemoteAS})
+	}
+	var fakes []*fake.Device
+	var backends []manager.Backend
+	for range count {
+		device := fake.NewDevice(backendNeighbors...)
+		fakes = append(fakes, device)
+		backends = append(backends, device)
+	}
+	devices, err := manager.NewTestDevices(context.Background(), backends...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, device := range fakes {
+		device.Reset()
+	}
+	return fakes, devices
+}
+
+// waitApplied waits for the queue to apply all the changes.
+func waitApplied(t *testing.T, queue *manager.WorkQueue) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for queue.Depth() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d changes not applied after %s", queue.Depth(), testTimeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// countCalls counts the operations of the kind made on the device.
+func countCalls(device *fake.Device, op fake.Op) int {
+	count := 0
+	for _, call := range device.Calls() {
+		if call.Op == op {
+			count++
+		}
+	}
+	return count
+}
+
+func TestWorkQueue(t *testing.T) {
+	tests := []struct {
+		name string
+		// neighbors are configured on the devices beforehand
+		neighbors []string
+		// failures fail the next operations of the first device
+		failures map[fake.Op][]error
+		// changes are queued before starting the workers
+		changes func(queue *manager.WorkQueue)
+		// settle is waited for after the changes are applied, e.g. for
+		// the scheduled removals
+		settle time.Duration
+		// want are the neighbors of the first and the other devices
+		want, wantOthers []string
+		// adds and removes are the operations made on the first device
+		adds, removes int
+	}{
+		{
+			name: "add",
+			changes: func(queue *manager.WorkQueue) {
+				queue.AddNeighbor(node1, "id")
+			},
+			want:       []string{node1.IP},
+			wantOthers: []string{node1.IP},
+			adds:       1,
+		},
+		{
+			name:      "add existing",
+			neighbors: []string{node1.IP},
+			changes: func(queue *manager.WorkQueue) {
+				queue.AddNeighbor(node1, "id")
+			},
+			want:       []string{node1.IP},
+			wantOthers: []string{node1.IP},
+		},
+		{
+			name:      "remove",
+			neighbors: []string{node1.IP, node2.IP},
+			changes: func(queue *manager.WorkQueue) {
+				queue.RemoveNeighbor(node1.IP, node1.NodeName, "id")
+			},
+			want:       []string{node2.IP},
+			wantOthers: []string{node2.IP},
+			removes:    1,
+		},
+		{
+			name: "latest state wins",
+			changes: func(queue *manager.WorkQueue) {
+				queue.AddNeighbor(node1, "id-1")
+				queue.AddNeighbor(node2, "id-1")
+				queue.RemoveNeighbor(node1.IP, node1.NodeName, "id-2")
+			},
+			want:       []string{node2.IP},
+			wantOthers: []string{node2.IP},
+			adds:       1,
+		},
+		{
+			name:     "busy device is retried",
+			failures: map[fake.Op][]error{fake.OpAddNeighbor: {manager.ErrDeviceBusy, manager.ErrDeviceBusy}},
+			changes: func(queue *manager.WorkQueue) {
+				queue.AddNeighbor(node1, "id")
+			},
+			want:       []string{node1.IP},
+			wantOthers: []string{node1.IP},
+			adds:       3,
+		},
+		{
+			name:     "rejected session is retried",
+			failures: map[fake.Op][]error{fake.OpAddNeighbor: {manager.ErrUnauthorized}},
+			changes: func(queue *manager.WorkQueue) {
+				queue.AddNeighbor(node1, "id")
+			},
+			want:       []string{node1.IP},
+			wantOthers: []string{node1.IP},
+			adds:       2,
+		},
+		{
+			name: "retries give up",
+			failures: map[fake.Op][]error{fake.OpAddNeighbor: {
+				manager.ErrDeviceBusy, manager.ErrDeviceBusy, manager.ErrDeviceBusy,
+				manager.ErrDeviceBusy, manager.ErrDeviceBusy, manager.ErrDeviceBusy,
+			}},
+			changes: func(queue *manager.WorkQueue) {
+				queue.AddNeighbor(node1, "id")
+			},
+			wantOthers: []string{node1.IP},
+			adds:       6,
+		},
+		{
+			name:     "fatal error is not retried",
+			failures: map[fake.Op][]error{fake.OpAddNeighbor: {manager.ErrForbidden}},
+			changes: func(queue *manager.WorkQueue) {
+				queue.AddNeighbor(node1, "id")
+			},
+			wantOthers: []string{node1.IP},
+			adds:       1,
+		},
+		{
+			name:      "scheduled removal",
+			neighbors: []string{node1.IP},
+			changes: func(queue *manager.WorkQueue) {
+				queue.ScheduleRemoveNeighbor(node1.IP, node1.NodeName, "id")
+			},
+			removes: 1,
+		},
+		{
+			name:      "scheduled removal cancelled by an add",
+			neighbors: []string{node1.IP},
+			changes: func(queue *manager.WorkQueue) {
+				queue.ScheduleRemoveNeighbor(node1.IP, node1.NodeName, "id-1")
+				queue.AddNeighbor(node1, "id-2")
+			},
+			settle:     2 * testRemovalDelay,
+			want:       []string{node1.IP},
+			wantOthers: []string{node1.IP},
+		},
+		{
+			name:      "removal scheduled twice",
+			neighbors: []string{node1.IP},
+			changes: func(queue *manager.WorkQueue) {
+				queue.ScheduleRemoveNeighbor(node1.IP, node1.NodeName, "id-1")
+				queue.ScheduleRemoveNeighbor(node1.IP, node1.NodeName, "id-2")
+			},
+			removes: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakes, devices := devicesWith(t, 2, tt.neighbors...)
+			for op, errs := range tt.failures {
+				fakes[0].FailNext(op, errs...)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			queue := manager.NewTestQueue(ctx, devices, testRemovalDelay)
+			tt.changes(queue)
+			queue.Start()
+			waitApplied(t, queue)
+			time.Sleep(tt.settle)
+
+			for i, device := range fakes {
+				want := tt.wantOthers
+				if i == 0 {
+					want = tt.want
+				}
+				if got := device.State(); !sameNeighbors(got, want) {
+					t.Errorf("device %d neighbors = %v, want %v", i, got, want)
+				}
+			}
+			if got := countCalls(fakes[0], fake.OpAddNeighbor); got != tt.adds {
+				t.Errorf("adds = %d, want %d", got, tt.adds)
+			}
+			if got := countCalls(fakes[0], fake.OpRemoveNeighbor); got != tt.removes {
+				t.Errorf("removes = %d, want %d", got, tt.removes)
+			}
+		})
+	}
+}
+
+func TestWorkQueuePaused(t *testing.T) {
+	fakes, devices := devicesWith(t, 2, node2.IP)
+	devices.SetPaused(true)
+	events := devices.RecordEvents()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := manager.NewTestQueue(ctx, devices, 0)
+	queue.AddNeighbor(node1, "id")
+	queue.RemoveNeighbor(node2.IP, node2.NodeName, "id")
+	queue.Start()
+	waitApplied(t, queue)
+
+	for i, device := range fakes {
+		if got := device.State(); !sameNeighbors(got, []string{node2.IP}) {
+			t.Errorf("device %d neighbors = %v, want %v", i, got, []string{node2.IP})
+		}
+		if calls := device.Calls(); len(calls) != 0 {
+			t.Errorf("device %d calls = %v, want none", i, calls)
+		}
+	}
+	if len(events) != 0 {
+		t.Errorf("recorded %d Events of the dropped changes, first %q", len(events), <-events)
+	}
+}
+
+// sameNeighbors checks if the device has exactly the neighbors, with the
+// remote AS of the test devices.
+func sameNeighbors(state map[string]int, neighbors []string) bool {
+	want := map[string]int{}
+	for _, ip := range neighbors {
+		want[ip] = manager.TestRemoteAS
+	}
+	return maps.Equal(state, want)
+}
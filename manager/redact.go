@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are the JSON keys whose values are masked by redactJSON.
+var sensitiveKeys = []string{"password", "signature", "token", "secret", "credentials"}
+
+// Secret is a sensitive string like a password or an auth signature.
+// It is masked when logged, formatted or marshaled. Use Reveal to get the
+// actual value.
+type Secret string
+
+// String masks the secret.
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// GoString masks the secret for the %#v verb.
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// MarshalJSON masks the secret when it's marshaled.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Reveal returns the actual secret value.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// redactJSON masks the values of sensitive keys in a JSON document so it can
+// be logged. Documents that aren't valid JSON are masked completely.
+func redactJSON(data []byte) string {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return redacted
+	}
+	masked, err := json.Marshal(redactValue(doc))
+	if err != nil {
+		return redacted
+	}
+	return string(masked)
+}
+
+// redactValue walks a decoded JSON value and masks sensitive keys.
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if isSensitiveKey(key) {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+	return value
+}
+
+// isSensitiveKey checks if the JSON key holds a secret.
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
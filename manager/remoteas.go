@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// remoteASTable maps label selectors to remote ASNs, so node pools running
+// different ASNs are peered by one controller without per-node annotations.
+// The first matching selector wins, nodes matching none keep the device
+// remote AS.
+type remoteASTable struct {
+	raw     string
+	entries []remoteASEntry
+}
+
+// remoteASEntry is a selector of the table and its remote AS.
+type remoteASEntry struct {
+	selector labels.Selector
+	remoteAS int
+}
+
+// parseRemoteASTable parses semicolon-separated "selector:ASN" entries,
+// e.g. "pool=a:64601;pool=b:64602". The selectors use the Kubernetes syntax
+// of NODES_LABEL_SELECTOR.
+// Returns nil if the table is empty, or an error if an entry is invalid.
+func parseRemoteASTable(raw string) (*remoteASTable, error) {
+	table := &remoteASTable{raw: raw}
+	for _, entry := range strings.Split(raw, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("entry %q must be selector:ASN", entry)
+		}
+		selector, err := labels.Parse(strings.TrimSpace(entry[:i]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector in %q: %w", entry, err)
+		}
+		remoteAS, err := strconv.Atoi(strings.TrimSpace(entry[i+1:]))
+		if err != nil || remoteAS <= 0 {
+			return nil, fmt.Errorf("invalid ASN in %q", entry)
+		}
+		table.entries = append(table.entries, remoteASEntry{selector: selector, remoteAS: remoteAS})
+	}
+	if len(table.entries) == 0 {
+		return nil, nil
+	}
+	return table, nil
+}
+
+// lookup returns the remote AS of the first selector matching the labels,
+// 0 if none matches.
+func (t *remoteASTable) lookup(nodeLabels map[string]string) int {
+	if t == nil {
+		return 0
+	}
+	set := labels.Set(nodeLabels)
+	for _, entry := range t.entries {
+		if entry.selector.Matches(set) {
+			return entry.remoteAS
+		}
+	}
+	return 0
+}
+
+// asns returns the remote ASNs of the table.
+func (t *remoteASTable) asns() []int {
+	if t == nil {
+		return nil
+	}
+	asns := make([]int, 0, len(t.entries))
+	for _, entry := range t.entries {
+		asns = append(asns, entry.remoteAS)
+	}
+	return asns
+}
+
+// String returns the table as configured.
+func (t *remoteASTable) String() string {
+	if t == nil {
+		return ""
+	}
+	return t.raw
+}
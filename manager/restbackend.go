@@ -0,0 +1,384 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const backendREST = "rest"
+
+// restEndpointConfig is a request of the REST backend. The path and body
+// are Go templates.
+type restEndpointConfig struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Body   string `json:"body,omitempty"`
+}
+
+// restBackendConfig defines the REST backend of a device, so other
+// REST-manageable BGP devices can be targeted without writing Go code.
+// Templates get the Username, Password, Token, AS, IP and RemoteAS fields,
+// and a json function quoting a value. Extraction uses JSONPath, like
+// {.neighbors[*]}.
+type restBackendConfig struct {
+	// Auth logs in and extracts the token of the session, optional
+	Auth *struct {
+		restEndpointConfig
+		Token string `json:"token"`
+	} `json:"auth,omitempty"`
+	// Header authenticates the requests, optional
+	Header *struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"header,omitempty"`
+	// List gets the neighbor items, and their IP and remote AS
+	List struct {
+		restEndpointConfig
+		Neighbors string `json:"neighbors"`
+		IP        string `json:"ip"`
+		RemoteAS  string `json:"remoteAS"`
+	} `json:"list"`
+	Add    restEndpointConfig `json:"add"`
+	Delete restEndpointConfig `json:"delete"`
+}
+
+// restEndpoint is a parsed request of the REST backend.
+type restEndpoint struct {
+	method     string
+	path, body *template.Template
+}
+
+// restTemplates are the parsed templates and JSONPaths of the REST
+// backend, shared by the devices.
+type restTemplates struct {
+	auth                   *restEndpoint
+	token                  *jsonpath.JSONPath
+	headerName             string
+	headerValue            *template.Template
+	list, add, delete      restEndpoint
+	neighbors, ip, peerASN *jsonpath.JSONPath
+}
+
+// restData is the data of the REST backend templates.
+type restData struct {
+	Username, Password, Token string
+	AS                        int
+	IP                        string
+	RemoteAS                  int
+}
+
+// parseRESTBackend loads the REST backend definition from the JSON file.
+// Returns an error if the file or its templates are invalid.
+func parseRESTBackend(path string) (*restTemplates, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading REST backend: %w", err)
+	}
+	var config restBackendConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return nil, fmt.Errorf("parsing REST backend: %w", err)
+	}
+
+	var errs []error
+	parse := func(name, text string) *template.Template {
+		tmpl, err := template.New(name).
+			Funcs(template.FuncMap{"json": jsonQuote}).
+			Option("missingkey=error").
+			Parse(text)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		return tmpl
+	}
+	endpoint := func(name string, config restEndpointConfig) restEndpoint {
+		if config.Method == "" || config.Path == "" {
+			errs = append(errs, fmt.Errorf("%s: method and path must be set", name))
+		}
+		return restEndpoint{
+			method: config.Method,
+			path:   parse(name+" path", config.Path),
+			body:   parse(name+" body", config.Body),
+		}
+	}
+	jsonPath := func(name, expr string) *jsonpath.JSONPath {
+		if expr == "" {
+			errs = append(errs, fmt.Errorf("%s must be set", name))
+			return nil
+		}
+		if !strings.HasPrefix(expr, "{") {
+			expr = "{" + expr + "}"
+		}
+		jp := jsonpath.New(name)
+		if err := jp.Parse(expr); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		return jp
+	}
+
+	t := &restTemplates{
+		list:      endpoint("list", config.List.restEndpointConfig),
+		add:       endpoint("add", config.Add),
+		delete:    endpoint("delete", config.Delete),
+		neighbors: jsonPath("list neighbors", config.List.Neighbors),
+		ip:        jsonPath("list ip", config.List.IP),
+		peerASN:   jsonPath("list remoteAS", config.List.RemoteAS),
+	}
+	if config.Auth != nil {
+		auth := endpoint("auth", config.Auth.restEndpointConfig)
+		t.auth = &auth
+		t.token = jsonPath("auth token", config.Auth.Token)
+	}
+	if config.Header != nil {
+		t.headerName = config.Header.Name
+		t.headerValue = parse("header value", config.Header.Value)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// jsonQuote renders the value as JSON, e.g. a quoted string.
+func jsonQuote(value interface{}) (string, error) {
+	raw, err := json.Marshal(value)
+	return string(raw), err
+}
+
+// restBackend manages the neighbors of a device with the REST requests
+// defined by the configuration.
+type restBackend struct {
+	templates   *restTemplates
+	address     string
+	as          int
+	client      *http.Client
+	credentials func() Credentials
+
+	mu    sync.Mutex
+	token string
+}
+
+// newRESTBackend creates the REST backend of the device.
+func newRESTBackend(a *A10, templates *restTemplates) *restBackend {
+	return &restBackend{
+		templates:   templates,
+		address:     a.address,
+		as:          a.as,
+		client:      a.client,
+		credentials: a.currentCredentials,
+	}
+}
+
+// data returns the template data of the request.
+func (r *restBackend) data(neighborIP string, remoteAS int) restData {
+	creds := r.credentials()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token := r.token
+	if token == "" {
+		token = creds.Token.Reveal()
+	}
+	return restData{
+		Username: creds.Username,
+		Password: creds.Password.Reveal(),
+		Token:    token,
+		AS:       r.as,
+		IP:       neighborIP,
+		RemoteAS: remoteAS,
+	}
+}
+
+// login runs the auth request and keeps the extracted token.
+// Returns an error if the operation fails.
+func (r *restBackend) login(ctx context.Context) error {
+	body, status, err := r.do(ctx, *r.templates.auth, r.data("", 0), false)
+	if err != nil {
+		return fmt.Errorf("logging in: %w", err)
+	}
+	if status/100 != 2 {
+		return fmt.Errorf("logging in: %w", newStatusError(status, deviceBusy(status, body)))
+	}
+	values, err := findJSONPath(r.templates.token, body)
+	if err != nil || len(values) != 1 {
+		return fmt.Errorf("extracting the token: %v", err)
+	}
+	r.mu.Lock()
+	r.token = fmt.Sprint(values[0])
+	r.mu.Unlock()
+	return nil
+}
+
+// request runs the request, logging in first if there is no session and
+// again if the session is rejected.
+// Returns the response body and status code, or an error if the request
+// fails.
+func (r *restBackend) request(ctx context.Context, endpoint restEndpoint, data func() restData) ([]byte, int, error) {
+	if r.templates.auth != nil {
+		r.mu.Lock()
+		loggedIn := r.token != ""
+		r.mu.Unlock()
+		if !loggedIn {
+			if err := r.login(ctx); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+	body, status, err := r.do(ctx, endpoint, data(), true)
+	if err == nil && status == http.StatusUnauthorized && r.templates.auth != nil {
+		if err := r.login(ctx); err != nil {
+			return nil, 0, err
+		}
+		body, status, err = r.do(ctx, endpoint, data(), true)
+	}
+	return body, status, err
+}
+
+// do renders and makes the request.
+// Returns the response body and status code, or an error if the request
+// can't be made.
+func (r *restBackend) do(ctx context.Context, endpoint restEndpoint, data restData, authenticate bool) ([]byte, int, error) {
+	var path, body bytes.Buffer
+	if err := endpoint.path.Execute(&path, data); err != nil {
+		return nil, 0, fmt.Errorf("rendering path: %w", err)
+	}
+	if err := endpoint.body.Execute(&body, data); err != nil {
+		return nil, 0, fmt.Errorf("rendering body: %w", err)
+	}
+	var reqBody io.Reader
+	if body.Len() > 0 {
+		reqBody = &body
+	}
+	req, err := http.NewRequestWithContext(ctx, endpoint.method, r.address+path.String(), reqBody)
+	if err != nil {
+		return nil, 0, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("content-type", "application/json")
+	if id := correlationID(ctx); id != "" {
+		req.Header.Set(correlationHeader, id)
+	}
+	if authenticate && r.templates.headerValue != nil {
+		var value bytes.Buffer
+		if err := r.templates.headerValue.Execute(&value, data); err != nil {
+			return nil, 0, fmt.Errorf("rendering auth header: %w", err)
+		}
+		req.Header.Set(r.templates.headerName, value.String())
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading response body: %w", err)
+	}
+	return respBody, resp.StatusCode, nil
+}
+
+// neighbors gets the neighbor items and extracts their IP and remote AS.
+func (r *restBackend) neighbors(ctx context.Context) ([]ipv4Neighbor, error) {
+	body, status, err := r.request(ctx, r.templates.list, func() restData { return r.data("", 0) })
+	if err != nil {
+		return nil, err
+	}
+	if status/100 != 2 {
+		return nil, fmt.Errorf("listing neighbors: %w", newStatusError(status, deviceBusy(status, body)))
+	}
+	items, err := findJSONPath(r.templates.neighbors, body)
+	if err != nil {
+		return nil, fmt.Errorf("extracting the neighbors: %w", err)
+	}
+	neighbors := make([]ipv4Neighbor, 0, len(items))
+	for _, item := range items {
+		ip, err := findJSONValue(r.templates.ip, item)
+		if err != nil {
+			return nil, fmt.Errorf("extracting the neighbor IP: %w", err)
+		}
+		remoteAS, err := findJSONValue(r.templates.peerASN, item)
+		if err != nil {
+			return nil, fmt.Errorf("extracting the neighbor remote AS of %s: %w", ip, err)
+		}
+		asn, err := strconv.Atoi(remoteAS)
+		if err != nil {
+			return nil, fmt.Errorf("remote AS of %s is not a number: %q", ip, remoteAS)
+		}
+		neighbors = append(neighbors, ipv4Neighbor{NeighborIPV4: ip, RemoteAS: asn})
+	}
+	return neighbors, nil
+}
+
+// addNeighbor runs the add request.
+func (r *restBackend) addNeighbor(ctx context.Context, neighborIP string, remoteAS int) error {
+	_, status, err := r.request(ctx, r.templates.add, func() restData { return r.data(neighborIP, remoteAS) })
+	if err != nil {
+		return err
+	}
+	if status/100 != 2 {
+		return fmt.Errorf("adding neighbor: %w", newStatusError(status, deviceBusy(status, nil)))
+	}
+	return nil
+}
+
+// removeNeighbor runs the delete request, a 404 means it's already gone.
+func (r *restBackend) removeNeighbor(ctx context.Context, neighborIP string) error {
+	_, status, err := r.request(ctx, r.templates.delete, func() restData { return r.data(neighborIP, 0) })
+	if err != nil {
+		return err
+	}
+	if status/100 != 2 && status != http.StatusNotFound {
+		return fmt.Errorf("removing neighbor: %w", newStatusError(status, deviceBusy(status, nil)))
+	}
+	return nil
+}
+
+// findJSONPath returns the values the JSONPath selects in the JSON body.
+func findJSONPath(jp *jsonpath.JSONPath, body []byte) ([]interface{}, error) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("unmarshaling JSON: %w", err)
+	}
+	return findJSONPathIn(jp, doc)
+}
+
+// findJSONPathIn returns the values the JSONPath selects in the document.
+func findJSONPathIn(jp *jsonpath.JSONPath, doc interface{}) ([]interface{}, error) {
+	results, err := jp.FindResults(doc)
+	if err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	for _, result := range results {
+		for _, value := range result {
+			values = append(values, value.Interface())
+		}
+	}
+	return values, nil
+}
+
+// findJSONValue returns the single value the JSONPath selects in the
+// document as a string.
+func findJSONValue(jp *jsonpath.JSONPath, doc interface{}) (string, error) {
+	values, err := findJSONPathIn(jp, doc)
+	if err != nil {
+		return "", err
+	}
+	if len(values) != 1 {
+		return "", fmt.Errorf("expected a single value, got %d", len(values))
+	}
+	if number, ok := values[0].(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	}
+	return fmt.Sprint(values[0]), nil
+}
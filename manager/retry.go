@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+)
+
+// retryPolicy is how a request is retried after a failed attempt, by the
+// idempotency of the operation, so retries can't create duplicate
+// neighbors or mask partial failures. A busy device rejects the request
+// without applying it, so busy responses are always retried.
+type retryPolicy struct {
+	// idempotent requests are retried as is
+	idempotent bool
+	// applied checks if a failed non-idempotent request took effect anyway,
+	// e.g. the device created the neighbor but the response was lost. The
+	// request succeeds if it did and is retried otherwise. Without it, a
+	// non-idempotent request isn't retried.
+	applied func() (bool, error)
+	// notFoundDone treats 404 as success, the object is already gone
+	notFoundDone bool
+}
+
+var (
+	// retryIdempotent retries reads and logins as is.
+	retryIdempotent = retryPolicy{idempotent: true}
+	// retryDelete retries deletes, a 404 means an earlier attempt deleted
+	// the object.
+	retryDelete = retryPolicy{idempotent: true, notFoundDone: true}
+)
+
+// retryCreate re-checks if the neighbor exists before retrying its
+// creation.
+func (a *A10) retryCreate(ctx context.Context, neighborIP string) retryPolicy {
+	return retryPolicy{applied: func() (bool, error) {
+		return a.neighborExists(ctx, neighborIP)
+	}}
+}
+
+// neighborExists checks on the device if the neighbor exists.
+// Returns an error if the operation fails.
+func (a *A10) neighborExists(ctx context.Context, neighborIP string) (bool, error) {
+	url := fmt.Sprintf("%s%s/%s", a.address, fmt.Sprintf(bgpEndpoint, a.as), neighborIP)
+	_, err := a.request(ctx, "GET", url, nil, retryIdempotent)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// canRetry checks if the failed request can be retried. A non-idempotent
+// request is done if it was applied anyway.
+// Returns an error if the request can't be retried.
+func (p retryPolicy) canRetry(logger *log.Logger, lastErr error) (done bool, err error) {
+	if p.idempotent {
+		return false, nil
+	}
+	if p.applied == nil {
+		return false, fmt.Errorf("not retrying a non-idempotent request: %w", lastErr)
+	}
+	applied, err := p.applied()
+	if err != nil {
+		return false, fmt.Errorf("checking if the failed request was applied: %w (after %w)", err, lastErr)
+	}
+	if applied {
+		logger.Info("Failed request was applied by the device, not retrying", "error", lastErr)
+	}
+	return applied, nil
+}
@@ -0,0 +1,84 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// verifyAdd waits for the session of the neighbor added to the device to
+// establish. If it doesn't and rollback is enabled, the neighbor is
+// removed from the device and quarantined.
+// Returns an error if the verification fails.
+func (d *Devices) verifyAdd(ctx context.Context, a10 *A10, neighbor Neighbor) error {
+	err := a10.waitEstablished(ctx, neighbor.IP, d.verifyTimeout)
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("verifying neighbor: %w", err)
+	d.status.setError(a10.address, neighbor.IP, err)
+	if d.rollback {
+		d.rollbackNeighbor(ctx, a10, neighbor, err)
+	}
+	return fmt.Errorf("device %s: %w", a10.address, err)
+}
+
+// rollbackFailedAdd removes the neighbor the device failed to add if it was
+// created anyway, so it isn't left half-configured, and quarantines it.
+// If it wasn't created, the add is retried as usual.
+func (d *Devices) rollbackFailedAdd(ctx context.Context, a10 *A10, neighbor Neighbor, addErr error) {
+	exists, err := a10.hasNeighbor(ctx, neighbor.IP)
+	if err != nil {
+		d.logger.Error(
+			"Error checking the failed neighbor on A10, not rolling it back",
+			"device", a10.address,
+			"neighbor", neighbor.IP,
+			"correlationID", correlationID(ctx),
+			"error", err,
+		)
+		return
+	}
+	if !exists {
+		return
+	}
+	d.rollbackNeighbor(ctx, a10, neighbor, addErr)
+}
+
+// rollbackNeighbor removes the neighbor from the device and quarantines
+// it.
+func (d *Devices) rollbackNeighbor(ctx context.Context, a10 *A10, neighbor Neighbor, reason error) {
+	logger := d.logger.With(
+		"device", a10.address,
+		"neighbor", neighbor.IP,
+		"node", neighbor.NodeName,
+		"correlationID", correlationID(ctx),
+	)
+	logger.Warn("Rolling the neighbor back", "reason", reason)
+	rollbacks.WithLabelValues(a10.address).Inc()
+	var err error
+	if a10.containsNeighbor(neighbor.IP) {
+		err = a10.RemoveNeighbor(ctx, neighbor.IP, neighbor.NodeName)
+	} else {
+		err = a10.deleteNeighbor(ctx, neighbor.IP)
+	}
+	if err != nil {
+		logger.Error("Error rolling the neighbor back", "error", err)
+	}
+	d.quarantine.add(neighbor, reason.Error())
+}
+
+// hasNeighbor checks if the neighbor exists on the device, bypassing the
+// cache.
+// Returns an error if the operation fails.
+func (a *A10) hasNeighbor(ctx context.Context, neighborIP string) (bool, error) {
+	if a.backend == nil {
+		return a.neighborExists(ctx, neighborIP)
+	}
+	neighbors, err := a.backend.neighbors(ctx)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(neighbors, func(neighbor ipv4Neighbor) bool {
+		return neighbor.NeighborIPV4 == neighborIP
+	}), nil
+}
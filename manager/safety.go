@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// removalGuard limits how many managed neighbors a single reconcile may
+// remove from a device, so a bad label selector or an API hiccup can't
+// withdraw the whole cluster from the load balancer at once.
+type removalGuard struct {
+	// limit is the maximum number of removals, or the maximum percentage of
+	// the managed neighbors if percent is set, 0 disables the guard
+	limit    float64
+	percent  bool
+	override bool
+}
+
+// parseRemovalGuard parses the limit, a number like "10" or a percentage of
+// the managed neighbors like "25%". Override lets every removal through,
+// e.g. for an intended decommission.
+// Returns an error if the limit is invalid.
+func parseRemovalGuard(raw string, override bool) (removalGuard, error) {
+	guard := removalGuard{override: override}
+	if raw == "" {
+		return guard, nil
+	}
+	value, percent := strings.CutSuffix(raw, "%")
+	limit, err := strconv.ParseFloat(value, 64)
+	if err != nil || limit <= 0 || (percent && limit > 100) {
+		return removalGuard{}, fmt.Errorf("must be a positive number or a percentage, got %q", raw)
+	}
+	guard.limit = limit
+	guard.percent = percent
+	return guard, nil
+}
+
+// maxRemovals returns the maximum number of removals out of the managed
+// neighbors, -1 for no limit.
+func (g removalGuard) maxRemovals(managed int) int {
+	if g.limit == 0 || g.override {
+		return -1
+	}
+	if g.percent {
+		return int(math.Floor(float64(managed) * g.limit / 100))
+	}
+	return int(g.limit)
+}
+
+// allows checks if the removals are within the limit.
+func (g removalGuard) allows(removals, managed int) bool {
+	limit := g.maxRemovals(managed)
+	return limit < 0 || removals <= limit
+}
+
+// String returns the limit as configured.
+func (g removalGuard) String() string {
+	if g.limit == 0 {
+		return "disabled"
+	}
+	limit := strconv.FormatFloat(g.limit, 'f', -1, 64)
+	if g.percent {
+		limit += "%"
+	}
+	if g.override {
+		limit += " (overridden)"
+	}
+	return limit
+}
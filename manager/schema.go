@@ -0,0 +1,77 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+const (
+	// schemaLenient ignores unknown fields and tolerates missing lists,
+	// logging the unexpected responses
+	schemaLenient = "lenient"
+	// schemaStrict fails on unexpected responses
+	schemaStrict = "strict"
+	// maxSchemaDiagnosticBody caps the response body logged with schema
+	// diagnostics
+	maxSchemaDiagnosticBody = 1024
+)
+
+// errUnexpectedSchema is returned in strict mode when a device returns a
+// response of an unexpected shape.
+var errUnexpectedSchema = errors.New("unexpected aXAPI response schema")
+
+// decodeResponse decodes an aXAPI response expected to be an object with
+// the key, e.g. ipv4-neighbor-list. A missing key or other top-level keys,
+// like an error response, are an unexpected schema: an error in strict
+// mode, a warning with diagnostics otherwise.
+// Returns an error if the response isn't a JSON object of the value type.
+func (a *A10) decodeResponse(what string, body []byte, key string, v interface{}) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		a.logUnexpectedSchema(what, "response is not a JSON object", body)
+		return fmt.Errorf("%w: %s: %w", errUnexpectedSchema, what, err)
+	}
+	keys := slices.Sorted(maps.Keys(fields))
+	if _, ok := fields[key]; !ok || len(keys) > 1 {
+		detail := fmt.Sprintf("expected the %q key, got %q", key, keys)
+		if err := a.unexpectedSchema(what, detail, body); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		a.logUnexpectedSchema(what, err.Error(), body)
+		return fmt.Errorf("%w: %s: %w", errUnexpectedSchema, what, err)
+	}
+	return nil
+}
+
+// unexpectedSchema reports a response of an unexpected shape.
+// Returns an error in strict mode.
+func (a *A10) unexpectedSchema(what, detail string, body []byte) error {
+	a.logUnexpectedSchema(what, detail, body)
+	if a.responseSchema == schemaStrict {
+		return fmt.Errorf("%w: %s: %s", errUnexpectedSchema, what, detail)
+	}
+	return nil
+}
+
+// logUnexpectedSchema logs the diagnostics of an unexpected response with
+// the redacted body, so the device and firmware quirks can be told apart.
+func (a *A10) logUnexpectedSchema(what, detail string, body []byte) {
+	logBody := redactJSON(body)
+	if len(logBody) > maxSchemaDiagnosticBody {
+		logBody = logBody[:maxSchemaDiagnosticBody] + "..."
+	}
+	unexpectedResponses.WithLabelValues(a.address, what).Inc()
+	a.logger.Warn(
+		"Unexpected aXAPI response schema",
+		"device", a.address,
+		"response", what,
+		"schema", a.responseSchema,
+		"detail", detail,
+		"body", logBody,
+	)
+}
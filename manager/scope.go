@@ -0,0 +1,139 @@
+package manager
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// topologyScope restricts the managed nodes to regions and zones, read from
+// the topology labels, so per-site instances in a stretched cluster don't
+// fight over the same nodes or peer the nodes of a site with a remote
+// device. Nodes out of scope are left to the other instances: they're
+// neither added nor removed, and their neighbors found on the devices are
+// kept. It is safe for concurrent use.
+type topologyScope struct {
+	regions map[string]struct{}
+	zones   map[string]struct{}
+
+	mu sync.Mutex
+	// foreign maps the nodes out of scope to their addresses, and back
+	foreign   map[string]string
+	addresses map[string]string
+}
+
+// parseTopologyScope parses the comma-separated regions and zones.
+// Returns nil if both are empty.
+func parseTopologyScope(regions, zones string) *topologyScope {
+	split := func(list string) map[string]struct{} {
+		if list == "" {
+			return nil
+		}
+		set := map[string]struct{}{}
+		for _, item := range strings.Split(list, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				set[item] = struct{}{}
+			}
+		}
+		return set
+	}
+	scope := &topologyScope{
+		regions:   split(regions),
+		zones:     split(zones),
+		foreign:   map[string]string{},
+		addresses: map[string]string{},
+	}
+	if scope.regions == nil && scope.zones == nil {
+		return nil
+	}
+	return scope
+}
+
+// String returns the scope for the logs.
+func (s *topologyScope) String() string {
+	if s == nil {
+		return ""
+	}
+	var parts []string
+	for _, dimension := range []struct {
+		label  string
+		values map[string]struct{}
+	}{{v1.LabelTopologyRegion, s.regions}, {v1.LabelTopologyZone, s.zones}} {
+		if dimension.values == nil {
+			continue
+		}
+		values := slices.Sorted(maps.Keys(dimension.values))
+		parts = append(parts, fmt.Sprintf("%s in (%s)", dimension.label, strings.Join(values, ",")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// contains checks if the node is in the scope. Without its topology label,
+// a node is out of the scope of the dimension.
+func (s *topologyScope) contains(node *v1.Node) bool {
+	if s == nil {
+		return true
+	}
+	if s.regions != nil {
+		if _, ok := s.regions[node.Labels[v1.LabelTopologyRegion]]; !ok {
+			return false
+		}
+	}
+	if s.zones != nil {
+		if _, ok := s.zones[node.Labels[v1.LabelTopologyZone]]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// exclude records the address of a node out of the scope, so its neighbor
+// is kept.
+func (s *topologyScope) exclude(nodeName, address string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forgetLocked(nodeName)
+	if address != "" {
+		s.foreign[nodeName] = address
+		s.addresses[address] = nodeName
+	}
+}
+
+// forget forgets a deleted node, or a node back in the scope.
+func (s *topologyScope) forget(nodeName string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forgetLocked(nodeName)
+}
+
+// forgetLocked forgets the node. Must be called with the lock held.
+func (s *topologyScope) forgetLocked(nodeName string) {
+	if address, ok := s.foreign[nodeName]; ok {
+		delete(s.foreign, nodeName)
+		if s.addresses[address] == nodeName {
+			delete(s.addresses, address)
+		}
+	}
+}
+
+// foreignNeighbor returns the node out of the scope the neighbor belongs
+// to, if any.
+func (s *topologyScope) foreignNeighbor(neighborIP string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nodeName, ok := s.addresses[neighborIP]
+	return nodeName, ok
+}
@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// nodeSelector is a union of label selectors: a node matches if it matches
+// any of them. It allows covering node pools with different labeling
+// schemes with one controller.
+type nodeSelector struct {
+	raw       string
+	selectors []labels.Selector
+}
+
+// parseNodeSelector parses semicolon-separated label selectors,
+// e.g. "bgp=cilium;pool=edge". Each selector uses the Kubernetes syntax, so
+// it may combine comma-separated requirements including negative ones, e.g.
+// "bgp=cilium,pool!=test,!node-role.kubernetes.io/control-plane".
+// Returns an error if a selector is invalid.
+func parseNodeSelector(raw string) (nodeSelector, error) {
+	selector := nodeSelector{raw: raw}
+	for _, part := range strings.Split(raw, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		parsed, err := labels.Parse(part)
+		if err != nil {
+			return nodeSelector{}, fmt.Errorf("invalid label selector %q: %w", part, err)
+		}
+		selector.selectors = append(selector.selectors, parsed)
+	}
+	if len(selector.selectors) == 0 {
+		return nodeSelector{}, fmt.Errorf("at least one label selector must be set")
+	}
+	return selector, nil
+}
+
+// matches checks if the labels match any of the selectors.
+func (s nodeSelector) matches(nodeLabels map[string]string) bool {
+	set := labels.Set(nodeLabels)
+	for _, selector := range s.selectors {
+		if selector.Matches(set) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the selectors as configured.
+func (s nodeSelector) String() string {
+	return s.raw
+}
@@ -0,0 +1,221 @@
+package manager
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	defaultStatusAddress = ":8080"
+	shutdownTimeout      = 5 * time.Second
+)
+
+// StatusServer serves the status API, health checks and metrics.
+type StatusServer struct {
+	logger  *log.Logger
+	ctx     context.Context
+	address string
+	status  *statusTracker
+	health  *healthState
+	// checkpoints restore the failed batches on request if set
+	checkpoints *checkpointer
+	// restoreToken authenticates the checkpoint restores, they're disabled
+	// without it
+	restoreToken Secret
+	// approvals approve the staged removals on request if set
+	approvals *approvalGate
+	// approvalToken authenticates the approvals, approving through the API
+	// is disabled without it
+	approvalToken Secret
+	// resyncToken authenticates the manual resyncs, they're disabled
+	// without it
+	resyncToken Secret
+	// resyncs is notified of the requested manual resyncs
+	resyncs chan struct{}
+	// syncWebhook receives the device sync notifications if set
+	syncWebhook *syncWebhook
+}
+
+// Start starts the status server in the background.
+// The server is shut down when the context is done.
+func (s *StatusServer) Start() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/status", s.statusHandler)
+	mux.HandleFunc("/plan", s.planHandler)
+	mux.HandleFunc("/checkpoint/restore", s.restoreHandler)
+	mux.HandleFunc("/approvals", s.approvalsHandler)
+	mux.HandleFunc("/resync", s.resyncHandler)
+	mux.HandleFunc("/webhook/sync", s.syncWebhook.handle)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{
+		Addr:              s.address,
+		Handler:           mux,
+		ReadHeaderTimeout: defaultTimeout,
+	}
+
+	go func() {
+		s.logger.Info("Starting status server", "address", s.address)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Status server failed", "error", err)
+		}
+	}()
+
+	go func() {
+		<-s.ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			s.logger.Error("Error shutting down status server", "error", err)
+		}
+	}()
+}
+
+// healthz reports that the process is alive, with the health of the
+// components as JSON. It answers 200 whatever the components report, so
+// an unreachable device doesn't make the liveness probe restart the
+// controller.
+func (s *StatusServer) healthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.health.report()); err != nil {
+		s.logger.Error("Error encoding health", "error", err)
+	}
+}
+
+// statusHandler returns the per-device per-neighbor sync matrix and the node
+// eligibility results as JSON.
+func (s *StatusServer) statusHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.status.report()); err != nil {
+		s.logger.Error("Error encoding status", "error", err)
+	}
+}
+
+// planHandler returns the plan of the last reconcile as JSON, or as ACOS
+// CLI commands with the cli format.
+func (s *StatusServer) planHandler(w http.ResponseWriter, r *http.Request) {
+	plan := s.status.lastPlan()
+	if plan == nil {
+		http.Error(w, "no reconcile yet", http.StatusServiceUnavailable)
+		return
+	}
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+	case "cli":
+		w.Header().Set("content-type", "text/plain")
+		_, _ = w.Write([]byte(plan.cli()))
+		return
+	default:
+		http.Error(w, "unknown format "+format, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(plan); err != nil {
+		s.logger.Error("Error encoding plan", "error", err)
+	}
+}
+
+// restoreHandler restores the checkpoint of the last failed batch. The
+// request must carry the restore token as a bearer token.
+func (s *StatusServer) restoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.restoreToken == "" {
+		http.Error(w, "checkpoint restore is disabled, set CHECKPOINT_RESTORE_TOKEN", http.StatusNotFound)
+		return
+	}
+	if !bearerAuthorized(r, s.restoreToken) {
+		w.Header().Set("www-authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := s.checkpoints.restoreFailed(s.ctx); err != nil {
+		s.logger.Error("Error restoring checkpoint", "error", err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// approvalsHandler returns the removals waiting for approval as JSON, or
+// approves them with their ID in the id parameter of a POST carrying the
+// approval token as a bearer token.
+func (s *StatusServer) approvalsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		pending := s.approvals.report()
+		if pending == nil {
+			http.Error(w, "no removals waiting for approval", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("content-type", "application/json")
+		if err := json.NewEncoder(w).Encode(pending); err != nil {
+			s.logger.Error("Error encoding pending approval", "error", err)
+		}
+	case http.MethodPost:
+		if s.approvalToken == "" {
+			http.Error(w, "approving through the API is disabled, set APPROVAL_TOKEN", http.StatusNotFound)
+			return
+		}
+		if !bearerAuthorized(r, s.approvalToken) {
+			w.Header().Set("www-authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := s.approvals.approve(r.URL.Query().Get("id")); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("allow", http.MethodGet+", "+http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// resyncHandler requests an immediate full reconcile, with the device
+// neighbors fetched again, e.g. right after a manual device maintenance.
+// The request must carry the resync token as a bearer token. Requests made
+// while a resync is pending are coalesced with it.
+func (s *StatusServer) resyncHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.resyncToken == "" {
+		http.Error(w, "manual resync is disabled, set RESYNC_TOKEN", http.StatusNotFound)
+		return
+	}
+	if !bearerAuthorized(r, s.resyncToken) {
+		w.Header().Set("www-authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	select {
+	case s.resyncs <- struct{}{}:
+		s.logger.Info("Manual resync requested", "remote", r.RemoteAddr)
+	default:
+		s.logger.Debug("Manual resync already pending", "remote", r.RemoteAddr)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// bearerAuthorized checks if the request carries the token as a bearer
+// token, in constant time.
+func bearerAuthorized(r *http.Request, token Secret) bool {
+	given, ok := strings.CutPrefix(r.Header.Get("authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
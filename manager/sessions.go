@@ -0,0 +1,189 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// bgpOperEndpoint is the aXAPI BGP neighbor operational data endpoint.
+const bgpOperEndpoint = bgpEndpoint + "/oper"
+
+// bgpEstablished is the BGP state of an up session.
+const bgpEstablished = "Established"
+
+// bgpNeighborsOper is the structure of the BGP neighbor operational data.
+type bgpNeighborsOper struct {
+	Ipv4NeighborList []struct {
+		NeighborIPV4 string `json:"neighbor-ipv4"`
+		Oper         struct {
+			State            string          `json:"state"`
+			UpTime           json.RawMessage `json:"up-time"`
+			PrefixesReceived int             `json:"prefixes-received"`
+		} `json:"oper"`
+	} `json:"ipv4-neighbor-list"`
+}
+
+// bgpSession is the state of a BGP session of a device.
+// prefixesReceived is -1 when the source doesn't report it.
+type bgpSession struct {
+	neighbor         string
+	state            string
+	uptime           time.Duration
+	prefixesReceived int
+}
+
+// GetSessions gets the state of the BGP sessions from the A10 device, from
+// the oper API or over SNMP.
+// Returns an error if the operation fails.
+func (a *A10) GetSessions() ([]bgpSession, error) {
+	if a.snmp != nil {
+		return a.snmp.sessions(a.ctx)
+	}
+	url := fmt.Sprintf("%s%s", a.address, fmt.Sprintf(bgpOperEndpoint, a.as))
+	body, err := a.sessionRequest(a.ctx, "GET", url, nil, retryIdempotent)
+	if err != nil {
+		return nil, fmt.Errorf("getting BGP sessions: %w", err)
+	}
+
+	var response bgpNeighborsOper
+	if err := a.decodeResponse("sessions", body, "ipv4-neighbor-list", &response); err != nil {
+		return nil, fmt.Errorf("unmarshaling JSON from A10 to get BGP sessions: %w", err)
+	}
+	sessions := make([]bgpSession, 0, len(response.Ipv4NeighborList))
+	for _, n := range response.Ipv4NeighborList {
+		sessions = append(sessions, bgpSession{
+			neighbor:         n.NeighborIPV4,
+			state:            n.Oper.State,
+			uptime:           parseUptime(n.Oper.UpTime),
+			prefixesReceived: n.Oper.PrefixesReceived,
+		})
+	}
+	return sessions, nil
+}
+
+// uptimePattern matches uptimes like "1d02h03m" or "3w2d".
+var uptimePattern = regexp.MustCompile(`^(?:(\d+)w)?(?:(\d+)d)?(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s)?$`)
+
+// parseUptime parses a session uptime, either seconds or a string like
+// "01:02:03" or "1d02h03m". Unknown formats are 0.
+func parseUptime(raw json.RawMessage) time.Duration {
+	var seconds float64
+	if err := json.Unmarshal(raw, &seconds); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil || value == "" {
+		return 0
+	}
+	if parts := strings.Split(value, ":"); len(parts) == 3 {
+		var uptime time.Duration
+		for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+			n, err := strconv.Atoi(parts[i])
+			if err != nil {
+				return 0
+			}
+			uptime += time.Duration(n) * unit
+		}
+		return uptime
+	}
+	match := uptimePattern.FindStringSubmatch(value)
+	if match == nil {
+		return 0
+	}
+	var uptime time.Duration
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	for i, unit := range units {
+		if n, err := strconv.Atoi(match[i+1]); err == nil {
+			uptime += time.Duration(n) * unit
+		}
+	}
+	return uptime
+}
+
+// SessionExporter periodically scrapes the BGP session state of the
+// managed neighbors from the devices and publishes it as metrics labeled
+// with the node name, so the controller is the single observability point
+// of the cluster to A10 peering.
+type SessionExporter struct {
+	logger   *log.Logger
+	ctx      context.Context
+	devices  *Devices
+	status   *statusTracker
+	interval time.Duration
+	// exported holds the label sets of the last scrape, to drop the
+	// sessions that are gone
+	exported map[[3]string]struct{}
+}
+
+// Start starts scraping in the background.
+// A zero interval disables the exporter.
+func (e *SessionExporter) Start() {
+	if e.interval == 0 {
+		return
+	}
+	e.logger.Info("Starting BGP session exporter", "interval", e.interval)
+	e.exported = map[[3]string]struct{}{}
+	go func() {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			e.scrape()
+			select {
+			case <-e.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// scrape updates the session metrics of every device.
+// The metrics of a device that fails to answer are kept.
+func (e *SessionExporter) scrape() {
+	nodes := e.status.nodeNames()
+	exported := map[[3]string]struct{}{}
+	for _, a10 := range e.devices.devices {
+		sessions, err := a10.GetSessions()
+		if err != nil {
+			e.logger.Error("Error scraping BGP sessions", "device", a10.address, "error", err)
+			for labels := range e.exported {
+				if labels[0] == a10.address {
+					exported[labels] = struct{}{}
+				}
+			}
+			continue
+		}
+		for _, session := range sessions {
+			if !a10.containsNeighbor(session.neighbor) {
+				continue
+			}
+			labels := [3]string{a10.address, session.neighbor, nodes[session.neighbor]}
+			exported[labels] = struct{}{}
+			e.status.setSession(a10.address, session.neighbor, session.state)
+			established := 0.0
+			if session.state == bgpEstablished {
+				established = 1
+			}
+			bgpSessionEstablished.WithLabelValues(labels[:]...).Set(established)
+			bgpSessionUptime.WithLabelValues(labels[:]...).Set(session.uptime.Seconds())
+			if session.prefixesReceived >= 0 {
+				bgpSessionPrefixes.WithLabelValues(labels[:]...).Set(float64(session.prefixesReceived))
+			}
+		}
+	}
+	for labels := range e.exported {
+		if _, ok := exported[labels]; !ok {
+			bgpSessionEstablished.DeleteLabelValues(labels[:]...)
+			bgpSessionUptime.DeleteLabelValues(labels[:]...)
+			bgpSessionPrefixes.DeleteLabelValues(labels[:]...)
+		}
+	}
+	e.exported = exported
+}
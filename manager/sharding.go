@@ -0,0 +1,125 @@
+package manager
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	shardModeNone   = ""
+	shardModeNode   = "node"
+	shardModeDevice = "device"
+)
+
+// Sharder splits the managed nodes or devices between active replicas.
+// In node mode every replica manages the nodes whose name hashes to its
+// shard index on all devices. In device mode every replica manages all
+// nodes on the devices whose position maps to its shard index.
+// In node mode the neighbors found on the devices are removed by the replica
+// of their node too, so a single replica removes them, honoring the removal
+// delay and the tombstones of its node events.
+type Sharder struct {
+	mode  string
+	count int
+	index int
+
+	mu sync.Mutex
+	// nodes maps the addresses to the node last seen with them, whatever
+	// its shard
+	nodes map[string]string
+}
+
+// shardIndexFromPodName gets the shard index from the StatefulSet pod
+// ordinal, e.g. 2 for "a10-bgp-neighbor-manager-2".
+// Returns an error if the pod name has no ordinal.
+func shardIndexFromPodName(podName string) (int, error) {
+	i := strings.LastIndex(podName, "-")
+	if i == -1 {
+		return 0, fmt.Errorf("pod name %q has no ordinal", podName)
+	}
+	index, err := strconv.Atoi(podName[i+1:])
+	if err != nil {
+		return 0, fmt.Errorf("pod name %q has no ordinal: %w", podName, err)
+	}
+	return index, nil
+}
+
+// hashShard maps the key to a shard.
+func (s *Sharder) hashShard(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(s.count))
+}
+
+// ownsNode checks if this replica manages the node.
+func (s *Sharder) ownsNode(nodeName string) bool {
+	if s.mode != shardModeNode {
+		return true
+	}
+	return s.hashShard(nodeName) == s.index
+}
+
+// ownsNeighbor checks if this replica manages a neighbor that has no node,
+// e.g. an extra neighbor found on the device.
+func (s *Sharder) ownsNeighbor(neighborIP string) bool {
+	if s.mode != shardModeNode {
+		return true
+	}
+	return s.hashShard(neighborIP) == s.index
+}
+
+// observe records the address of the node, in node mode, so the replica
+// managing the node removes its neighbor. The addresses of the deleted nodes
+// are kept, their neighbors are still removed by the replica of the node.
+func (s *Sharder) observe(node *v1.Node, nodes *nodeState) {
+	if s.mode != shardModeNode {
+		return
+	}
+	address := nodes.address(node)
+	if address == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nodes == nil {
+		s.nodes = map[string]string{}
+	}
+	s.nodes[address] = node.Name
+}
+
+// ownsRemoval checks if this replica removes the neighbor found on a device,
+// by the node it was added for, the node last seen with its address or, for
+// neighbors with no known node, by its IP.
+func (s *Sharder) ownsRemoval(neighborIP, nodeName string) bool {
+	if s.mode != shardModeNode {
+		return true
+	}
+	if nodeName == "" {
+		s.mu.Lock()
+		nodeName = s.nodes[neighborIP]
+		s.mu.Unlock()
+	}
+	return s.owns(Neighbor{IP: neighborIP, NodeName: nodeName})
+}
+
+// owns checks if this replica manages the neighbor, by its node or, for
+// neighbors without a node, by its IP.
+func (s *Sharder) owns(neighbor Neighbor) bool {
+	if neighbor.NodeName == "" {
+		return s.ownsNeighbor(neighbor.IP)
+	}
+	return s.ownsNode(neighbor.NodeName)
+}
+
+// ownsDevice checks if this replica manages the device at the position.
+func (s *Sharder) ownsDevice(position int) bool {
+	if s.mode != shardModeDevice {
+		return true
+	}
+	return position%s.count == s.index
+}
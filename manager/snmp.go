@@ -0,0 +1,103 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+const (
+	sessionSourceAXAPI = "axapi"
+	sessionSourceSNMP  = "snmp"
+
+	defaultSNMPPort = 161
+
+	// bgpPeerStateOID is bgpPeerState of the BGP4-MIB bgpPeerTable, indexed
+	// by the peer IP
+	bgpPeerStateOID = ".1.3.6.1.2.1.15.3.1.2"
+	// bgpPeerFsmEstablishedTimeOID is bgpPeerFsmEstablishedTime, the seconds
+	// the peer has been in the established state or since it last was
+	bgpPeerFsmEstablishedTimeOID = ".1.3.6.1.2.1.15.3.1.16"
+)
+
+// snmpSessions reads the BGP session state from the BGP4-MIB over SNMPv2c,
+// for accounts that can't read the oper API. The MIB has no per-peer
+// prefix counts, so they aren't reported.
+type snmpSessions struct {
+	host      string
+	port      uint16
+	community Secret
+}
+
+// newSNMPSessions creates the SNMP session reader of the device at the
+// address, a URL like https://a10 or a host name.
+func newSNMPSessions(address string, port uint16, community Secret) *snmpSessions {
+	host := address
+	if u, err := url.Parse(address); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	return &snmpSessions{host: host, port: port, community: community}
+}
+
+// sessions walks the bgpPeerTable for the state and established time of
+// every peer.
+// Returns an error if the operation fails.
+func (s *snmpSessions) sessions(ctx context.Context) ([]bgpSession, error) {
+	client := &gosnmp.GoSNMP{
+		Context:   ctx,
+		Target:    s.host,
+		Port:      s.port,
+		Community: s.community.Reveal(),
+		Version:   gosnmp.Version2c,
+		Timeout:   defaultTimeout,
+		Retries:   maxRequestRetries,
+		MaxOids:   gosnmp.MaxOids,
+	}
+	if err := client.Connect(); err != nil {
+		return nil, fmt.Errorf("connecting to SNMP agent: %w", err)
+	}
+	defer client.Conn.Close()
+
+	states, err := client.BulkWalkAll(bgpPeerStateOID)
+	if err != nil {
+		return nil, fmt.Errorf("walking bgpPeerState: %w", err)
+	}
+	times, err := client.BulkWalkAll(bgpPeerFsmEstablishedTimeOID)
+	if err != nil {
+		return nil, fmt.Errorf("walking bgpPeerFsmEstablishedTime: %w", err)
+	}
+
+	established := map[string]time.Duration{}
+	for _, pdu := range times {
+		established[strings.TrimPrefix(pdu.Name, bgpPeerFsmEstablishedTimeOID+".")] =
+			time.Duration(gosnmp.ToBigInt(pdu.Value).Int64()) * time.Second
+	}
+	sessions := make([]bgpSession, 0, len(states))
+	for _, pdu := range states {
+		peer := strings.TrimPrefix(pdu.Name, bgpPeerStateOID+".")
+		session := bgpSession{
+			neighbor:         peer,
+			state:            bgpPeerStateName(gosnmp.ToBigInt(pdu.Value).Int64()),
+			prefixesReceived: -1,
+		}
+		// the established time counts since the session went down too
+		if session.state == bgpEstablished {
+			session.uptime = established[peer]
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// bgpPeerStateName returns the name of a bgpPeerState value.
+func bgpPeerStateName(state int64) string {
+	names := []string{"", "Idle", "Connect", "Active", "OpenSent", "OpenConfirm", bgpEstablished}
+	if state > 0 && state < int64(len(names)) {
+		return names[state]
+	}
+	return fmt.Sprintf("unknown(%d)", state)
+}
@@ -0,0 +1,229 @@
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	backendAXAPI = "axapi"
+	backendSSH   = "ssh"
+
+	defaultSSHPort = 22
+)
+
+// cliBackend applies the neighbor changes with ACOS CLI commands over SSH,
+// for environments where the aXAPI is disabled by policy. It logs in with
+// the device credentials, so pre-issued tokens aren't supported.
+type cliBackend struct {
+	logger *log.Logger
+	// address is the host:port of the SSH server
+	address     string
+	as          int
+	hostKey     ssh.HostKeyCallback
+	credentials func() Credentials
+}
+
+// newCLIBackend creates the SSH backend of the device at the address, a URL
+// like https://a10 or a host name.
+func newCLIBackend(a *A10, port int, hostKey ssh.HostKeyCallback) *cliBackend {
+	host := a.address
+	if u, err := url.Parse(a.address); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	return &cliBackend{
+		logger:      a.logger,
+		address:     net.JoinHostPort(host, strconv.Itoa(port)),
+		as:          a.as,
+		hostKey:     hostKey,
+		credentials: a.currentCredentials,
+	}
+}
+
+// parseSSHHostKeys loads the known_hosts file verifying the device host
+// keys. Without it, host keys aren't verified, like the certificates
+// without pinned fingerprints, which is warned about at startup.
+// Returns an error if the file can't be loaded.
+func parseSSHHostKeys(path string) (ssh.HostKeyCallback, error) {
+	if path == "" {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading known hosts: %w", err)
+	}
+	return callback, nil
+}
+
+// run runs the commands in a CLI session and returns the output.
+// Returns an error if the session fails or the CLI rejects a command.
+func (c *cliBackend) run(ctx context.Context, commands ...string) (string, error) {
+	creds := c.credentials()
+	config := &ssh.ClientConfig{
+		User: creds.Username,
+		Auth: []ssh.AuthMethod{
+			ssh.Password(creds.Password.Reveal()),
+			ssh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = creds.Password.Reveal()
+				}
+				return answers, nil
+			}),
+		},
+		HostKeyCallback: c.hostKey,
+		Timeout:         defaultTimeout,
+	}
+
+	dialer := net.Dialer{Timeout: defaultTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return "", fmt.Errorf("connecting to %s: %w", c.address, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, c.address, config)
+	if err != nil {
+		conn.Close()
+		return "", fmt.Errorf("logging in to %s: %w", c.address, err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+	// abort the session when the context is done
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("opening session: %w", err)
+	}
+	defer session.Close()
+	if err := session.RequestPty("vt100", 0, 512, ssh.TerminalModes{ssh.ECHO: 0}); err != nil {
+		return "", fmt.Errorf("requesting terminal: %w", err)
+	}
+	var output bytes.Buffer
+	session.Stdout = &output
+	session.Stderr = &output
+	// enable asks for the enable password, blank by default
+	script := append([]string{"enable", "", "terminal length 0"}, commands...)
+	session.Stdin = strings.NewReader(strings.Join(append(script, "exit"), "\n") + "\n")
+	c.logger.Debug("Running A10 CLI commands", "device", c.address, "commands", commands)
+	if err := session.Shell(); err != nil {
+		return "", fmt.Errorf("starting shell: %w", err)
+	}
+	waitErr := session.Wait()
+	if waitErr != nil && ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if line := cliError(output.String()); line != "" {
+		return "", fmt.Errorf("CLI rejected the commands: %s", line)
+	}
+	if waitErr != nil {
+		return "", fmt.Errorf("running the CLI session: %w", waitErr)
+	}
+	return output.String(), nil
+}
+
+// cliError returns the first error line of the CLI output, empty if none.
+func cliError(output string) string {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "% ") || strings.Contains(line, "Invalid input") {
+			return line
+		}
+	}
+	return ""
+}
+
+// neighbors gets the BGP neighbors and their remote AS from the running
+// configuration of the device AS.
+// Returns an error if the operation fails.
+func (c *cliBackend) neighbors(ctx context.Context) ([]ipv4Neighbor, error) {
+	output, err := c.run(ctx, "show running-config")
+	if err != nil {
+		return nil, err
+	}
+	return parseCLINeighbors(output, c.as)
+}
+
+// parseCLINeighbors parses the "neighbor <ip> remote-as <as>" lines of the
+// router bgp block of the AS in the running configuration.
+// Returns an error if the block is missing.
+func parseCLINeighbors(config string, as int) ([]ipv4Neighbor, error) {
+	header := fmt.Sprintf("router bgp %d", as)
+	var neighbors []ipv4Neighbor
+	found, inBlock := false, false
+	scanner := bufio.NewScanner(strings.NewReader(config))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r ")
+		if !inBlock {
+			if line == header {
+				found, inBlock = true, true
+			}
+			continue
+		}
+		// the block ends at the first line that isn't indented
+		if line != "" && line[0] != ' ' {
+			break
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != "neighbor" || fields[2] != "remote-as" {
+			continue
+		}
+		remoteAS, err := strconv.Atoi(fields[3])
+		if err != nil {
+			continue
+		}
+		neighbors = append(neighbors, ipv4Neighbor{NeighborIPV4: fields[1], RemoteAS: remoteAS})
+	}
+	if !found {
+		return nil, fmt.Errorf("no %q in the running configuration", header)
+	}
+	return neighbors, nil
+}
+
+// addNeighbor configures the neighbor with its remote AS.
+// Returns an error if the operation fails.
+func (c *cliBackend) addNeighbor(ctx context.Context, neighborIP string, remoteAS int) error {
+	_, err := c.run(
+		ctx,
+		"configure",
+		fmt.Sprintf("router bgp %d", c.as),
+		fmt.Sprintf("neighbor %s remote-as %d", neighborIP, remoteAS),
+		"end",
+	)
+	return err
+}
+
+// shutdownNeighbor shuts the neighbor down or brings it back up.
+// Returns an error if the operation fails.
+func (c *cliBackend) shutdownNeighbor(ctx context.Context, neighborIP string, shutdown bool) error {
+	command := fmt.Sprintf("neighbor %s shutdown", neighborIP)
+	if !shutdown {
+		command = "no " + command
+	}
+	_, err := c.run(ctx, "configure", fmt.Sprintf("router bgp %d", c.as), command, "end")
+	return err
+}
+
+// removeNeighbor removes the neighbor configuration.
+// Returns an error if the operation fails.
+func (c *cliBackend) removeNeighbor(ctx context.Context, neighborIP string) error {
+	_, err := c.run(
+		ctx,
+		"configure",
+		fmt.Sprintf("router bgp %d", c.as),
+		fmt.Sprintf("no neighbor %s", neighborIP),
+		"end",
+	)
+	return err
+}
@@ -0,0 +1,36 @@
+package manager
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// staticNeighbors are extra neighbors without a node, e.g. out-of-cluster
+// route reflectors, the controller always ensures exist on the devices.
+type staticNeighbors []Neighbor
+
+// parseStaticNeighbors parses a comma-separated list of neighbor IPs.
+// Returns an error if an entry is not an IP address.
+func parseStaticNeighbors(list string) (staticNeighbors, error) {
+	var static staticNeighbors
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		ip, err := parseNeighborIP(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid static neighbor: %w", err)
+		}
+		static = append(static, Neighbor{IP: ip})
+	}
+	return static, nil
+}
+
+// contains checks if the neighbor is static.
+func (s staticNeighbors) contains(neighborIP string) bool {
+	return slices.ContainsFunc(s, func(neighbor Neighbor) bool {
+		return neighbor.IP == neighborIP
+	})
+}
@@ -0,0 +1,169 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	// statsdFormatStatsd flattens the labels into the metric name
+	statsdFormatStatsd = "statsd"
+	// statsdFormatDatadog sends the labels as DogStatsD tags
+	statsdFormatDatadog = "datadog"
+	// statsdMaxPacket keeps the UDP packets under the Ethernet MTU
+	statsdMaxPacket = 1432
+)
+
+// statsdReplacer replaces the characters with a meaning in the statsd line
+// protocol, and the dots of the label values flattened into the names.
+var statsdReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", "\n", "_")
+
+// statsdSink sends the metrics to a statsd or DogStatsD agent over UDP,
+// converted from the Prometheus registry: gauges are sent as gauges,
+// counters as the counts since the previous push, histograms and summaries
+// as the count and sum counters of their observations.
+type statsdSink struct {
+	address  string
+	format   string
+	prefix   string
+	gatherer prometheus.Gatherer
+	// sent are the counter values sent so far by metric and labels
+	sent map[string]float64
+}
+
+// newStatsdSink creates a statsd sink sending to the host:port address in
+// the format, with the metric names prefixed by the prefix if set.
+func newStatsdSink(address, format, prefix string) *statsdSink {
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	return &statsdSink{
+		address:  address,
+		format:   format,
+		prefix:   prefix,
+		gatherer: prometheus.DefaultGatherer,
+		sent:     map[string]float64{},
+	}
+}
+
+func (s *statsdSink) String() string {
+	return "statsd"
+}
+
+// push gathers the metrics and sends them to the agent. The address is
+// resolved on every push, so the agent may come up after the controller.
+func (s *statsdSink) push(ctx context.Context) error {
+	families, err := s.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("gathering metrics: %w", err)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", s.address)
+	if err != nil {
+		return fmt.Errorf("connecting to statsd agent: %w", err)
+	}
+	defer conn.Close()
+
+	var packet []byte
+	for _, line := range s.lines(families) {
+		if len(packet) > 0 && len(packet)+1+len(line) > statsdMaxPacket {
+			if _, err := conn.Write(packet); err != nil {
+				return fmt.Errorf("sending metrics to statsd agent: %w", err)
+			}
+			packet = packet[:0]
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		if _, err := conn.Write(packet); err != nil {
+			return fmt.Errorf("sending metrics to statsd agent: %w", err)
+		}
+	}
+	return nil
+}
+
+// lines converts the metric families to statsd lines. Non-finite values
+// are dropped.
+func (s *statsdSink) lines(families []*dto.MetricFamily) []string {
+	var lines []string
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				lines = s.appendCount(lines, family.GetName(), m, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				lines = s.appendLine(lines, family.GetName(), m, m.GetGauge().GetValue(), "g")
+			case dto.MetricType_UNTYPED:
+				lines = s.appendLine(lines, family.GetName(), m, m.GetUntyped().GetValue(), "g")
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				lines = s.appendCount(lines, family.GetName()+"_count", m, float64(h.GetSampleCount()))
+				lines = s.appendCount(lines, family.GetName()+"_sum", m, h.GetSampleSum())
+			case dto.MetricType_SUMMARY:
+				sm := m.GetSummary()
+				lines = s.appendCount(lines, family.GetName()+"_count", m, float64(sm.GetSampleCount()))
+				lines = s.appendCount(lines, family.GetName()+"_sum", m, sm.GetSampleSum())
+			}
+		}
+	}
+	return lines
+}
+
+// appendCount appends the count of the cumulative value since the previous
+// push. A value lower than the previous one was reset and is sent whole.
+func (s *statsdSink) appendCount(lines []string, name string, m *dto.Metric, value float64) []string {
+	if !finite(value) {
+		return lines
+	}
+	key := name
+	for _, label := range m.GetLabel() {
+		key += "\x00" + label.GetName() + "=" + label.GetValue()
+	}
+	count := value
+	if previous, ok := s.sent[key]; ok && value >= previous {
+		count = value - previous
+	}
+	s.sent[key] = value
+	if count == 0 {
+		return lines
+	}
+	return s.appendLine(lines, name, m, count, "c")
+}
+
+// appendLine appends the statsd line of the value of the type.
+func (s *statsdSink) appendLine(lines []string, name string, m *dto.Metric, value float64, kind string) []string {
+	if !finite(value) {
+		return lines
+	}
+	var line strings.Builder
+	line.WriteString(s.prefix)
+	line.WriteString(statsdReplacer.Replace(name))
+	if s.format == statsdFormatStatsd {
+		for _, label := range m.GetLabel() {
+			line.WriteByte('.')
+			line.WriteString(strings.ReplaceAll(statsdReplacer.Replace(label.GetValue()), ".", "_"))
+		}
+	}
+	line.WriteByte(':')
+	line.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	line.WriteByte('|')
+	line.WriteString(kind)
+	if s.format == statsdFormatDatadog && len(m.GetLabel()) > 0 {
+		tags := make([]string, 0, len(m.GetLabel()))
+		for _, label := range m.GetLabel() {
+			tags = append(tags, label.GetName()+":"+statsdReplacer.Replace(label.GetValue()))
+		}
+		line.WriteString("|#")
+		line.WriteString(strings.Join(tags, ","))
+	}
+	return append(lines, line.String())
+}
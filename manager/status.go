@@ -0,0 +1,260 @@
+package manager
+
+import (
+	"sync"
+	"time"
+)
+
+// syncState is the sync state of a neighbor on a single device.
+type syncState string
+
+const (
+	syncPending syncState = "pending"
+	syncSynced  syncState = "synced"
+	syncError   syncState = "error"
+)
+
+// neighborStatus is the sync status of a neighbor on a single device.
+// LastSync is the last successful sync that changed the neighbor on the
+// device, or that first found it there, so syncs confirming the same state
+// don't move it. Retries counts the failed attempts since then.
+type neighborStatus struct {
+	Node          string    `json:"node,omitempty"`
+	Present       bool      `json:"present"`
+	State         syncState `json:"state"`
+	LastError     string    `json:"lastError,omitempty"`
+	LastErrorTime time.Time `json:"lastErrorTime,omitempty"`
+	LastSync      time.Time `json:"lastSync,omitempty"`
+	Retries       int       `json:"retries"`
+	// Session is the BGP state of the session as last scraped, empty if
+	// unknown
+	Session string `json:"session,omitempty"`
+}
+
+// nodeStatus is the result of the last eligibility evaluation of a node.
+type nodeStatus struct {
+	Address  string `json:"address,omitempty"`
+	Eligible bool   `json:"eligible"`
+	Reason   string `json:"reason"`
+}
+
+// statusReport is the status API payload.
+type statusReport struct {
+	Devices map[string]map[string]neighborStatus `json:"devices"`
+	Nodes   map[string]nodeStatus                `json:"nodes"`
+	// Quarantined are the neighbors rolled back or flapping and not added
+	// again until their quarantine expires
+	Quarantined map[string]quarantineEntry `json:"quarantined,omitempty"`
+	// Adoption is the adoption report of the first reconcile
+	Adoption *adoptionReport `json:"adoption,omitempty"`
+	// SubnetViolations maps the nodes violating the subnet policy to the
+	// violation
+	SubnetViolations map[string]string `json:"subnetViolations,omitempty"`
+	// DuplicateAddresses maps the nodes reporting the address of another
+	// node to the address
+	DuplicateAddresses map[string]string `json:"duplicateAddresses,omitempty"`
+	// PendingApproval are the removals waiting for approval
+	PendingApproval *stagedRemovals `json:"pendingApproval,omitempty"`
+	// Tombstones are the neighbors of the deleted nodes shut down until
+	// their removal
+	Tombstones map[string]tombstone `json:"tombstones,omitempty"`
+}
+
+// statusTracker keeps the device x neighbor sync matrix and the node
+// eligibility results. It is safe for concurrent use.
+type statusTracker struct {
+	mu      sync.RWMutex
+	devices map[string]map[string]*neighborStatus
+	nodes   map[string]nodeStatus
+	// plan is the plan of the last reconcile
+	plan *reconcilePlan
+	// quarantine keeps the rolled back neighbors
+	quarantine *quarantine
+	// adoption is the adoption report of the first reconcile
+	adoption *adoptionReport
+	// approvals stage the removals waiting for approval
+	approvals *approvalGate
+	// tombstones keep the neighbors of the deleted nodes
+	tombstones *tombstones
+	// nodeState reports the subnet violations and the duplicate addresses
+	nodeState *nodeState
+}
+
+// newStatusTracker creates an empty status tracker.
+func newStatusTracker() *statusTracker {
+	return &statusTracker{
+		devices: map[string]map[string]*neighborStatus{},
+		nodes:   map[string]nodeStatus{},
+	}
+}
+
+// neighbor returns the status entry of a neighbor on a device,
+// creating it if needed. Must be called with the lock held.
+func (s *statusTracker) neighbor(device, neighborIP string) *neighborStatus {
+	neighbors, ok := s.devices[device]
+	if !ok {
+		neighbors = map[string]*neighborStatus{}
+		s.devices[device] = neighbors
+	}
+	status, ok := neighbors[neighborIP]
+	if !ok {
+		status = &neighborStatus{State: syncPending}
+		neighbors[neighborIP] = status
+	}
+	return status
+}
+
+// setPending marks the neighbor as pending on the device with the desired
+// presence.
+func (s *statusTracker) setPending(device, neighborIP, nodeName string, present bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.neighbor(device, neighborIP)
+	if nodeName != "" {
+		status.Node = nodeName
+	}
+	status.Present = present
+	status.State = syncPending
+	neighborSynced.WithLabelValues(device, neighborIP).Set(0)
+}
+
+// setSynced marks the neighbor as synced on the device, changed by the sync
+// if changed. Synced absent neighbors are dropped from the matrix.
+func (s *statusTracker) setSynced(device, neighborIP string, changed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.neighbor(device, neighborIP)
+	if !status.Present {
+		delete(s.devices[device], neighborIP)
+		neighborSynced.DeleteLabelValues(device, neighborIP)
+		neighborLastSync.DeleteLabelValues(device, neighborIP)
+		neighborRetries.DeleteLabelValues(device, neighborIP)
+		return
+	}
+	status.State = syncSynced
+	status.LastError = ""
+	if changed || status.LastSync.IsZero() {
+		status.LastSync = time.Now()
+	}
+	status.Retries = 0
+	neighborSynced.WithLabelValues(device, neighborIP).Set(1)
+	neighborLastSync.WithLabelValues(device, neighborIP).Set(float64(status.LastSync.Unix()))
+	neighborRetries.WithLabelValues(device, neighborIP).Set(0)
+}
+
+// setError marks the neighbor as failed on the device.
+func (s *statusTracker) setError(device, neighborIP string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.neighbor(device, neighborIP)
+	status.State = syncError
+	status.LastError = err.Error()
+	status.LastErrorTime = time.Now()
+	status.Retries++
+	neighborSynced.WithLabelValues(device, neighborIP).Set(0)
+	neighborRetries.WithLabelValues(device, neighborIP).Set(float64(status.Retries))
+	neighborSyncErrors.WithLabelValues(device).Inc()
+}
+
+// setSession records the BGP state of the session of a neighbor known on
+// the device.
+func (s *statusTracker) setSession(device, neighborIP, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status, ok := s.devices[device][neighborIP]; ok {
+		status.Session = state
+	}
+}
+
+// setNode records the eligibility result of a node.
+func (s *statusTracker) setNode(nodeName string, status nodeStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodes[nodeName] = status
+}
+
+// deleteNode forgets a deleted node.
+func (s *statusTracker) deleteNode(nodeName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.nodes, nodeName)
+}
+
+// neighborNode returns the node name of the neighbor on the device, empty
+// if unknown.
+func (s *statusTracker) neighborNode(device, neighborIP string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if status, ok := s.devices[device][neighborIP]; ok {
+		return status.Node
+	}
+	return ""
+}
+
+// setPlan records the plan of the last reconcile.
+func (s *statusTracker) setPlan(plan *reconcilePlan) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.plan = plan
+}
+
+// setAdoption records the adoption report unless one was recorded before.
+// Returns false if the report was already recorded.
+func (s *statusTracker) setAdoption(report *adoptionReport) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.adoption != nil {
+		return false
+	}
+	s.adoption = report
+	return true
+}
+
+// lastPlan returns the plan of the last reconcile, nil before the first
+// one.
+func (s *statusTracker) lastPlan() *reconcilePlan {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.plan
+}
+
+// nodeNames maps the addresses of the evaluated nodes to their names.
+func (s *statusTracker) nodeNames() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make(map[string]string, len(s.nodes))
+	for nodeName, status := range s.nodes {
+		if status.Address != "" {
+			names[status.Address] = nodeName
+		}
+	}
+	return names
+}
+
+// report returns a copy of the current status.
+func (s *statusTracker) report() statusReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	report := statusReport{
+		Devices: map[string]map[string]neighborStatus{},
+		Nodes:   map[string]nodeStatus{},
+	}
+	for device, neighbors := range s.devices {
+		report.Devices[device] = map[string]neighborStatus{}
+		for neighborIP, status := range neighbors {
+			report.Devices[device][neighborIP] = *status
+		}
+	}
+	for nodeName, status := range s.nodes {
+		report.Nodes[nodeName] = status
+	}
+	report.Quarantined = s.quarantine.report()
+	report.Adoption = s.adoption
+	if s.nodeState != nil {
+		report.SubnetViolations = s.nodeState.subnets.report()
+		report.DuplicateAddresses = s.nodeState.claims.report()
+	}
+	report.PendingApproval = s.approvals.report()
+	report.Tombstones = s.tombstones.report()
+	return report
+}
@@ -0,0 +1,155 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// defaultSubnetPolicyLabel is the node label with the site or zone of
+	// the subnet policy
+	defaultSubnetPolicyLabel = v1.LabelTopologyZone
+	// subnetPolicyDefault is the policy entry of the zones without their
+	// own entry
+	subnetPolicyDefault = "*"
+
+	subnetPolicyEnforce = "enforce"
+	subnetPolicyAudit   = "audit"
+)
+
+// subnetPolicy requires the node addresses to be in the subnets of the node
+// site or zone, read from a node label, to catch mislabeled or misconfigured
+// nodes. In enforce mode a violating node is ineligible, in audit mode it's
+// only reported. The violations are logged, published on the status API and
+// exported as metrics rather than silently skipped.
+type subnetPolicy struct {
+	logger  *log.Logger
+	label   string
+	audit   bool
+	subnets map[string][]netip.Prefix
+
+	mu sync.Mutex
+	// violations maps the violating nodes to the violation
+	violations map[string]string
+}
+
+// parseSubnetPolicy parses the policy, a JSON object of the sites or zones
+// to their subnets, e.g. {"zone-a": ["10.1.0.0/16"], "*": ["10.0.0.0/8"]}.
+// Returns nil if the policy is empty.
+// Returns an error if the policy or the mode is invalid.
+func parseSubnetPolicy(logger *log.Logger, raw, label, mode string) (*subnetPolicy, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var zones map[string][]string
+	if err := json.Unmarshal([]byte(raw), &zones); err != nil {
+		return nil, fmt.Errorf("parsing the policy: %w", err)
+	}
+	policy := &subnetPolicy{
+		logger:     logger,
+		label:      label,
+		subnets:    make(map[string][]netip.Prefix, len(zones)),
+		violations: map[string]string{},
+	}
+	switch mode {
+	case "", subnetPolicyEnforce:
+	case subnetPolicyAudit:
+		policy.audit = true
+	default:
+		return nil, fmt.Errorf("unknown mode %q, must be %s or %s", mode, subnetPolicyEnforce, subnetPolicyAudit)
+	}
+	for zone, cidrs := range zones {
+		for _, cidr := range cidrs {
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid subnet %q of %s: %w", cidr, zone, err)
+			}
+			policy.subnets[zone] = append(policy.subnets[zone], prefix.Masked())
+		}
+	}
+	return policy, nil
+}
+
+// check checks the node address is in the subnets of the node zone, falling
+// back to the default entry. A violation is reported, and makes the node
+// ineligible unless auditing.
+// Returns whether the node is allowed and the violation, if any.
+func (p *subnetPolicy) check(node *v1.Node, address string) (bool, string) {
+	if p == nil {
+		return true, ""
+	}
+	violation := p.violation(node, address)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if violation == "" {
+		delete(p.violations, node.Name)
+		subnetPolicyViolation.DeleteLabelValues(node.Name)
+		return true, ""
+	}
+	subnetPolicyViolation.WithLabelValues(node.Name).Set(1)
+	if p.violations[node.Name] != violation {
+		p.violations[node.Name] = violation
+		p.logger.Warn("Node address violates the subnet policy", "node", node.Name, "violation", violation, "audit", p.audit)
+	}
+	return p.audit, violation
+}
+
+// violation returns how the node address violates the policy, or an empty
+// string if it doesn't.
+func (p *subnetPolicy) violation(node *v1.Node, address string) string {
+	zone, labeled := node.Labels[p.label]
+	subnets, ok := p.subnets[zone]
+	if !labeled || !ok {
+		subnets, ok = p.subnets[subnetPolicyDefault]
+	}
+	if !ok {
+		if !labeled {
+			return fmt.Sprintf("node has no %s label", p.label)
+		}
+		return fmt.Sprintf("no subnets for %s %s", p.label, zone)
+	}
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return fmt.Sprintf("invalid address %s", address)
+	}
+	for _, prefix := range subnets {
+		if prefix.Contains(addr) {
+			return ""
+		}
+	}
+	if !labeled {
+		return fmt.Sprintf("address %s is outside the default subnets", address)
+	}
+	return fmt.Sprintf("address %s is outside the subnets of %s %s", address, p.label, zone)
+}
+
+// forget drops the violation of a deleted node.
+func (p *subnetPolicy) forget(nodeName string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.violations, nodeName)
+	subnetPolicyViolation.DeleteLabelValues(nodeName)
+}
+
+// report returns a copy of the violations.
+func (p *subnetPolicy) report() map[string]string {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	report := make(map[string]string, len(p.violations))
+	for nodeName, violation := range p.violations {
+		report[nodeName] = violation
+	}
+	return report
+}
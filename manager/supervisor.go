@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"context"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	defaultHealthCheckInterval = 30 * time.Second
+	// recoveryBackoff is the initial delay between probes of an unreachable
+	// device, doubled after every failed probe up to maxRecoveryBackoff
+	recoveryBackoff    = 5 * time.Second
+	maxRecoveryBackoff = 5 * time.Minute
+)
+
+// Supervisor monitors the connection to every device. When a device becomes
+// unreachable, it probes it with backoff until it recovers, at the longest
+// backoff if the error is fatal, and then
+// re-fetches its neighbors and replays the changes that failed meanwhile,
+// instead of waiting for the next node event to find out.
+type Supervisor struct {
+	logger   *log.Logger
+	ctx      context.Context
+	devices  *Devices
+	interval time.Duration
+	// resync queues the changes that make the devices match k8s
+	resync func()
+}
+
+// Start starts monitoring the devices in the background.
+// A zero interval disables the supervisor.
+func (s *Supervisor) Start() {
+	if s.interval == 0 {
+		return
+	}
+	s.logger.Info("Starting A10 connection supervisor", "interval", s.interval)
+	for _, a10 := range s.devices.devices {
+		deviceUp.WithLabelValues(a10.address).Set(1)
+		go s.watch(a10)
+	}
+}
+
+// watch probes the device until the context is done.
+func (s *Supervisor) watch(a10 *A10) {
+	logger := s.logger.With("device", a10.address)
+
+	healthy := true
+	backoff := recoveryBackoff
+	timer := time.NewTimer(s.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		if err := a10.probe(); err != nil {
+			class := classifyError(err)
+			if healthy {
+				logger.Error("Lost connection to A10", "error", err, "class", class)
+				healthy = false
+				backoff = recoveryBackoff
+			} else {
+				logger.Warn("A10 is still unreachable", "error", err, "class", class, "retryIn", backoff)
+			}
+			// probing often won't fix it, it needs the operator
+			if class == errorFatal {
+				backoff = maxRecoveryBackoff
+			}
+			deviceUp.WithLabelValues(a10.address).Set(0)
+			a10.unreachable.Store(true)
+			timer.Reset(backoff)
+			backoff = min(backoff*2, maxRecoveryBackoff)
+			continue
+		}
+
+		if !healthy {
+			logger.Info("Recovered connection to A10, replaying neighbor changes")
+			healthy = true
+			deviceUp.WithLabelValues(a10.address).Set(1)
+			a10.unreachable.Store(false)
+			s.resync()
+		}
+		timer.Reset(s.interval)
+	}
+}
+
+// probe checks the connection to the A10 device by re-fetching its
+// neighbors. On failure, the session is dropped so the next probe logs in
+// again.
+// Returns an error if the device is unreachable.
+func (a *A10) probe() error {
+	if err := a.GetNeighbors(); err != nil {
+		a.invalidateSession()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package manager
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// syncSignatureHeader carries the HMAC-SHA256 of the notification body,
+	// as "sha256=<hex>"
+	syncSignatureHeader = "x-signature-256"
+	maxSyncNotification = 1 << 20
+)
+
+// syncNotification tells the controller the state of devices changed
+// outside of it.
+type syncNotification struct {
+	// Devices are the addresses of the changed devices, all of them if
+	// empty
+	Devices []string `json:"devices"`
+	// Reason is logged, e.g. the change ticket
+	Reason string `json:"reason"`
+}
+
+// syncWebhook receives the notifications of the external systems changing
+// the devices, e.g. the device config management, the CMDB or the
+// maintenance tooling, so their neighbors are fetched again and reconciled
+// without waiting for the next periodic reconcile. The notifications
+// received while a sync is pending are merged into it. It is safe for
+// concurrent use.
+type syncWebhook struct {
+	logger  *log.Logger
+	secret  Secret
+	devices []string
+	synced  chan struct{}
+
+	mu sync.Mutex
+	// pending are the devices of the pending sync, all if it's for all of
+	// them
+	pending map[string]struct{}
+	all     bool
+}
+
+// newSyncWebhook creates a receiver authenticating the notifications with
+// the secret, about the devices.
+// Returns nil if the secret is empty.
+func newSyncWebhook(logger *log.Logger, secret Secret, devices []deviceConfig) *syncWebhook {
+	if secret == "" {
+		return nil
+	}
+	w := &syncWebhook{
+		logger: logger,
+		secret: secret,
+		synced: make(chan struct{}, 1),
+	}
+	for _, device := range devices {
+		w.devices = append(w.devices, device.address)
+	}
+	return w
+}
+
+// handle accepts a notification authenticated by its HMAC-SHA256 signature
+// with the secret, or the secret as a bearer token for the tools that can't
+// sign.
+func (w *syncWebhook) handle(rw http.ResponseWriter, r *http.Request) {
+	if w == nil {
+		http.Error(rw, "sync webhook is disabled, set SYNC_WEBHOOK_SECRET", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		rw.Header().Set("allow", http.MethodPost)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxSyncNotification))
+	if err != nil {
+		syncNotifications.WithLabelValues("invalid").Inc()
+		http.Error(rw, "reading body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !w.signed(r, body) && !bearerAuthorized(r, w.secret) {
+		syncNotifications.WithLabelValues("unauthorized").Inc()
+		rw.Header().Set("www-authenticate", "Bearer")
+		http.Error(rw, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var notification syncNotification
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &notification); err != nil {
+			syncNotifications.WithLabelValues("invalid").Inc()
+			http.Error(rw, "invalid notification: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := w.notify(notification.Devices); err != nil {
+		syncNotifications.WithLabelValues("invalid").Inc()
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	syncNotifications.WithLabelValues("accepted").Inc()
+	w.logger.Info(
+		"Device sync notification received",
+		"devices", notification.Devices,
+		"reason", notification.Reason,
+		"remote", r.RemoteAddr,
+	)
+	rw.WriteHeader(http.StatusAccepted)
+}
+
+// signed checks the HMAC-SHA256 signature of the body, in constant time.
+func (w *syncWebhook) signed(r *http.Request, body []byte) bool {
+	signature, ok := strings.CutPrefix(r.Header.Get(syncSignatureHeader), "sha256=")
+	if !ok {
+		return false
+	}
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(w.secret))
+	mac.Write(body)
+	return hmac.Equal(given, mac.Sum(nil))
+}
+
+// notify merges the devices, all of them if empty, into the pending sync.
+// Returns an error if a device is unknown.
+func (w *syncWebhook) notify(devices []string) error {
+	var unknown []string
+	for _, device := range devices {
+		if !slices.Contains(w.devices, device) {
+			unknown = append(unknown, device)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown devices %s", strings.Join(unknown, ", "))
+	}
+	w.mu.Lock()
+	if len(devices) == 0 {
+		w.all = true
+	} else if !w.all {
+		if w.pending == nil {
+			w.pending = map[string]struct{}{}
+		}
+		for _, device := range devices {
+			w.pending[device] = struct{}{}
+		}
+	}
+	w.mu.Unlock()
+	select {
+	case w.synced <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// take returns the devices of the pending sync, nil for all of them, and
+// clears it.
+// Returns false if no sync is pending.
+func (w *syncWebhook) take() ([]string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.all && w.pending == nil {
+		return nil, false
+	}
+	var devices []string
+	if !w.all {
+		devices = slices.Sorted(maps.Keys(w.pending))
+	}
+	w.pending, w.all = nil, false
+	return devices, true
+}
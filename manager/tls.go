@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// parseFingerprints parses a comma-separated list of SHA-256 certificate
+// fingerprints in hex, optionally with colons, e.g. "AB:CD:...".
+// Returns an error if a fingerprint is invalid.
+func parseFingerprints(list string) ([][]byte, error) {
+	var fingerprints [][]byte
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.ReplaceAll(strings.TrimSpace(entry), ":", "")
+		if entry == "" {
+			continue
+		}
+		fingerprint, err := hex.DecodeString(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fingerprint %q: %w", entry, err)
+		}
+		if len(fingerprint) != sha256.Size {
+			return nil, fmt.Errorf("invalid fingerprint %q: must be a SHA-256 hash", entry)
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	return fingerprints, nil
+}
+
+// verifyFingerprint returns a TLS peer verification function pinning the
+// device certificate: the leaf certificate must match one of the SHA-256
+// fingerprints. The certificate chain itself isn't verified, so it works with
+// self-signed certificates.
+func verifyFingerprint(fingerprints [][]byte) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("no certificate presented by the device")
+		}
+		sum := sha256.Sum256(rawCerts[0])
+		for _, fingerprint := range fingerprints {
+			if bytes.Equal(sum[:], fingerprint) {
+				return nil
+			}
+		}
+		return fmt.Errorf(
+			"device certificate fingerprint %s doesn't match any pinned fingerprint",
+			hex.EncodeToString(sum[:]),
+		)
+	}
+}
@@ -0,0 +1,170 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// tombstone is the neighbor of a deleted node, shut down until its
+// deletion.
+type tombstone struct {
+	Node    string    `json:"node,omitempty"`
+	Deleted time.Time `json:"deleted"`
+	Expires time.Time `json:"expires"`
+}
+
+// tombstones keep the neighbors of the deleted nodes shut down for a TTL
+// before deleting them, unless the nodes return, so transient Node object
+// deletions, e.g. during control plane upgrades, don't drop the neighbor
+// configuration. A nil store deletes the neighbors right away. It is safe
+// for concurrent use.
+type tombstones struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	neighbors map[string]tombstone
+}
+
+// newTombstones creates an empty store of the TTL.
+// Returns nil if the TTL is 0.
+func newTombstones(ttl time.Duration) *tombstones {
+	if ttl == 0 {
+		return nil
+	}
+	return &tombstones{ttl: ttl, neighbors: map[string]tombstone{}}
+}
+
+// add records the tombstone of the neighbor of the deleted node.
+// Returns its expiration.
+func (t *tombstones) add(neighborIP, nodeName string) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	entry := tombstone{Node: nodeName, Deleted: now, Expires: now.Add(t.ttl)}
+	t.neighbors[neighborIP] = entry
+	tombstonedNeighbors.Set(float64(len(t.neighbors)))
+	return entry.Expires
+}
+
+// remove forgets the tombstone of the neighbor, deleted or revived.
+func (t *tombstones) remove(neighborIP string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.neighbors, neighborIP)
+	tombstonedNeighbors.Set(float64(len(t.neighbors)))
+}
+
+// contains checks if the neighbor has a tombstone.
+func (t *tombstones) contains(neighborIP string) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.neighbors[neighborIP]
+	return ok
+}
+
+// report returns a copy of the tombstones.
+func (t *tombstones) report() map[string]tombstone {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	report := make(map[string]tombstone, len(t.neighbors))
+	for neighborIP, entry := range t.neighbors {
+		report[neighborIP] = entry
+	}
+	return report
+}
+
+// neighborShutdowner is a device backend that can shut a neighbor down
+// without deleting it.
+type neighborShutdowner interface {
+	// shutdownNeighbor shuts the neighbor down or brings it back up
+	shutdownNeighbor(ctx context.Context, neighborIP string, shutdown bool) error
+}
+
+// errShutdownUnsupported is returned when the backend can't shut the
+// neighbors down.
+var errShutdownUnsupported = errors.New("neighbor shutdown isn't supported by the backend")
+
+// ShutdownNeighbor shuts the neighbor down, or brings it back up, on the
+// device if it exists there.
+// Returns an error if the operation fails.
+func (a *A10) ShutdownNeighbor(ctx context.Context, neighborIP string, shutdown bool) error {
+	if a.protected.contains(neighborIP) || !a.containsNeighbor(neighborIP) {
+		return nil
+	}
+	if a.backend != nil {
+		backend, ok := a.backend.(neighborShutdowner)
+		if !ok {
+			return errShutdownUnsupported
+		}
+		return backend.shutdownNeighbor(ctx, neighborIP, shutdown)
+	}
+	if err := a.waitChangeBudget(ctx); err != nil {
+		return fmt.Errorf("waiting for the change rate limit: %w", err)
+	}
+	url := fmt.Sprintf("%s%s/%s", a.address, fmt.Sprintf(bgpEndpoint, a.as), neighborIP)
+	flag := 0
+	if shutdown {
+		flag = 1
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"ipv4-neighbor": map[string]interface{}{
+			"neighbor-ipv4": neighborIP,
+			"shutdown":      flag,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling request data: %w", err)
+	}
+	_, err = a.sessionRequest(ctx, "POST", url, data, retryIdempotent)
+	return err
+}
+
+// ShutdownNeighbor shuts the neighbor down, or brings it back up, on every
+// device. Backends that can't shut the neighbors down are skipped.
+// Returns the joined errors of the devices that failed.
+func (d *Devices) ShutdownNeighbor(ctx context.Context, neighborIP string, shutdown bool) error {
+	if d.pause.isPaused() {
+		d.logger.Info(
+			"A10 writes paused, not changing neighbor shutdown",
+			"neighbor", neighborIP,
+			"shutdown", shutdown,
+			"correlationID", correlationID(ctx),
+		)
+		return nil
+	}
+	var errs []error
+	for _, a10 := range d.devices {
+		err := a10.ShutdownNeighbor(ctx, neighborIP, shutdown)
+		if errors.Is(err, errShutdownUnsupported) {
+			d.logger.Debug("Neighbor shutdown isn't supported, leaving it", "device", a10.address, "neighbor", neighborIP)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("device %s: %w", a10.address, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ReviveNeighbor brings the neighbor of a returning node back up and adds
+// it where it's missing.
+// Returns the joined errors of the devices that failed.
+func (d *Devices) ReviveNeighbor(ctx context.Context, neighbor Neighbor) error {
+	if err := d.ShutdownNeighbor(ctx, neighbor.IP, false); err != nil {
+		return err
+	}
+	return d.AddNeighbor(ctx, neighbor)
+}
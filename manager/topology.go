@@ -0,0 +1,198 @@
+package manager
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// activePartitionEndpoint switches the session to an ADP partition.
+const activePartitionEndpoint = "/axapi/v3/active-partition/%s"
+
+// topologyFile is the device fleet of multi-datacenter estates, where every
+// device has its own address, credentials, AS, partition and nodes.
+type topologyFile struct {
+	Devices []topologyDevice `json:"devices"`
+}
+
+// topologyDevice is a device of the topology file.
+type topologyDevice struct {
+	Address string `json:"address"`
+	// AS is the local AS of the device, A10_AS if not set
+	AS int `json:"as,omitempty"`
+	// Partition is the ADP partition of the neighbors, shared if not set
+	Partition string `json:"partition,omitempty"`
+	// Credentials reference the files of the device credentials, the
+	// credentials source if not set
+	Credentials *topologyCredentials `json:"credentials,omitempty"`
+	// NodeSelector limits the nodes peered with the device, with the syntax
+	// of NODES_LABEL_SELECTOR
+	NodeSelector string `json:"nodeSelector,omitempty"`
+	// RemoteAS overrides A10_REMOTE_AS on the device
+	RemoteAS int `json:"remoteAS,omitempty"`
+	// PeerGroup is the peer group of the neighbors on the device
+	PeerGroup string `json:"peerGroup,omitempty"`
+	// NeighborAttributes override A10_NEIGHBOR_EXTRA_ATTRS on the device
+	NeighborAttributes map[string]interface{} `json:"neighborAttributes,omitempty"`
+}
+
+// topologyCredentials reference the credential files of a device, e.g. a
+// mounted Secret, read again on every refresh to pick up rotations.
+type topologyCredentials struct {
+	UsernameFile string `json:"usernameFile,omitempty"`
+	PasswordFile string `json:"passwordFile,omitempty"`
+	TokenFile    string `json:"tokenFile,omitempty"`
+}
+
+// deviceConfig is the configuration of a single device.
+type deviceConfig struct {
+	address   string
+	as        int
+	partition string
+	// credentials resolve the device credentials instead of the
+	// credentials source if set
+	credentials CredentialsProvider
+	// selector limits the nodes peered with the device if set
+	selector *nodeSelector
+	// remoteAS overrides the remote AS if set
+	remoteAS int
+	// neighborAttrs override the extra neighbor attributes
+	neighborAttrs map[string]interface{}
+}
+
+// parseTopology loads the devices of the topology file.
+// Returns an error if the file or a device is invalid.
+func parseTopology(path string, defaultAS int) ([]deviceConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading topology: %w", err)
+	}
+	var topology topologyFile
+	if err := yaml.UnmarshalStrict(raw, &topology); err != nil {
+		return nil, fmt.Errorf("parsing topology: %w", err)
+	}
+	if len(topology.Devices) == 0 {
+		return nil, fmt.Errorf("no devices in the topology")
+	}
+
+	seen := map[string]bool{}
+	devices := make([]deviceConfig, 0, len(topology.Devices))
+	for i, device := range topology.Devices {
+		address := strings.TrimSpace(device.Address)
+		if address == "" {
+			return nil, fmt.Errorf("device %d: address must be set", i)
+		}
+		if seen[address] {
+			return nil, fmt.Errorf("device %s is listed twice", address)
+		}
+		seen[address] = true
+
+		config := deviceConfig{
+			address:       address,
+			as:            device.AS,
+			partition:     device.Partition,
+			remoteAS:      device.RemoteAS,
+			neighborAttrs: device.NeighborAttributes,
+		}
+		if config.as == 0 {
+			config.as = defaultAS
+		}
+		if config.as <= 0 {
+			return nil, fmt.Errorf("device %s: as must be set without A10_AS", address)
+		}
+		if device.Credentials != nil {
+			config.credentials, err = device.Credentials.provider()
+			if err != nil {
+				return nil, fmt.Errorf("device %s: %w", address, err)
+			}
+		}
+		if device.NodeSelector != "" {
+			selector, err := parseNodeSelector(device.NodeSelector)
+			if err != nil {
+				return nil, fmt.Errorf("device %s: nodeSelector: %w", address, err)
+			}
+			config.selector = &selector
+		}
+		if config.remoteAS < 0 {
+			return nil, fmt.Errorf("device %s: remoteAS must be positive", address)
+		}
+		if device.PeerGroup != "" {
+			config.neighborAttrs = maps.Clone(config.neighborAttrs)
+			if config.neighborAttrs == nil {
+				config.neighborAttrs = map[string]interface{}{}
+			}
+			config.neighborAttrs["peer-group-name"] = device.PeerGroup
+		}
+		devices = append(devices, config)
+	}
+	return devices, nil
+}
+
+// overridesNeighbors checks if the device overrides neighbor attributes,
+// which only the aXAPI sets.
+func (d deviceConfig) overridesNeighbors() bool {
+	return len(d.neighborAttrs) > 0
+}
+
+// neighborExtraAttrs returns the extra neighbor attributes of the device,
+// the shared ones overridden by the device ones.
+func (d deviceConfig) neighborExtraAttrs(shared map[string]interface{}) map[string]interface{} {
+	if len(d.neighborAttrs) == 0 {
+		return shared
+	}
+	attrs := map[string]interface{}{}
+	maps.Copy(attrs, shared)
+	maps.Copy(attrs, d.neighborAttrs)
+	return attrs
+}
+
+// provider returns the provider of the referenced credential files.
+// Returns an error if neither a token nor a username and password are set.
+func (c *topologyCredentials) provider() (CredentialsProvider, error) {
+	switch {
+	case c.TokenFile != "":
+		return &tokenCredentials{tokenFile: c.TokenFile}, nil
+	case c.UsernameFile != "" && c.PasswordFile != "":
+		return &envCredentials{usernameFile: c.UsernameFile, passwordFile: c.PasswordFile}, nil
+	default:
+		return nil, fmt.Errorf("credentials need tokenFile, or usernameFile and passwordFile")
+	}
+}
+
+// sharedCredentials checks if any device uses the credentials source.
+func sharedCredentials(devices []deviceConfig) bool {
+	for _, device := range devices {
+		if device.credentials == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// selects checks if the neighbor is peered with the device. Neighbors
+// without a node, like the static ones, are peered with every device.
+func (a *A10) selects(neighbor Neighbor) bool {
+	return a.selector == nil || neighbor.NodeName == "" || a.selector.matches(neighbor.Labels)
+}
+
+// activatePartition switches the session to the device partition, so the
+// neighbors are managed in it instead of the shared partition.
+// Returns an error if the operation fails.
+func (a *A10) activatePartition(signature Secret) error {
+	if a.partition == "" {
+		return nil
+	}
+	url := a.address + fmt.Sprintf(activePartitionEndpoint, a.partition)
+	req, err := http.NewRequestWithContext(a.ctx, "POST", url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request to switch partition: %w", err)
+	}
+	if _, err := a.makeRequest(req, signature, retryIdempotent); err != nil {
+		return fmt.Errorf("switching to partition %s: %w", a.partition, err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/charmbracelet/log"
+)
+
+// validateConfigFlag is the flag validating the configuration.
+const validateConfigFlag = "--validate-config"
+
+// isValidateConfig checks if the configuration validation was requested.
+func isValidateConfig() bool {
+	return slices.Contains(os.Args[1:], validateConfigFlag)
+}
+
+// runValidateConfig parses and validates the whole configuration and
+// prints the effective configuration as JSON with the secrets masked, e.g.
+// for CI/CD pipelines before a rollout. Unlike the check command, it
+// connects to neither Kubernetes nor the devices.
+// Returns the exit code, 1 if the configuration is invalid.
+func runValidateConfig(ctx context.Context, logger *log.Logger) int {
+	config := Config{logger: logger}
+	err := config.Get()
+	if err == nil {
+		_, err = newEligibilityChecks(config.EligibilityChecks, &config, &nodeState{logger: logger})
+	}
+	if err == nil {
+		_, err = parseAddressFallback(ctx, logger, config.NodeAddressFallback)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		return 1
+	}
+
+	effective := map[string]interface{}{}
+	fields := config.fields()
+	for i := 0; i+1 < len(fields); i += 2 {
+		value := fields[i+1]
+		if stringer, ok := value.(fmt.Stringer); ok {
+			value = stringer.String()
+		}
+		effective[fields[i].(string)] = value
+	}
+	out, err := json.MarshalIndent(effective, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "encoding configuration: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(out))
+	return 0
+}
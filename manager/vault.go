@@ -0,0 +1,220 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	defaultVaultAuthMount = "kubernetes"
+	serviceAccountToken   = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// vaultAuthResponse is the response from Vault when logging in or renewing
+// the token.
+type vaultAuthResponse struct {
+	Auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+// vaultSecretResponse is the response from Vault when reading a secret.
+// KV v2 secrets are nested in data.data, KV v1 secrets are in data.
+type vaultSecretResponse struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+// vaultCredentials resolves the A10 credentials from a Vault secret using
+// the Kubernetes auth method. The Vault token is renewed automatically and
+// the controller logs in again when it can't be renewed.
+type vaultCredentials struct {
+	logger    *log.Logger
+	address   string
+	path      string
+	role      string
+	authMount string
+	jwtPath   string
+	client    *http.Client
+
+	mu      sync.Mutex
+	token   Secret
+	expires time.Time
+}
+
+// newVaultCredentials creates the Vault provider and logs in to Vault.
+// Returns an error if the operation fails.
+func newVaultCredentials(ctx context.Context, config *Config) (*vaultCredentials, error) {
+	v := &vaultCredentials{
+		logger:    config.logger,
+		address:   strings.TrimSuffix(config.VaultAddress, "/"),
+		path:      strings.Trim(config.VaultPath, "/"),
+		role:      config.VaultRole,
+		authMount: strings.Trim(config.VaultAuthMount, "/"),
+		jwtPath:   serviceAccountToken,
+		client:    &http.Client{Timeout: defaultTimeout},
+	}
+	if err := v.login(ctx); err != nil {
+		return nil, fmt.Errorf("logging in to Vault: %w", err)
+	}
+	go v.renewLoop(ctx)
+	return v, nil
+}
+
+// login logs in to Vault with the Kubernetes service account token.
+// Returns an error if the operation fails.
+func (v *vaultCredentials) login(ctx context.Context) error {
+	v.logger.Debug("Logging in to Vault", "address", v.address, "role", v.role)
+	jwt, err := os.ReadFile(v.jwtPath)
+	if err != nil {
+		return fmt.Errorf("reading service account token: %w", err)
+	}
+	data, err := json.Marshal(map[string]string{
+		"role": v.role,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling JSON: %w", err)
+	}
+
+	body, err := v.request(ctx, "POST", fmt.Sprintf("auth/%s/login", v.authMount), data, "")
+	if err != nil {
+		return err
+	}
+	return v.setToken(body)
+}
+
+// renew renews the Vault token.
+// Returns an error if the operation fails.
+func (v *vaultCredentials) renew(ctx context.Context) error {
+	v.logger.Debug("Renewing Vault token")
+	body, err := v.request(ctx, "POST", "auth/token/renew-self", nil, v.currentToken())
+	if err != nil {
+		return err
+	}
+	return v.setToken(body)
+}
+
+// setToken stores the token from a Vault auth response.
+func (v *vaultCredentials) setToken(body []byte) error {
+	var response vaultAuthResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("unmarshaling JSON from Vault: %w", err)
+	}
+	if response.Auth.ClientToken == "" {
+		return fmt.Errorf("no client token in Vault response")
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.token = Secret(response.Auth.ClientToken)
+	v.expires = time.Now().Add(time.Duration(response.Auth.LeaseDuration) * time.Second)
+	v.logger.Debug("Got Vault token", "token", v.token, "expires", v.expires)
+	return nil
+}
+
+// currentToken returns the Vault token.
+func (v *vaultCredentials) currentToken() Secret {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.token
+}
+
+// renewLoop renews the Vault token at two thirds of its lease and logs in
+// again if the renewal fails. It returns when the context is done.
+func (v *vaultCredentials) renewLoop(ctx context.Context) {
+	for {
+		v.mu.Lock()
+		wait := time.Until(v.expires) * 2 / 3
+		v.mu.Unlock()
+		if wait < time.Second {
+			wait = time.Second
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		if err := v.renew(ctx); err != nil {
+			v.logger.Error("Error renewing Vault token, logging in again", "error", err)
+			if err := v.login(ctx); err != nil {
+				v.logger.Error("Error logging in to Vault", "error", err)
+			}
+		}
+	}
+}
+
+// Credentials reads the credentials from the Vault secret.
+// The secret must have the username and password keys.
+// Returns an error if the operation fails.
+func (v *vaultCredentials) Credentials(ctx context.Context) (Credentials, error) {
+	body, err := v.request(ctx, "GET", v.path, nil, v.currentToken())
+	if err != nil {
+		return Credentials{}, fmt.Errorf("reading Vault secret %s: %w", v.path, err)
+	}
+	var response vaultSecretResponse
+	if err = json.Unmarshal(body, &response); err != nil {
+		return Credentials{}, fmt.Errorf("unmarshaling JSON from Vault: %w", err)
+	}
+
+	data := response.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	username, _ := data["username"].(string)
+	password, _ := data["password"].(string)
+	if username == "" || password == "" {
+		return Credentials{}, fmt.Errorf("vault secret %s must have username and password keys", v.path)
+	}
+	return Credentials{Username: username, Password: Secret(password)}, nil
+}
+
+// request makes a request to the Vault API.
+// Returns an error if the operation fails.
+func (v *vaultCredentials) request(
+	ctx context.Context,
+	method, path string,
+	data []byte,
+	token Secret,
+) ([]byte, error) {
+	url := fmt.Sprintf("%s/v1/%s", v.address, path)
+	var reqBody io.Reader
+	if data != nil {
+		reqBody = bytes.NewBuffer(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("creating request to Vault: %w", err)
+	}
+	req.Header.Set("content-type", "application/json")
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token.Reveal())
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making request to Vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading Vault response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault request failed: %d", resp.StatusCode)
+	}
+	return body, nil
+}
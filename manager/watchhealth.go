@@ -0,0 +1,145 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/pager"
+)
+
+const (
+	// defaultWatchStaleAfter is how long the node watch may fail before
+	// the nodes are considered stale
+	defaultWatchStaleAfter = 5 * time.Minute
+	// watchCheckInterval is how often the node watch is checked
+	watchCheckInterval = 30 * time.Second
+	// nodeListPageSize is how many nodes are listed per request
+	nodeListPageSize = 500
+)
+
+// watchHealth detects the node watch failing for too long, when delete
+// events may be missed, and relists the nodes and reconciles once the API
+// server is reachable again, so the neighbors of the deleted nodes don't
+// stay forever. It is safe for concurrent use.
+type watchHealth struct {
+	logger     *log.Logger
+	clientset  kubernetes.Interface
+	staleAfter time.Duration
+
+	mu sync.Mutex
+	// failingSince is when the watch started failing, zero while it works
+	failingSince time.Time
+	stale        bool
+}
+
+// failed records the watch or relist failure and lets the informer handle
+// it as usual.
+func (w *watchHealth) failed(r *cache.Reflector, err error) {
+	cache.DefaultWatchErrorHandler(r, err)
+	nodeWatchFailures.Inc()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failingSince.IsZero() {
+		w.failingSince = time.Now()
+	}
+}
+
+// seen records a node event, proving the watch works.
+func (w *watchHealth) seen() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.stale {
+		w.failingSince = time.Time{}
+	}
+}
+
+// checkStale marks the nodes stale if the watch fails beyond the threshold.
+// Returns true if the nodes are stale.
+func (w *watchHealth) checkStale() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.stale && !w.failingSince.IsZero() && time.Since(w.failingSince) > w.staleAfter {
+		w.stale = true
+		nodeWatchStale.Set(1)
+		w.logger.Error(
+			"Node watch failing, delete events may be missed",
+			"since", w.failingSince,
+			"threshold", w.staleAfter,
+		)
+	}
+	return w.stale
+}
+
+// recovered clears the stale state.
+func (w *watchHealth) recovered() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stale = false
+	w.failingSince = time.Time{}
+	nodeWatchStale.Set(0)
+}
+
+// run checks the watch until the context is done. While the nodes are
+// stale, it tries a fresh list of the nodes, and once it succeeds it
+// reconciles the devices with it.
+func (w *watchHealth) run(ctx context.Context, reconcile func(corelisters.NodeLister)) {
+	ticker := time.NewTicker(watchCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !w.checkStale() {
+			continue
+		}
+		lister, err := w.relist(ctx)
+		if err != nil {
+			w.logger.Warn("Error relisting nodes, the API server is still unreachable", "error", err)
+			continue
+		}
+		w.logger.Info("API server reachable again, reconciling with the relisted nodes")
+		w.recovered()
+		reconcile(lister)
+	}
+}
+
+// relist lists the nodes from the API server, bypassing the informer
+// cache. The nodes are listed in pages of nodeListPageSize, so a large
+// cluster isn't listed in one response.
+// Returns an error if the operation fails.
+func (w *watchHealth) relist(ctx context.Context) (corelisters.NodeLister, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+	nodes := pager.New(pager.SimplePageFunc(func(opts metav1.ListOptions) (runtime.Object, error) {
+		return w.clientset.CoreV1().Nodes().List(ctx, opts)
+	}))
+	nodes.PageSize = nodeListPageSize
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	err := nodes.EachListItem(ctx, metav1.ListOptions{}, func(node runtime.Object) error {
+		stripped, err := stripNode(node)
+		if err != nil {
+			return err
+		}
+		if err := indexer.Add(stripped); err != nil {
+			return fmt.Errorf("indexing node: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+	return corelisters.NewNodeLister(indexer), nil
+}
@@ -0,0 +1,116 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+const defaultWebhookTimeout = 5 * time.Second
+
+// webhookReview is the response expected from the eligibility webhook.
+type webhookReview struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason"`
+}
+
+// eligibilityWebhook calls an external HTTP endpoint with the Node object
+// and lets it approve or veto peering.
+type eligibilityWebhook struct {
+	logger      *log.Logger
+	url         string
+	failOpen    bool
+	client      *http.Client
+	bearerToken Secret
+}
+
+func init() {
+	registerEligibilityCheck("webhook", newWebhookCheck)
+}
+
+// newWebhookCheck builds the webhook eligibility check from the config.
+func newWebhookCheck(_ string, config *Config, _ *nodeState) (func(*v1.Node) (bool, string), error) {
+	if config.WebhookURL == "" {
+		return nil, fmt.Errorf("NODE_ELIGIBILITY_WEBHOOK_URL must be set to use the webhook check")
+	}
+	w := &eligibilityWebhook{
+		logger:      config.logger,
+		url:         config.WebhookURL,
+		failOpen:    config.WebhookFailOpen,
+		bearerToken: config.WebhookToken,
+		client:      &http.Client{Timeout: config.WebhookTimeout},
+	}
+	return w.check, nil
+}
+
+// check posts the node to the webhook and returns its verdict.
+// If the webhook can't be reached or answers garbage, the node is
+// approved or vetoed according to the failure policy.
+func (w *eligibilityWebhook) check(node *v1.Node) (bool, string) {
+	logger := w.logger.With(
+		"node", node.Name,
+		"webhook", w.url,
+	)
+	review, err := w.review(node)
+	if err != nil {
+		logger.Error("Error calling eligibility webhook", "error", err, "failOpen", w.failOpen)
+		if w.failOpen {
+			return true, fmt.Sprintf("webhook failed, allowed by failure policy: %v", err)
+		}
+		return false, fmt.Sprintf("webhook failed, denied by failure policy: %v", err)
+	}
+	logger.Debug("Eligibility webhook response", "allowed", review.Allowed, "reason", review.Reason)
+	reason := review.Reason
+	if reason == "" {
+		reason = "no reason given by webhook"
+	}
+	return review.Allowed, reason
+}
+
+// review makes the webhook request.
+// Returns an error if the operation fails.
+func (w *eligibilityWebhook) review(node *v1.Node) (*webhookReview, error) {
+	jsonBytes, err := json.Marshal(node)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling node: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", w.url, bytes.NewBuffer(jsonBytes))
+	if err != nil {
+		return nil, fmt.Errorf("creating webhook request: %w", err)
+	}
+	req.Header.Set("accept", "application/json")
+	req.Header.Set("content-type", "application/json")
+	if w.bearerToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", w.bearerToken.Reveal()))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making webhook request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("webhook request failed: %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading webhook response body: %w", err)
+	}
+
+	var review webhookReview
+	if err = json.Unmarshal(body, &review); err != nil {
+		return nil, fmt.Errorf("unmarshaling webhook response: %w", err)
+	}
+	return &review, nil
+}